@@ -1,7 +1,12 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 )
@@ -12,57 +17,393 @@ var envMap = map[string]string{
 	userDatabase:   defaultUserDatabase,
 	userCollection: defaultUserCollection,
 	roleCollection: defaultRoleCollection,
+	jwtSecret:      defaultJWTSecret,
+	jwtSecretFile:  defaultJWTSecretFile,
+	jwtPrivateKey:  defaultJWTPrivateKey,
+	keyCollection:  defaultKeyCollection,
+	keyGracePeriod: defaultKeyGracePeriod,
+	keyEncryption:  defaultKeyEncryption,
+	keyEncryptFile: defaultKeyEncryptFile,
+	adminRole:      defaultAdminRole,
+	tokenLifetime:  defaultTokenLifetime,
+	tokenIssuer:    defaultTokenIssuer,
+	tokenAudience:  defaultTokenAudience,
+	refreshTokens:  defaultRefreshTokens,
+	refreshLife:    defaultRefreshLife,
+	revocations:    defaultRevocations,
+	introspectID:   defaultIntrospectID,
+	introspectKey:  defaultIntrospectKey,
+	oidcClients:    defaultOIDCClients,
+	authCodes:      defaultAuthCodes,
+	clients:        defaultClients,
+	deviceCodes:    defaultDeviceCodes,
+	deviceLife:     defaultDeviceLife,
+	passwordHash:   defaultPasswordHash,
+	bcryptCost:     defaultBcryptCost,
+	argon2Time:     defaultArgon2Time,
+	argon2Memory:   defaultArgon2Memory,
+	argon2Threads:  defaultArgon2Threads,
+	scryptN:        defaultScryptN,
+	scryptR:        defaultScryptR,
+	scryptP:        defaultScryptP,
+	minLength:      defaultMinLength,
+	maxLength:      defaultMaxLength,
+	requireUpper:   defaultRequireUpper,
+	requireLower:   defaultRequireLower,
+	requireDigit:   defaultRequireDigit,
+	requireSymbol:  defaultRequireSymbol,
+	minEntropy:     defaultMinEntropy,
+	breachFile:     defaultBreachFile,
+	breachMinCount: defaultBreachMinCount,
+	resets:         defaultResets,
+	resetLife:      defaultResetLife,
+	resetURL:       defaultResetURL,
+	mailer:         defaultMailer,
+	mailFrom:       defaultMailFrom,
+	mailDirectory:  defaultMailDirectory,
+	smtpAddr:       defaultSMTPAddr,
+	smtpUsername:   defaultSMTPUsername,
+	smtpPassword:   defaultSMTPPassword,
+	history:        defaultHistory,
+	lockout:        defaultLockout,
+	lockoutLife:    defaultLockoutLife,
+	lockoutMax:     defaultLockoutMax,
+	rateLimits:     defaultRateLimits,
+	rateLimitStore: defaultRateLimitStore,
+	rateLimitColl:  defaultRateLimitColl,
+	trustedProxies: defaultTrustedProxies,
+	mfaChallenges:  defaultMFAChallenges,
+	mfaLife:        defaultMFALife,
+	totpIssuer:     defaultTOTPIssuer,
+	recoveryCodes:  defaultRecoveryCodes,
+	webauthnRPID:   defaultWebAuthnRPID,
+	webauthnRPName: defaultWebAuthnRPName,
+	webauthnOrigin: defaultWebAuthnOrigin,
+	webauthnColl:   defaultWebAuthnColl,
+	webauthnLife:   defaultWebAuthnLife,
+	magicLinks:     defaultMagicLinks,
+	magicLife:      defaultMagicLife,
+	magicURL:       defaultMagicURL,
+	verifyRequired: defaultVerifyRequired,
+	verifyLife:     defaultVerifyLife,
+	verifyURL:      defaultVerifyURL,
+	purgeDelay:     defaultPurgeDelay,
+	reservations:   defaultReservations,
+	reservedFor:    defaultReservedFor,
 }
 
-//Config is the general struct for app configuration
+// Config is the general struct for app configuration
 type Config struct {
-	Port           string       `json:"port"`
-	UserDatabase   string       `json:"characterDatabase"`
-	UserCollection string       `json:"characterCollection"`
-	RoleCollection string       `json:"roleCollection"`
-	LogLevel       logrus.Level `json:"log-level"`
+	Port                        string              `json:"port"`
+	UserDatabase                string              `json:"characterDatabase"`
+	UserCollection              string              `json:"characterCollection"`
+	RoleCollection              string              `json:"roleCollection"`
+	LogLevel                    logrus.Level        `json:"log-level"`
+	JWTSecret                   []byte              `json:"-"`
+	JWTPrivateKeyFile           string              `json:"jwtPrivateKeyFile"`
+	KeyCollection               string              `json:"signingKeyCollection"`
+	KeyGracePeriod              time.Duration       `json:"keyRotationGracePeriod"`
+	KeyEncryptionKey            []byte              `json:"-"`
+	AdminRole                   string              `json:"adminRole"`
+	TokenLifetime               time.Duration       `json:"tokenLifetime"`
+	TokenIssuer                 string              `json:"tokenIssuer"`
+	TokenAudience               string              `json:"tokenAudience"`
+	RefreshTokenCollection      string              `json:"refreshTokenCollection"`
+	RefreshTokenLifetime        time.Duration       `json:"refreshTokenLifetime"`
+	RevocationCollection        string              `json:"revocationCollection"`
+	IntrospectionClientID       string              `json:"introspectionClientId"`
+	IntrospectionSecret         string              `json:"-"`
+	OIDCClients                 map[string][]string `json:"oidcClients"`
+	AuthorizationCodeCollection string              `json:"authorizationCodeCollection"`
+	ClientCollection            string              `json:"clientCollection"`
+	DeviceCodeCollection        string              `json:"deviceCodeCollection"`
+	DeviceCodeLifetime          time.Duration       `json:"deviceCodeLifetime"`
+	PasswordHashAlgorithm       string              `json:"passwordHashAlgorithm"`
+	BcryptCost                  int                 `json:"bcryptCost"`
+	Argon2Time                  int                 `json:"argon2Time"`
+	Argon2Memory                int                 `json:"argon2Memory"`
+	Argon2Threads               int                 `json:"argon2Threads"`
+	ScryptN                     int                 `json:"scryptN"`
+	ScryptR                     int                 `json:"scryptR"`
+	ScryptP                     int                 `json:"scryptP"`
+	PasswordMinLength           int                 `json:"passwordMinLength"`
+	PasswordMaxLength           int                 `json:"passwordMaxLength"`
+	PasswordRequireUppercase    bool                `json:"passwordRequireUppercase"`
+	PasswordRequireLowercase    bool                `json:"passwordRequireLowercase"`
+	PasswordRequireDigit        bool                `json:"passwordRequireDigit"`
+	PasswordRequireSymbol       bool                `json:"passwordRequireSymbol"`
+	PasswordMinEntropy          int                 `json:"passwordMinEntropy"`
+	BreachedPasswordsFile       string              `json:"breachedPasswordsFile"`
+	BreachedPasswordsThreshold  int                 `json:"breachedPasswordsThreshold"`
+	PasswordResetCollection     string              `json:"passwordResetCollection"`
+	PasswordResetLifetime       time.Duration       `json:"passwordResetLifetime"`
+	PasswordResetURL            string              `json:"passwordResetUrl"`
+	Mailer                      string              `json:"mailer"`
+	MailFrom                    string              `json:"mailFrom"`
+	MailDirectory               string              `json:"mailDirectory"`
+	SMTPAddr                    string              `json:"smtpAddr"`
+	SMTPUsername                string              `json:"smtpUsername"`
+	SMTPPassword                string              `json:"-"`
+	PasswordHistory             int                 `json:"passwordHistory"`
+	LockoutThreshold            int                 `json:"lockoutThreshold"`
+	LockoutDuration             time.Duration       `json:"lockoutDuration"`
+	LockoutMaxDuration          time.Duration       `json:"lockoutMaxDuration"`
+	RateLimits                  string              `json:"rateLimits"`
+	RateLimitStore              string              `json:"rateLimitStore"`
+	RateLimitCollection         string              `json:"rateLimitCollection"`
+	TrustedProxies              string              `json:"trustedProxies"`
+	MFAChallengeCollection      string              `json:"mfaChallengeCollection"`
+	MFAChallengeLifetime        time.Duration       `json:"mfaChallengeLifetime"`
+	TOTPIssuer                  string              `json:"totpIssuer"`
+	RecoveryCodeCount           int                 `json:"recoveryCodeCount"`
+	WebAuthnRPID                string              `json:"webauthnRpId"`
+	WebAuthnRPName              string              `json:"webauthnRpName"`
+	WebAuthnOrigins             []string            `json:"webauthnOrigins"`
+	WebAuthnChallengeCollection string              `json:"webauthnChallengeCollection"`
+	WebAuthnTimeout             time.Duration       `json:"webauthnTimeout"`
+	MagicLinkCollection         string              `json:"magicLinkCollection"`
+	MagicLinkLifetime           time.Duration       `json:"magicLinkLifetime"`
+	MagicLinkURL                string              `json:"magicLinkUrl"`
+	EmailVerificationRequired   bool                `json:"emailVerificationRequired"`
+	EmailVerificationLifetime   time.Duration       `json:"emailVerificationLifetime"`
+	EmailVerificationURL        string              `json:"emailVerificationUrl"`
+	UserPurgeDelay              time.Duration       `json:"userPurgeDelay"`
+	ReservedUsernameCollection  string              `json:"reservedUsernameCollection"`
+	ReservedUsernamePeriod      time.Duration       `json:"reservedUsernamePeriod"`
 }
 
-//Accessor is the interface setup for any configuration accessor
+// Accessor is the interface setup for any configuration accessor
 type Accessor interface {
 	BindEnv(input ...string) error
 	IsSet(key string) bool
 	GetString(key string) string
 }
 
-//New sets up a new config based on the interface passed
+// New sets up a new config based on the interface passed
 func New(accessor Accessor) (c *Config, err error) {
-	error := loadEnvVars(accessor)
+	env, error := loadEnvVars(accessor)
 	if error != nil {
 		return nil, error
 	}
 
-	currentLogLevel, err := logrus.ParseLevel(envMap[logLevel])
+	currentLogLevel, err := logrus.ParseLevel(env[logLevel])
 	if err != nil {
 		logrus.Warnf("Cannot load log-level: %v", err)
 	}
 
 	config := Config{
-		Port:           envMap[port],
-		LogLevel:       currentLogLevel,
-		UserDatabase:   envMap[userDatabase],
-		UserCollection: envMap[userCollection],
-		RoleCollection: envMap[roleCollection],
+		Port:                        env[port],
+		LogLevel:                    currentLogLevel,
+		UserDatabase:                env[userDatabase],
+		UserCollection:              env[userCollection],
+		RoleCollection:              env[roleCollection],
+		JWTPrivateKeyFile:           env[jwtPrivateKey],
+		KeyCollection:               env[keyCollection],
+		KeyGracePeriod:              parseDuration(keyGracePeriod, env[keyGracePeriod], defaultKeyGracePeriod),
+		AdminRole:                   env[adminRole],
+		TokenLifetime:               parseDuration(tokenLifetime, env[tokenLifetime], defaultTokenLifetime),
+		TokenIssuer:                 env[tokenIssuer],
+		TokenAudience:               env[tokenAudience],
+		RefreshTokenCollection:      env[refreshTokens],
+		RefreshTokenLifetime:        parseDuration(refreshLife, env[refreshLife], defaultRefreshLife),
+		RevocationCollection:        env[revocations],
+		IntrospectionClientID:       env[introspectID],
+		IntrospectionSecret:         env[introspectKey],
+		OIDCClients:                 parseClients(env[oidcClients]),
+		AuthorizationCodeCollection: env[authCodes],
+		ClientCollection:            env[clients],
+		DeviceCodeCollection:        env[deviceCodes],
+		DeviceCodeLifetime:          parseDuration(deviceLife, env[deviceLife], defaultDeviceLife),
+		PasswordHashAlgorithm:       strings.ToLower(env[passwordHash]),
+		BcryptCost:                  parseInt(bcryptCost, env[bcryptCost], defaultBcryptCost, 1),
+		Argon2Time:                  parseInt(argon2Time, env[argon2Time], defaultArgon2Time, 1),
+		Argon2Memory:                parseInt(argon2Memory, env[argon2Memory], defaultArgon2Memory, 1),
+		Argon2Threads:               parseInt(argon2Threads, env[argon2Threads], defaultArgon2Threads, 1),
+		ScryptN:                     parseInt(scryptN, env[scryptN], defaultScryptN, 1),
+		ScryptR:                     parseInt(scryptR, env[scryptR], defaultScryptR, 1),
+		ScryptP:                     parseInt(scryptP, env[scryptP], defaultScryptP, 1),
+		PasswordMinLength:           parseInt(minLength, env[minLength], defaultMinLength, 1),
+		PasswordMaxLength:           parseInt(maxLength, env[maxLength], defaultMaxLength, 0),
+		PasswordRequireUppercase:    parseBool(requireUpper, env[requireUpper], defaultRequireUpper),
+		PasswordRequireLowercase:    parseBool(requireLower, env[requireLower], defaultRequireLower),
+		PasswordRequireDigit:        parseBool(requireDigit, env[requireDigit], defaultRequireDigit),
+		PasswordRequireSymbol:       parseBool(requireSymbol, env[requireSymbol], defaultRequireSymbol),
+		PasswordMinEntropy:          parseInt(minEntropy, env[minEntropy], defaultMinEntropy, 0),
+		BreachedPasswordsFile:       env[breachFile],
+		BreachedPasswordsThreshold:  parseInt(breachMinCount, env[breachMinCount], defaultBreachMinCount, 1),
+		PasswordResetCollection:     env[resets],
+		PasswordResetLifetime:       parseDuration(resetLife, env[resetLife], defaultResetLife),
+		PasswordResetURL:            env[resetURL],
+		Mailer:                      strings.ToLower(env[mailer]),
+		MailFrom:                    env[mailFrom],
+		MailDirectory:               env[mailDirectory],
+		SMTPAddr:                    env[smtpAddr],
+		SMTPUsername:                env[smtpUsername],
+		SMTPPassword:                env[smtpPassword],
+		PasswordHistory:             parseInt(history, env[history], defaultHistory, 0),
+		LockoutThreshold:            parseInt(lockout, env[lockout], defaultLockout, 0),
+		LockoutDuration:             parseDuration(lockoutLife, env[lockoutLife], defaultLockoutLife),
+		LockoutMaxDuration:          parseDuration(lockoutMax, env[lockoutMax], defaultLockoutMax),
+		RateLimits:                  env[rateLimits],
+		RateLimitStore:              strings.ToLower(env[rateLimitStore]),
+		RateLimitCollection:         env[rateLimitColl],
+		TrustedProxies:              env[trustedProxies],
+		MFAChallengeCollection:      env[mfaChallenges],
+		MFAChallengeLifetime:        parseDuration(mfaLife, env[mfaLife], defaultMFALife),
+		TOTPIssuer:                  env[totpIssuer],
+		RecoveryCodeCount:           parseInt(recoveryCodes, env[recoveryCodes], defaultRecoveryCodes, 1),
+		WebAuthnRPID:                env[webauthnRPID],
+		WebAuthnRPName:              env[webauthnRPName],
+		WebAuthnOrigins:             parseList(env[webauthnOrigin]),
+		WebAuthnChallengeCollection: env[webauthnColl],
+		WebAuthnTimeout:             parseDuration(webauthnLife, env[webauthnLife], defaultWebAuthnLife),
+		MagicLinkCollection:         env[magicLinks],
+		MagicLinkLifetime:           parseDuration(magicLife, env[magicLife], defaultMagicLife),
+		MagicLinkURL:                env[magicURL],
+		EmailVerificationRequired:   parseBool(verifyRequired, env[verifyRequired], defaultVerifyRequired),
+		EmailVerificationLifetime:   parseDuration(verifyLife, env[verifyLife], defaultVerifyLife),
+		EmailVerificationURL:        env[verifyURL],
+		UserPurgeDelay:              parseDuration(purgeDelay, env[purgeDelay], defaultPurgeDelay),
+		ReservedUsernameCollection:  env[reservations],
+		ReservedUsernamePeriod:      parseDuration(reservedFor, env[reservedFor], defaultReservedFor),
 	}
+
+	// The HMAC secret is only required when tokens are not signed with a private key
+	config.JWTSecret, err = loadSecret(env[jwtSecret], env[jwtSecretFile], config.JWTPrivateKeyFile == "")
+	if err != nil {
+		return nil, err
+	}
+
+	config.KeyEncryptionKey, err = loadKeyEncryptionKey(env[keyEncryption], env[keyEncryptFile])
+	if err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
 
-func loadEnvVars(accessor Accessor) error {
-	for envKey := range envMap {
+func loadEnvVars(accessor Accessor) (map[string]string, error) {
+	values := make(map[string]string, len(envMap))
+	for envKey, defaultValue := range envMap {
 		err := accessor.BindEnv(envKey)
 		if err != nil {
-			return fmt.Errorf("error loading environment variable %s: %v", envKey, err)
+			return nil, fmt.Errorf("error loading environment variable %s: %v", envKey, err)
 		}
 
+		values[envKey] = defaultValue
 		if accessor.IsSet(envKey) {
-			envMap[envKey] = accessor.GetString(envKey)
+			values[envKey] = accessor.GetString(envKey)
 		}
 	}
 
-	return nil
+	return values, nil
+}
+
+// parseDuration falls back to the default when the value can not be parsed, the same way the log level does
+func parseDuration(key string, value string, defaultValue string) time.Duration {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		logrus.Warnf("Cannot load %s: %v", key, err)
+		duration, _ = time.ParseDuration(defaultValue)
+	}
+
+	return duration
+}
+
+// parseInt falls back to the default when the value is not a number of at least min
+func parseInt(key string, value string, defaultValue string, min int) int {
+	number, err := strconv.Atoi(value)
+	if err == nil && number < min {
+		err = fmt.Errorf("must be at least %d", min)
+	}
+	if err != nil {
+		logrus.Warnf("Cannot load %s: %v", key, err)
+		number, _ = strconv.Atoi(defaultValue)
+	}
+
+	return number
+}
+
+// parseBool falls back to the default when the value is not true or false
+func parseBool(key string, value string, defaultValue string) bool {
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		logrus.Warnf("Cannot load %s: %v", key, err)
+		enabled, _ = strconv.ParseBool(defaultValue)
+	}
+
+	return enabled
+}
+
+// parseClients reads comma separated client_id|redirect_uri pairs, a client with several redirect URIs is listed once
+// per URI
+func parseClients(value string) map[string][]string {
+	clients := map[string][]string{}
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "|", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			logrus.Warnf("Cannot load %s entry %q, expected client_id|redirect_uri", oidcClients, pair)
+			continue
+		}
+		clients[parts[0]] = append(clients[parts[0]], parts[1])
+	}
+
+	return clients
+}
+
+// parseList reads a comma separated list, empty entries are dropped
+func parseList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry != "" {
+			list = append(list, entry)
+		}
+	}
+
+	return list
+}
+
+// loadSecret reads the JWT secret from the file when one is given, such as a mounted kubernetes secret,
+// otherwise it uses the value of the environment variable
+func loadSecret(value string, file string, required bool) ([]byte, error) {
+	if file != "" {
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", jwtSecretFile, err)
+		}
+		value = strings.TrimSpace(string(contents))
+	}
+
+	if value == "" && !required {
+		return nil, nil
+	}
+
+	if value == "" || value == insecureJWTSecret {
+		return nil, errors.New("a JWT secret must be set with " + jwtSecret + " or " + jwtSecretFile + " and must not be the default")
+	}
+
+	return []byte(value), nil
+}
+
+// loadKeyEncryptionKey reads the key that encrypts rotated signing keys at rest, the file takes precedence over the value
+func loadKeyEncryptionKey(value string, file string) ([]byte, error) {
+	if file != "" {
+		contents, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", keyEncryptFile, err)
+		}
+		value = strings.TrimSpace(string(contents))
+	}
+
+	if value == "" {
+		return nil, nil
+	}
+
+	return []byte(value), nil
 }
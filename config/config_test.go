@@ -2,6 +2,8 @@ package config
 
 import (
 	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/geeksheik9/login-service/config/mocks"
@@ -12,9 +14,13 @@ func TestConfig_NewNoError(t *testing.T) {
 
 	configAccessor.On("BindEnv", port).Return(nil)
 	configAccessor.On("IsSet", port).Return(false)
+	configAccessor.On("BindEnv", jwtSecretFile).Return(nil)
+	configAccessor.On("IsSet", jwtSecretFile).Return(false)
+	configAccessor.On("BindEnv", keyEncryptFile).Return(nil)
+	configAccessor.On("IsSet", keyEncryptFile).Return(false)
 
 	for envKey := range envMap {
-		if envKey != port {
+		if envKey != port && envKey != jwtSecretFile && envKey != keyEncryptFile {
 			configAccessor.On("BindEnv", envKey).Return(nil)
 			configAccessor.On("IsSet", envKey).Return(true)
 			configAccessor.On("GetString", envKey).Return("dummyEnvValue")
@@ -25,7 +31,7 @@ func TestConfig_NewNoError(t *testing.T) {
 
 	configAccessor.AssertNumberOfCalls(t, "BindEnv", len(envMap))
 	configAccessor.AssertNumberOfCalls(t, "IsSet", len(envMap))
-	configAccessor.AssertNumberOfCalls(t, "GetString", len(envMap)-1)
+	configAccessor.AssertNumberOfCalls(t, "GetString", len(envMap)-3)
 
 	// Test that port uses default value
 	if c.Port != defaultPort {
@@ -51,3 +57,162 @@ func TestConfig_NewWithError(t *testing.T) {
 		t.Errorf("New() returned wrong value: got %v, want %v", err, expectedErr)
 	}
 }
+
+func newAccessor(values map[string]string) *mocks.ConfigAccessor {
+	configAccessor := &mocks.ConfigAccessor{}
+
+	for envKey := range envMap {
+		value, ok := values[envKey]
+		configAccessor.On("BindEnv", envKey).Return(nil)
+		configAccessor.On("IsSet", envKey).Return(ok)
+		if ok {
+			configAccessor.On("GetString", envKey).Return(value)
+		}
+	}
+
+	return configAccessor
+}
+
+func TestConfig_NewMissingSecret(t *testing.T) {
+	_, err := New(newAccessor(map[string]string{}))
+	if err == nil {
+		t.Errorf("New() error:\n   expected: <error>\n   got:      %v", err)
+	}
+}
+
+func TestConfig_NewDefaultSecret(t *testing.T) {
+	_, err := New(newAccessor(map[string]string{jwtSecret: insecureJWTSecret}))
+	if err == nil {
+		t.Errorf("New() error:\n   expected: <error>\n   got:      %v", err)
+	}
+}
+
+func TestConfig_NewSecretFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "jwt-secret")
+	err := os.WriteFile(file, []byte("mounted-secret\n"), 0600)
+	if err != nil {
+		t.Fatalf("unable to write secret file: %v", err)
+	}
+
+	c, err := New(newAccessor(map[string]string{jwtSecret: "ignored-secret", jwtSecretFile: file}))
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	if string(c.JWTSecret) != "mounted-secret" {
+		t.Errorf("New() JWTSecret error:\n   expected: mounted-secret\n   got:      %s", c.JWTSecret)
+	}
+}
+
+func TestConfig_NewMissingSecretFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "missing")
+
+	_, err := New(newAccessor(map[string]string{jwtSecretFile: file}))
+	if err == nil {
+		t.Errorf("New() error:\n   expected: <error>\n   got:      %v", err)
+	}
+}
+
+func TestConfig_NewKeyEncryptionFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "key-encryption")
+	err := os.WriteFile(file, []byte("mounted-key\n"), 0600)
+	if err != nil {
+		t.Fatalf("unable to write key file: %v", err)
+	}
+
+	c, err := New(newAccessor(map[string]string{jwtSecret: "a-much-better-secret", keyEncryption: "ignored-key", keyEncryptFile: file}))
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	if string(c.KeyEncryptionKey) != "mounted-key" {
+		t.Errorf("New() KeyEncryptionKey error:\n   expected: mounted-key\n   got:      %s", c.KeyEncryptionKey)
+	}
+}
+
+func TestConfig_NewPrivateKeyWithoutSecret(t *testing.T) {
+	c, err := New(newAccessor(map[string]string{jwtPrivateKey: "/keys/signing.pem"}))
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	if c.JWTPrivateKeyFile != "/keys/signing.pem" || c.JWTSecret != nil {
+		t.Errorf("New() error:\n   expected: /keys/signing.pem and no secret\n   got:      %v and %s", c.JWTPrivateKeyFile, c.JWTSecret)
+	}
+}
+
+func TestConfig_NewOIDCClients(t *testing.T) {
+	value := "web|https://app.example.com/callback, web|http://localhost:8080/callback,broken,cli|http://127.0.0.1/cb"
+	c, err := New(newAccessor(map[string]string{jwtSecret: "a-much-better-secret", oidcClients: value}))
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	if len(c.OIDCClients) != 2 || len(c.OIDCClients["web"]) != 2 || c.OIDCClients["cli"][0] != "http://127.0.0.1/cb" {
+		t.Errorf("New() OIDCClients error: got %v", c.OIDCClients)
+	}
+}
+
+func TestConfig_NewPasswordHashing(t *testing.T) {
+	c, err := New(newAccessor(map[string]string{
+		jwtSecret:    "a-much-better-secret",
+		passwordHash: "BCRYPT",
+		bcryptCost:   "14",
+		argon2Time:   "0",
+		scryptN:      "lots",
+	}))
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	if c.PasswordHashAlgorithm != "bcrypt" || c.BcryptCost != 14 {
+		t.Errorf("New() password hashing error: got %v with cost %v", c.PasswordHashAlgorithm, c.BcryptCost)
+	}
+	if c.Argon2Time != 2 || c.ScryptN != 131072 {
+		t.Errorf("New() password hashing error:\n   expected: defaults for invalid values\n   got:      %v, %v", c.Argon2Time, c.ScryptN)
+	}
+}
+
+func TestConfig_NewPasswordPolicy(t *testing.T) {
+	c, err := New(newAccessor(map[string]string{
+		jwtSecret:     "a-much-better-secret",
+		minLength:     "12",
+		maxLength:     "0",
+		requireDigit:  "true",
+		requireSymbol: "sometimes",
+		minEntropy:    "-1",
+	}))
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	if c.PasswordMinLength != 12 || c.PasswordMaxLength != 0 || !c.PasswordRequireDigit {
+		t.Errorf("New() password policy error: got %v, %v, %v", c.PasswordMinLength, c.PasswordMaxLength, c.PasswordRequireDigit)
+	}
+	if c.PasswordRequireSymbol || c.PasswordMinEntropy != 30 {
+		t.Errorf("New() password policy error:\n   expected: defaults for invalid values\n   got:      %v, %v", c.PasswordRequireSymbol, c.PasswordMinEntropy)
+	}
+}
+
+func TestConfig_NewPasswordHistory(t *testing.T) {
+	c, err := New(newAccessor(map[string]string{jwtSecret: "a-much-better-secret", history: "0"}))
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	if c.PasswordHistory != 0 {
+		t.Errorf("New() PasswordHistory error:\n   expected: 0\n   got:      %v", c.PasswordHistory)
+	}
+}
+
+func TestConfig_NewWebAuthnOrigins(t *testing.T) {
+	value := "https://login.example.com, ,https://admin.example.com"
+	c, err := New(newAccessor(map[string]string{jwtSecret: "a-much-better-secret", webauthnOrigin: value}))
+	if err != nil {
+		t.Fatalf("New() returned error: %v", err)
+	}
+
+	if len(c.WebAuthnOrigins) != 2 || c.WebAuthnOrigins[1] != "https://admin.example.com" {
+		t.Errorf("New() WebAuthnOrigins error:\n   expected: 2 origins\n   got:      %v", c.WebAuthnOrigins)
+	}
+}
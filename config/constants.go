@@ -6,6 +6,79 @@ const (
 	userDatabase   = "USER_DATABASE"
 	userCollection = "USER_COLLECTION"
 	roleCollection = "ROLE_COLLECTION"
+	jwtSecret      = "JWT_SECRET"
+	jwtSecretFile  = "JWT_SECRET_FILE"
+	jwtPrivateKey  = "JWT_PRIVATE_KEY_FILE"
+	keyCollection  = "SIGNING_KEY_COLLECTION"
+	keyGracePeriod = "KEY_ROTATION_GRACE_PERIOD"
+	keyEncryption  = "SIGNING_KEY_ENCRYPTION_KEY"
+	keyEncryptFile = "SIGNING_KEY_ENCRYPTION_KEY_FILE"
+	adminRole      = "ADMIN_ROLE"
+	tokenLifetime  = "TOKEN_LIFETIME"
+	tokenIssuer    = "TOKEN_ISSUER"
+	tokenAudience  = "TOKEN_AUDIENCE"
+	refreshTokens  = "REFRESH_TOKEN_COLLECTION"
+	refreshLife    = "REFRESH_TOKEN_LIFETIME"
+	revocations    = "REVOCATION_COLLECTION"
+	introspectID   = "INTROSPECTION_CLIENT_ID"
+	introspectKey  = "INTROSPECTION_CLIENT_SECRET"
+	oidcClients    = "OIDC_CLIENTS"
+	authCodes      = "AUTHORIZATION_CODE_COLLECTION"
+	clients        = "CLIENT_COLLECTION"
+	deviceCodes    = "DEVICE_CODE_COLLECTION"
+	deviceLife     = "DEVICE_CODE_LIFETIME"
+	passwordHash   = "PASSWORD_HASH_ALGORITHM"
+	bcryptCost     = "PASSWORD_BCRYPT_COST"
+	argon2Time     = "PASSWORD_ARGON2_TIME"
+	argon2Memory   = "PASSWORD_ARGON2_MEMORY"
+	argon2Threads  = "PASSWORD_ARGON2_THREADS"
+	scryptN        = "PASSWORD_SCRYPT_N"
+	scryptR        = "PASSWORD_SCRYPT_R"
+	scryptP        = "PASSWORD_SCRYPT_P"
+	minLength      = "PASSWORD_MIN_LENGTH"
+	maxLength      = "PASSWORD_MAX_LENGTH"
+	requireUpper   = "PASSWORD_REQUIRE_UPPERCASE"
+	requireLower   = "PASSWORD_REQUIRE_LOWERCASE"
+	requireDigit   = "PASSWORD_REQUIRE_DIGIT"
+	requireSymbol  = "PASSWORD_REQUIRE_SYMBOL"
+	minEntropy     = "PASSWORD_MIN_ENTROPY"
+	breachFile     = "BREACHED_PASSWORDS_FILE"
+	breachMinCount = "BREACHED_PASSWORDS_THRESHOLD"
+	resets         = "PASSWORD_RESET_COLLECTION"
+	resetLife      = "PASSWORD_RESET_LIFETIME"
+	resetURL       = "PASSWORD_RESET_URL"
+	mailer         = "MAILER"
+	mailFrom       = "MAIL_FROM"
+	mailDirectory  = "MAIL_DIRECTORY"
+	smtpAddr       = "SMTP_ADDR"
+	smtpUsername   = "SMTP_USERNAME"
+	smtpPassword   = "SMTP_PASSWORD"
+	history        = "PASSWORD_HISTORY"
+	lockout        = "LOCKOUT_THRESHOLD"
+	lockoutLife    = "LOCKOUT_DURATION"
+	lockoutMax     = "LOCKOUT_MAX_DURATION"
+	rateLimits     = "RATE_LIMITS"
+	rateLimitStore = "RATE_LIMIT_STORE"
+	rateLimitColl  = "RATE_LIMIT_COLLECTION"
+	trustedProxies = "TRUSTED_PROXIES"
+	mfaChallenges  = "MFA_CHALLENGE_COLLECTION"
+	mfaLife        = "MFA_CHALLENGE_LIFETIME"
+	totpIssuer     = "TOTP_ISSUER"
+	recoveryCodes  = "RECOVERY_CODE_COUNT"
+	webauthnRPID   = "WEBAUTHN_RP_ID"
+	webauthnRPName = "WEBAUTHN_RP_NAME"
+	webauthnOrigin = "WEBAUTHN_ORIGINS"
+	webauthnColl   = "WEBAUTHN_CHALLENGE_COLLECTION"
+	webauthnLife   = "WEBAUTHN_TIMEOUT"
+	magicLinks     = "MAGIC_LINK_COLLECTION"
+	magicLife      = "MAGIC_LINK_LIFETIME"
+	magicURL       = "MAGIC_LINK_URL"
+	verifyRequired = "EMAIL_VERIFICATION_REQUIRED"
+	verifyLife     = "EMAIL_VERIFICATION_LIFETIME"
+	verifyURL      = "EMAIL_VERIFICATION_URL"
+	purgeDelay     = "USER_PURGE_DELAY"
+	reservations   = "USERNAME_RESERVATION_COLLECTION"
+	reservedFor    = "USERNAME_RESERVATION_PERIOD"
 )
 
 const (
@@ -14,4 +87,85 @@ const (
 	defaultUserDatabase   = "users"
 	defaultUserCollection = "users"
 	defaultRoleCollection = "roles"
+	defaultJWTSecret      = ""
+	defaultJWTSecretFile  = ""
+	defaultJWTPrivateKey  = ""
+	defaultKeyCollection  = "signingKeys"
+	defaultKeyEncryption  = ""
+	defaultKeyEncryptFile = ""
+	defaultKeyGracePeriod = "24h"
+	defaultAdminRole      = "admin"
+	defaultTokenLifetime  = "1h"
+	defaultTokenIssuer    = "login-service"
+	defaultTokenAudience  = "login-service"
+	defaultRefreshTokens  = "refreshTokens"
+	defaultRefreshLife    = "720h"
+	defaultRevocations    = "revocations"
+	defaultIntrospectID   = ""
+	defaultIntrospectKey  = ""
+	defaultOIDCClients    = ""
+	defaultAuthCodes      = "authorizationCodes"
+	defaultClients        = "clients"
+	defaultDeviceCodes    = "deviceCodes"
+	defaultDeviceLife     = "10m"
+	defaultPasswordHash   = "argon2id"
+	defaultBcryptCost     = "12"
+	defaultArgon2Time     = "2"
+	defaultArgon2Memory   = "19456"
+	defaultArgon2Threads  = "1"
+	defaultScryptN        = "131072"
+	defaultScryptR        = "8"
+	defaultScryptP        = "1"
+	defaultMinLength      = "8"
+	defaultMaxLength      = "128"
+	defaultRequireUpper   = "false"
+	defaultRequireLower   = "false"
+	defaultRequireDigit   = "false"
+	defaultRequireSymbol  = "false"
+	defaultMinEntropy     = "30"
+	defaultBreachFile     = ""
+	defaultBreachMinCount = "1"
+	defaultResets         = "passwordResets"
+	defaultResetLife      = "1h"
+	defaultResetURL       = ""
+	defaultMailer         = ""
+	defaultMailFrom       = "no-reply@localhost"
+	defaultMailDirectory  = "mail"
+	defaultSMTPAddr       = ""
+	defaultSMTPUsername   = ""
+	defaultSMTPPassword   = ""
+	defaultHistory        = "5"
+	defaultLockout        = "5"
+	defaultLockoutLife    = "1m"
+	defaultLockoutMax     = "1h"
+	defaultRateLimits     = "login:ip=20/m,login:username=10/m,register:ip=5/h,password:ip=10/m,password:username=5/m," +
+		"password/forgot:ip=5/h,password/forgot:username=3/h,password/reset:ip=10/h,authorize:ip=20/m,authorize:username=10/m," +
+		"device:ip=20/m,device:username=10/m,token:ip=60/m,login/mfa:ip=10/m,webauthn/login:ip=20/m," +
+		"login/magic:ip=10/h,login/magic:username=5/h,login/magic/verify:ip=20/m,login/magic/verify:username=10/m," +
+		"email/verify:ip=20/m,email/verify/resend:ip=5/h,email/verify/resend:username=3/h,users/me/username:ip=10/m," +
+		"users/me/username:username=5/m,users/me:ip=10/m,users/me:username=5/m"
+	defaultRateLimitStore = "memory"
+	defaultRateLimitColl  = "rateLimits"
+	defaultTrustedProxies = ""
+	defaultMFAChallenges  = "mfaChallenges"
+	defaultMFALife        = "5m"
+	defaultTOTPIssuer     = "Login Service"
+	defaultRecoveryCodes  = "10"
+	defaultWebAuthnRPID   = "localhost"
+	defaultWebAuthnRPName = "Login Service"
+	defaultWebAuthnOrigin = "http://localhost:3000"
+	defaultWebAuthnColl   = "webauthnChallenges"
+	defaultWebAuthnLife   = "2m"
+	defaultMagicLinks     = "magicLinks"
+	defaultMagicLife      = "15m"
+	defaultMagicURL       = ""
+	defaultVerifyRequired = "false"
+	defaultVerifyLife     = "72h"
+	defaultVerifyURL      = ""
+	defaultPurgeDelay     = "0s"
+	defaultReservations   = "usernameReservations"
+	defaultReservedFor    = "720h"
 )
+
+// insecureJWTSecret is the secret the service used to ship with, it is refused at startup
+const insecureJWTSecret = "secret"
@@ -9,22 +9,36 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/geeksheik9/login-service/config"
+	"github.com/geeksheik9/login-service/pkg/audit"
 	"github.com/geeksheik9/login-service/pkg/db"
 	"github.com/geeksheik9/login-service/pkg/handler"
+	"github.com/geeksheik9/login-service/pkg/mail"
+	"github.com/geeksheik9/login-service/pkg/password"
+	"github.com/geeksheik9/login-service/pkg/ratelimit"
+	"github.com/geeksheik9/login-service/pkg/token"
+	"github.com/geeksheik9/login-service/pkg/webauthn"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/cors"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var version string
 
+// keyReloadInterval is how often signing keys rotated by other replicas are picked up
+const keyReloadInterval = time.Minute
+
+// revocationReloadInterval is how often tokens revoked on other replicas are picked up
+const revocationReloadInterval = 10 * time.Second
+
 func main() {
 	//go:generate swagger generate spec
 	log.Info("INITIALIZING LOGIN SERVICE")
@@ -44,7 +58,8 @@ func main() {
 	if err != nil {
 		log.Warnf("Failed to intialize client with error: %v, trying again", err)
 		err = nil
-		ctx, _ = context.WithTimeout(context.Background(), time.Second*60)
+		ctx, cancel = context.WithTimeout(context.Background(), time.Second*60)
+		defer cancel()
 		client, err = db.InitializeClients(ctx)
 		if err != nil {
 			log.Fatalf("Failed to initialize database client a second time with error: %v", err)
@@ -53,14 +68,136 @@ func main() {
 
 	defer client.Disconnect(context.Background())
 
-	database := db.InitializeDatabases(client, config)
+	bootstrapKey, err := loadBootstrapKey(config)
+	if err != nil {
+		log.Fatalf("ERROR LOADING SIGNING KEYS: %v", err.Error())
+	}
+
+	keys, err := token.NewKeySet(db.InitializeKeyStore(client, config), bootstrapKey, config.KeyGracePeriod, config.KeyEncryptionKey)
+	if err != nil {
+		log.Fatalf("ERROR LOADING SIGNING KEYS: %v", err.Error())
+	}
+	if config.KeyEncryptionKey == nil {
+		log.Warn("No signing key encryption key is configured, signing keys can not be rotated")
+	}
+	go keys.Watch(context.Background(), keyReloadInterval)
+
+	indexCtx, indexCancel := context.WithTimeout(context.Background(), timeout)
+	defer indexCancel()
+
+	revocationStore := db.InitializeRevocationStore(client, config)
+	err = revocationStore.EnsureIndexes(indexCtx)
+	if err != nil {
+		log.Fatalf("Failed to create revocation indexes: %v", err)
+	}
+
+	revocations, err := token.NewRevocationList(revocationStore, config.TokenLifetime)
+	if err != nil {
+		log.Fatalf("ERROR LOADING REVOCATIONS: %v", err.Error())
+	}
+	go revocations.Watch(context.Background(), revocationReloadInterval)
+
+	issuer := &token.Issuer{
+		Keys:        keys,
+		Issuer:      config.TokenIssuer,
+		Audience:    config.TokenAudience,
+		Lifetime:    config.TokenLifetime,
+		Revocations: revocations,
+	}
+
+	hasher, err := password.NewHasher(config.PasswordHashAlgorithm, password.Options{
+		BcryptCost:    config.BcryptCost,
+		Argon2Time:    uint32(config.Argon2Time),
+		Argon2Memory:  uint32(config.Argon2Memory),
+		Argon2Threads: uint8(config.Argon2Threads),
+		ScryptN:       config.ScryptN,
+		ScryptR:       config.ScryptR,
+		ScryptP:       config.ScryptP,
+	})
+	if err != nil {
+		log.Fatalf("ERROR CONFIGURING PASSWORD HASHING: %v", err.Error())
+	}
+
+	database := db.InitializeDatabases(client, config, hasher)
 	if database == nil {
 		log.Fatalf("Error no database from client %v", client)
 	}
 
+	err = database.EnsureIndexes(indexCtx)
+	if err != nil {
+		log.Fatalf("Failed to create database indexes: %v", err)
+	}
+
+	limiter, err := newRateLimiter(client, config)
+	if err != nil {
+		log.Fatalf("ERROR CONFIGURING RATE LIMITS: %v", err.Error())
+	}
+
+	mailer, err := newMailer(config)
+	if err != nil {
+		log.Fatalf("ERROR CONFIGURING MAIL: %v", err.Error())
+	}
+
+	var breaches *password.BreachList
+	if config.BreachedPasswordsFile != "" {
+		breaches, err = password.LoadBreachList(config.BreachedPasswordsFile, config.BreachedPasswordsThreshold)
+		if err != nil {
+			log.Fatalf("ERROR LOADING BREACHED PASSWORDS: %v", err.Error())
+		}
+		log.Infof("Loaded %d breached password hashes", breaches.Len())
+	}
+
 	gearService := handler.LoginService{
-		Version:  version,
-		Database: database,
+		Version:    version,
+		Database:   database,
+		Keys:       keys,
+		KeyManager: keys,
+		Tokens:     issuer,
+		AdminRole:  config.AdminRole,
+
+		RefreshLifetime: config.RefreshTokenLifetime,
+		IntrospectionClient: handler.ClientCredentials{
+			ID:     config.IntrospectionClientID,
+			Secret: config.IntrospectionSecret,
+		},
+		OIDCClients:        config.OIDCClients,
+		DeviceCodeLifetime: config.DeviceCodeLifetime,
+		PasswordPolicy: &password.Policy{
+			MinLength:        config.PasswordMinLength,
+			MaxLength:        config.PasswordMaxLength,
+			RequireUppercase: config.PasswordRequireUppercase,
+			RequireLowercase: config.PasswordRequireLowercase,
+			RequireDigit:     config.PasswordRequireDigit,
+			RequireSymbol:    config.PasswordRequireSymbol,
+			MinEntropy:       float64(config.PasswordMinEntropy),
+			Breaches:         breaches,
+		},
+
+		Mailer:                mailer,
+		PasswordResetLifetime: config.PasswordResetLifetime,
+		PasswordResetURL:      config.PasswordResetURL,
+
+		RateLimiter: limiter,
+
+		MFAChallengeLifetime: config.MFAChallengeLifetime,
+		TOTPIssuer:           config.TOTPIssuer,
+		RecoveryCodeCount:    config.RecoveryCodeCount,
+
+		WebAuthn: &webauthn.RelyingParty{
+			ID:      config.WebAuthnRPID,
+			Name:    config.WebAuthnRPName,
+			Origins: config.WebAuthnOrigins,
+		},
+		WebAuthnTimeout: config.WebAuthnTimeout,
+
+		MagicLinkLifetime: config.MagicLinkLifetime,
+		MagicLinkURL:      config.MagicLinkURL,
+
+		EmailVerificationRequired: config.EmailVerificationRequired,
+		EmailVerificationLifetime: config.EmailVerificationLifetime,
+		EmailVerificationURL:      config.EmailVerificationURL,
+
+		Audit: &audit.LogRecorder{},
 	}
 
 	r := mux.NewRouter().StrictSlash(true)
@@ -70,3 +207,73 @@ func main() {
 	log.Info("END")
 	log.Fatal(http.ListenAndServe(":"+config.Port, cors.AllowAll().Handler(r)))
 }
+
+// loadBootstrapKey returns the configured private key when there is one and falls back to the HMAC secret,
+// it signs tokens until a rotated key is promoted over it
+func loadBootstrapKey(c *config.Config) (*token.Key, error) {
+	if c.JWTPrivateKeyFile == "" {
+		return token.NewHMACKey(c.JWTSecret)
+	}
+
+	key, err := token.LoadPrivateKeyFile(c.JWTPrivateKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	log.Infof("Bootstrap signing key is %v key %v", key.Method.Alg(), key.ID)
+
+	return key, nil
+}
+
+// newMailer returns the configured mail sender, there is no default so reset, magic and verification links are never
+// silently dropped
+func newMailer(c *config.Config) (mail.Mailer, error) {
+	if c.Mailer == "" {
+		return nil, errors.New("MAILER is required, set it to smtp or file")
+	}
+	if !mail.IsAddress(c.MailFrom) {
+		return nil, fmt.Errorf("MAIL_FROM %q is not an email address", c.MailFrom)
+	}
+
+	switch c.Mailer {
+	case "smtp":
+		if c.SMTPAddr == "" {
+			return nil, errors.New("SMTP_ADDR is required for the smtp mailer")
+		}
+		return &mail.SMTPMailer{Addr: c.SMTPAddr, From: c.MailFrom, Username: c.SMTPUsername, Password: c.SMTPPassword}, nil
+	case "file":
+		return &mail.FileMailer{Dir: c.MailDirectory, From: c.MailFrom}, nil
+	}
+
+	return nil, fmt.Errorf("unknown mailer %q", c.Mailer)
+}
+
+// newRateLimiter returns the limiter for the configured limits, the mongo store shares the limits between replicas
+func newRateLimiter(client *mongo.Client, c *config.Config) (*ratelimit.Limiter, error) {
+	limits, err := ratelimit.ParseLimits(c.RateLimits)
+	if err != nil {
+		return nil, err
+	}
+	proxies, err := ratelimit.ParseNetworks(c.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	limiter := &ratelimit.Limiter{Limits: limits, TrustedProxies: proxies}
+	switch c.RateLimitStore {
+	case "memory":
+		limiter.Store = ratelimit.NewMemoryStore()
+	case "mongo":
+		store := db.InitializeRateLimitStore(client, c)
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err = store.EnsureIndexes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		limiter.Store = store
+	default:
+		return nil, fmt.Errorf("unknown rate limit store %q", c.RateLimitStore)
+	}
+
+	return limiter, nil
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// AuthorizationCode is the stored form of an OAuth 2.0 authorization code, only the hash of the code is kept
+type AuthorizationCode struct {
+	Hash          string    `json:"-" bson:"hash"`
+	ClientID      string    `json:"clientId" bson:"clientId"`
+	RedirectURI   string    `json:"redirectUri" bson:"redirectUri"`
+	Username      string    `json:"username" bson:"username"`
+	Scope         string    `json:"scope" bson:"scope"`
+	Nonce         string    `json:"nonce,omitempty" bson:"nonce,omitempty"`
+	CodeChallenge string    `json:"-" bson:"codeChallenge"`
+	AuthTime      time.Time `json:"authTime" bson:"authTime"`
+	ExpiresAt     time.Time `json:"expiresAt" bson:"expiresAt"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Client is an OAuth 2.0 client registered with the service
+type Client struct {
+	ClientID     string    `json:"clientId" bson:"clientId"`
+	Name         string    `json:"name" bson:"name"`
+	Secret       string    `json:"clientSecret,omitempty" bson:"-"`
+	SecretHash   string    `json:"-" bson:"secretHash,omitempty"`
+	Public       bool      `json:"public" bson:"public"`
+	Scopes       []string  `json:"scopes" bson:"scopes"`
+	GrantTypes   []string  `json:"grantTypes" bson:"grantTypes"`
+	RedirectURIs []string  `json:"redirectUris,omitempty" bson:"redirectUris,omitempty"`
+	CreatedAt    time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// HasGrantType checks whether the client may use the grant type
+func (c *Client) HasGrantType(grantType string) bool {
+	for _, value := range c.GrantTypes {
+		if value == grantType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// HasScope checks whether the scope was assigned to the client
+func (c *Client) HasScope(scope string) bool {
+	for _, value := range c.Scopes {
+		if value == scope {
+			return true
+		}
+	}
+
+	return false
+}
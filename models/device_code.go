@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Device code statuses, a code starts pending until the user approves or denies it
+const (
+	DeviceCodePending  = "pending"
+	DeviceCodeApproved = "approved"
+	DeviceCodeDenied   = "denied"
+)
+
+// DeviceCode is the stored form of an RFC 8628 device authorization, only the hash of the device code is kept
+type DeviceCode struct {
+	Hash         string     `json:"-" bson:"hash"`
+	UserCode     string     `json:"userCode" bson:"userCode"`
+	ClientID     string     `json:"clientId" bson:"clientId"`
+	Scope        string     `json:"scope,omitempty" bson:"scope,omitempty"`
+	Status       string     `json:"status" bson:"status"`
+	Username     string     `json:"username,omitempty" bson:"username,omitempty"`
+	ExpiresAt    time.Time  `json:"expiresAt" bson:"expiresAt"`
+	LastPolledAt *time.Time `json:"lastPolledAt,omitempty" bson:"lastPolledAt,omitempty"`
+}
+
+// DeviceAuthorizationResponse is returned when a device starts the device authorization grant
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
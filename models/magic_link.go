@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// MagicLink is the stored form of a passwordless login sent by email, only hashes of the link token and of the code
+// are kept
+type MagicLink struct {
+	Hash      string    `json:"-" bson:"hash"`
+	CodeHash  string    `json:"-" bson:"codeHash"`
+	Username  string    `json:"username" bson:"username"`
+	Email     string    `json:"email" bson:"email"`
+	Attempts  int       `json:"attempts" bson:"attempts"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// TOTP is a user's authenticator app enrollment, it only counts as a second factor once it has been confirmed with a
+// first code
+type TOTP struct {
+	Secret        string     `json:"-" bson:"secret"`
+	Confirmed     bool       `json:"confirmed" bson:"confirmed"`
+	LastCounter   int64      `json:"-" bson:"lastCounter"`
+	RecoveryCodes []string   `json:"-" bson:"recoveryCodes,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt" bson:"createdAt"`
+	ConfirmedAt   *time.Time `json:"confirmedAt,omitempty" bson:"confirmedAt,omitempty"`
+}
+
+// MFAChallenge is the stored form of the interim token a user gets from /login when a second factor is required, only
+// the hash of the token is kept
+type MFAChallenge struct {
+	Hash      string    `json:"-" bson:"hash"`
+	Username  string    `json:"username" bson:"username"`
+	Attempts  int       `json:"attempts" bson:"attempts"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
+
+// MFARequiredResponse is returned by /login instead of tokens when the user has to finish at /login/mfa
+type MFARequiredResponse struct {
+	Status    string `json:"status"`
+	MFAToken  string `json:"mfa_token"`
+	ExpiresIn int64  `json:"expires_in"`
+}
+
+// TOTPEnrollmentResponse is the secret of a new enrollment, as is and as an otpauth URI for a QR code
+type TOTPEnrollmentResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// RecoveryCodesResponse holds the only copy of a user's recovery codes
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
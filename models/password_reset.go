@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// PasswordReset is the stored form of a password reset token, only the hash of the token is kept
+type PasswordReset struct {
+	Hash      string    `json:"-" bson:"hash"`
+	Username  string    `json:"username" bson:"username"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "errors"
+
+// ErrVersionConflict is returned when a profile changed since the version an update was based on
+var ErrVersionConflict = errors.New("profile was changed by another request")
+
+// Profile is what a user sees and edits of their own account, Version changes with every profile update and is sent
+// as the ETag
+type Profile struct {
+	ID            string `json:"id"`
+	Username      string `json:"username"`
+	FirstName     string `json:"firstName"`
+	LastName      string `json:"lastName"`
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"emailVerified"`
+	Roles         []Role `json:"roles,omitempty"`
+	Version       int64  `json:"version"`
+}
+
+// Profile returns the user's profile
+func (u *User) Profile() *Profile {
+	return &Profile{
+		ID:            u.ID.Hex(),
+		Username:      u.Username,
+		FirstName:     u.FirstName,
+		LastName:      u.LastName,
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified,
+		Roles:         u.Roles,
+		Version:       u.Version,
+	}
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefreshToken is the stored form of a refresh token, only the hash of the token is kept. Tokens from before users had
+// an ID in their tokens only have the username, tokens issued through the token endpoint keep the client they were
+// issued to
+type RefreshToken struct {
+	UserID primitive.ObjectID `json:"-" bson:"userId,omitempty"`
+
+	Hash      string     `json:"-" bson:"hash"`
+	Family    string     `json:"family" bson:"family"`
+	Username  string     `json:"username" bson:"username"`
+	ClientID  string     `json:"clientId,omitempty" bson:"clientId,omitempty"`
+	Scope     string     `json:"scope,omitempty" bson:"scope,omitempty"`
+	CreatedAt time.Time  `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time  `json:"expiresAt" bson:"expiresAt"`
+	UsedAt    *time.Time `json:"usedAt,omitempty" bson:"usedAt,omitempty"`
+	Revoked   bool       `json:"revoked" bson:"revoked"`
+}
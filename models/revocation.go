@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Revocation records a revoked token by jti, or every token of a user issued before RevokedAt when there is no jti.
+// Session revocations are kept by the token subject, the user's ID, older ones only have the username
+type Revocation struct {
+	JTI       string    `json:"jti,omitempty" bson:"jti,omitempty"`
+	Subject   string    `json:"sub,omitempty" bson:"subject,omitempty"`
+	Username  string    `json:"username" bson:"username"`
+	RevokedAt time.Time `json:"revokedAt" bson:"revokedAt"`
+	ExpiresAt time.Time `json:"expiresAt" bson:"expiresAt"`
+}
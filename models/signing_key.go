@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// SigningKey is the stored form of a key used to sign tokens
+type SigningKey struct {
+	KeyID      string    `json:"kid" bson:"kid"`
+	Algorithm  string    `json:"alg" bson:"alg"`
+	PrivateKey string    `json:"-" bson:"privateKey,omitempty"`
+	Encrypted  bool      `json:"-" bson:"encrypted,omitempty"`
+	Status     string    `json:"status" bson:"status"`
+	CreatedAt  time.Time `json:"createdAt" bson:"createdAt"`
+	RetireAt   time.Time `json:"retireAt,omitempty" bson:"retireAt,omitempty"`
+}
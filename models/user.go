@@ -1,14 +1,71 @@
 package models
 
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ErrEmailTaken is returned when another user already has the email address
+var ErrEmailTaken = errors.New("Email already Exists")
+
 // User is the implementation of a user that would log in
 // swagger:model
 type User struct {
+	ID primitive.ObjectID `json:"-" bson:"_id,omitempty"`
+
 	Username  string `json:"username" bson:"username"`
 	FirstName string `json:"firstName" bson:"firstName"`
 	LastName  string `json:"lastName" bson:"lastName"`
 	Password  string `json:"password,omitempty" bson:"password"`
 	Token     string `json:"token,omitempty" bson:"token"`
 	Roles     []Role `json:"roles,omitempty" bson:"roles"`
+
+	Email         string `json:"email,omitempty" bson:"email,omitempty"`
+	EmailVerified bool   `json:"emailVerified" bson:"emailVerified,omitempty"`
+	Version       int64  `json:"-" bson:"version,omitempty"`
+
+	PasswordHistory []string   `json:"-" bson:"passwordHistory,omitempty"`
+	FailedLogins    int        `json:"-" bson:"failedLogins,omitempty"`
+	Lockouts        int        `json:"-" bson:"lockouts,omitempty"`
+	LockedUntil     *time.Time `json:"-" bson:"lockedUntil,omitempty"`
+	TOTP            *TOTP      `json:"-" bson:"totp,omitempty"`
+	DeletedAt       *time.Time `json:"-" bson:"deletedAt,omitempty"`
+	PurgeAt         *time.Time `json:"-" bson:"purgeAt,omitempty"`
+
+	WebAuthnID          string               `json:"-" bson:"webauthnId,omitempty"`
+	WebAuthnCredentials []WebAuthnCredential `json:"-" bson:"webauthnCredentials,omitempty"`
+}
+
+// TOTPEnabled is whether the user has confirmed an authenticator app
+func (u *User) TOTPEnabled() bool {
+	return u.TOTP != nil && u.TOTP.Confirmed
+}
+
+// MFAEnabled is whether the user has to give a second factor to log in, an authenticator app or a passkey
+func (u *User) MFAEnabled() bool {
+	return u.TOTPEnabled() || len(u.WebAuthnCredentials) > 0
+}
+
+// WebAuthnCredential finds one of the user's passkeys by its base64url credential ID
+func (u *User) WebAuthnCredential(id string) *WebAuthnCredential {
+	for i := range u.WebAuthnCredentials {
+		if u.WebAuthnCredentials[i].ID == id {
+			return &u.WebAuthnCredentials[i]
+		}
+	}
+
+	return nil
+}
+
+// LockState is whether a user is locked out after too many failed logins
+type LockState struct {
+	Username     string     `json:"username"`
+	Locked       bool       `json:"locked"`
+	LockedUntil  *time.Time `json:"lockedUntil,omitempty"`
+	FailedLogins int        `json:"failedLogins"`
+	Lockouts     int        `json:"lockouts"`
 }
 
 // Role is the implementation of roles that a user would have
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Errors for usernames that can not be taken
+var (
+	ErrUsernameTaken    = errors.New("Username already Exists")
+	ErrUsernameReserved = errors.New("Username is reserved")
+)
+
+// UsernameReservation keeps a username a user gave up from being taken by anyone else until it expires, so the new
+// owner of a name can not pass themselves off as the old one
+type UsernameReservation struct {
+	Username  string             `json:"username" bson:"username"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time          `json:"expiresAt" bson:"expiresAt"`
+}
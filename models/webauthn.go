@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// WebAuthn ceremonies a challenge can be used for
+const (
+	WebAuthnRegistration = "registration"
+	WebAuthnLogin        = "login"
+	WebAuthnMFA          = "mfa"
+)
+
+// WebAuthnCredential is a passkey or security key registered by a user, the ID is the base64url credential ID
+type WebAuthnCredential struct {
+	ID         string     `json:"id" bson:"id"`
+	Name       string     `json:"name" bson:"name"`
+	PublicKey  []byte     `json:"-" bson:"publicKey"`
+	Algorithm  int64      `json:"algorithm" bson:"algorithm"`
+	SignCount  uint32     `json:"-" bson:"signCount"`
+	AAGUID     []byte     `json:"-" bson:"aaguid,omitempty"`
+	Transports []string   `json:"transports,omitempty" bson:"transports,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt" bson:"createdAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty" bson:"lastUsedAt,omitempty"`
+}
+
+// WebAuthnSession is a challenge handed out for one ceremony, a challenge for a second factor is tied to the interim
+// token of the login by its hash
+type WebAuthnSession struct {
+	Challenge    string    `json:"-" bson:"challenge"`
+	Ceremony     string    `json:"ceremony" bson:"ceremony"`
+	Username     string    `json:"username,omitempty" bson:"username,omitempty"`
+	MFAChallenge string    `json:"-" bson:"mfaChallenge,omitempty"`
+	ExpiresAt    time.Time `json:"expiresAt" bson:"expiresAt"`
+}
@@ -0,0 +1,56 @@
+// Package audit records security relevant events, such as deleted accounts, through a pluggable Recorder.
+package audit
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Event types
+const (
+	UserDeleted  = "user.deleted"
+	UserRestored = "user.restored"
+	UserRenamed  = "user.renamed"
+)
+
+// Event is something that happened to an account, the actor is who did it and the subject who it was done to
+type Event struct {
+	Type    string            `json:"type"`
+	Actor   string            `json:"actor"`
+	Subject string            `json:"subject"`
+	Time    time.Time         `json:"time"`
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// Recorder keeps events
+type Recorder interface {
+	Record(event *Event) error
+}
+
+// LogRecorder writes each event as a log entry with the event in its fields, a nil Logger writes to the standard
+// logger
+type LogRecorder struct {
+	Logger *logrus.Logger
+}
+
+// Record logs the event at info level so it is kept at every log level but warn and error
+func (l *LogRecorder) Record(event *Event) error {
+	logger := l.Logger
+	if logger == nil {
+		logger = logrus.StandardLogger()
+	}
+
+	fields := logrus.Fields{
+		"audit":   event.Type,
+		"actor":   event.Actor,
+		"subject": event.Subject,
+		"at":      event.Time.UTC().Format(time.RFC3339),
+	}
+	for key, value := range event.Details {
+		fields[key] = value
+	}
+	logger.WithFields(fields).Info("AUDIT " + event.Type)
+
+	return nil
+}
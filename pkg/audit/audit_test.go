@@ -0,0 +1,49 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestLogRecorder(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := logrus.New()
+	logger.SetOutput(&buffer)
+	logger.SetFormatter(&logrus.JSONFormatter{})
+
+	recorder := &LogRecorder{Logger: logger}
+	err := recorder.Record(&Event{
+		Type:    UserDeleted,
+		Actor:   "admin",
+		Subject: "user",
+		Time:    time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC),
+		Details: map[string]string{"mode": "soft"},
+	})
+	if err != nil {
+		t.Fatalf("Record() returned error: %v", err)
+	}
+
+	var entry map[string]string
+	err = json.Unmarshal(buffer.Bytes(), &entry)
+	if err != nil {
+		t.Fatalf("Record() wrote %q: %v", buffer.String(), err)
+	}
+
+	expected := map[string]string{
+		"audit":   "user.deleted",
+		"actor":   "admin",
+		"subject": "user",
+		"at":      "2022-09-01T12:00:00Z",
+		"mode":    "soft",
+		"msg":     "AUDIT user.deleted",
+	}
+	for key, value := range expected {
+		if entry[key] != value {
+			t.Errorf("Record() %s error:\n   expected: %q\n   got:      %q", key, value, entry[key])
+		}
+	}
+}
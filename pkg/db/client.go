@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/geeksheik9/login-service/config"
+	"github.com/geeksheik9/login-service/pkg/password"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
@@ -34,14 +35,69 @@ func InitializeClients(ctx context.Context) (*mongo.Client, error) {
 }
 
 // InitializeDatabases Factory for the dao implementation. Returns a dao connected to the designated MongoDB database for DB operations.
-// The database connection is made using configuration in the config.go file
-func InitializeDatabases(client *mongo.Client, config *config.Config) *UserDB {
+// The database connection is made using configuration in the config.go file, passwords are hashed by the hasher passed
+func InitializeDatabases(client *mongo.Client, config *config.Config, hasher password.Hasher) *UserDB {
 
 	database := &UserDB{
-		client:         client,
-		databaseName:   config.UserDatabase,
-		userCollection: config.UserCollection,
-		roleCollection: config.RoleCollection,
+		client:                      client,
+		databaseName:                config.UserDatabase,
+		userCollection:              config.UserCollection,
+		roleCollection:              config.RoleCollection,
+		refreshTokenCollection:      config.RefreshTokenCollection,
+		authorizationCodeCollection: config.AuthorizationCodeCollection,
+		clientCollection:            config.ClientCollection,
+		deviceCodeCollection:        config.DeviceCodeCollection,
+		passwordResetCollection:     config.PasswordResetCollection,
+		mfaChallengeCollection:      config.MFAChallengeCollection,
+		webauthnSessionCollection:   config.WebAuthnChallengeCollection,
+		magicLinkCollection:         config.MagicLinkCollection,
+		reservationCollection:       config.ReservedUsernameCollection,
+		passwordHistory:             config.PasswordHistory,
+		lockoutThreshold:            config.LockoutThreshold,
+		lockoutDurationBase:         config.LockoutDuration,
+		lockoutDurationMax:          config.LockoutMaxDuration,
+		deletionPurgeDelay:          config.UserPurgeDelay,
+		reservationPeriod:           config.ReservedUsernamePeriod,
+		hasher:                      hasher,
+	}
+
+	return database
+}
+
+// InitializeKeyStore returns the dao holding signing keys, it is separate from UserDB because the keys must be loaded
+// before tokens can be signed
+func InitializeKeyStore(client *mongo.Client, config *config.Config) *KeyDB {
+
+	database := &KeyDB{
+		client:        client,
+		databaseName:  config.UserDatabase,
+		keyCollection: config.KeyCollection,
+	}
+
+	return database
+}
+
+// InitializeRevocationStore returns the dao holding revoked tokens, it is separate from UserDB because revocations
+// are checked while validating tokens
+func InitializeRevocationStore(client *mongo.Client, config *config.Config) *RevocationDB {
+
+	database := &RevocationDB{
+		client:               client,
+		databaseName:         config.UserDatabase,
+		revocationCollection: config.RevocationCollection,
+	}
+
+	return database
+}
+
+// InitializeRateLimitStore returns the dao holding rate limit buckets, it is used instead of the memory store when
+// several replicas have to share the limits
+func InitializeRateLimitStore(client *mongo.Client, config *config.Config) *RateLimitDB {
+
+	database := &RateLimitDB{
+		client:              client,
+		databaseName:        config.UserDatabase,
+		rateLimitCollection: config.RateLimitCollection,
 	}
 
 	return database
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/password"
+	"github.com/geeksheik9/login-service/pkg/token"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateClient hashes the client's secret and inserts the client, secrets are random so they are hashed like opaque
+// tokens
+func (u *UserDB) CreateClient(client *models.Client) error {
+	logrus.Debug("BEGIN - CreateClient")
+
+	collection := u.client.Database(u.databaseName).Collection(u.clientCollection)
+
+	if client.Secret != "" {
+		client.SecretHash = token.HashOpaqueToken(client.Secret)
+	}
+
+	_, err := collection.InsertOne(context.Background(), client)
+
+	return err
+}
+
+// GetClient finds a client by its client ID
+func (u *UserDB) GetClient(clientID string) (*models.Client, error) {
+	logrus.Debug("BEGIN - GetClient")
+
+	collection := u.client.Database(u.databaseName).Collection(u.clientCollection)
+
+	var result models.Client
+	err := collection.FindOne(context.Background(), bson.M{"clientId": clientID}).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// GetClients returns the registered clients
+func (u *UserDB) GetClients(queryParams url.Values) ([]models.Client, error) {
+	logrus.Debug("BEGIN - GetClients")
+
+	collection := u.client.Database(u.databaseName).Collection(u.clientCollection)
+
+	pageNumber, pageCount, _, filter := api.BuildFilter(queryParams)
+	skip := 0
+	if pageNumber > 0 {
+		skip = (pageNumber - 1) * pageCount
+	}
+
+	opts := options.Find().
+		SetMaxTime(30 * time.Second).
+		SetSkip(int64(skip)).
+		SetLimit(int64(pageCount)).
+		SetSort(bson.D{{
+			Key:   "clientId",
+			Value: 1,
+		}})
+
+	if filter == nil {
+		filter = bson.M{}
+	}
+
+	cur, err := collection.Find(context.Background(), filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	clients := []models.Client{}
+	err = cur.All(context.Background(), &clients)
+	if err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// UpdateClient changes the client's name, scopes, grant types and redirect URIs, its ID and secret stay as they are
+func (u *UserDB) UpdateClient(client *models.Client) error {
+	logrus.Debug("BEGIN - UpdateClient")
+
+	collection := u.client.Database(u.databaseName).Collection(u.clientCollection)
+
+	result, err := collection.UpdateOne(context.Background(), bson.M{"clientId": client.ClientID}, bson.M{"$set": bson.M{
+		"name":         client.Name,
+		"scopes":       client.Scopes,
+		"grantTypes":   client.GrantTypes,
+		"redirectUris": client.RedirectURIs,
+	}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("client not found")
+	}
+
+	return nil
+}
+
+// DeleteClient removes a client
+func (u *UserDB) DeleteClient(clientID string) error {
+	logrus.Debug("BEGIN - DeleteClient")
+
+	collection := u.client.Database(u.databaseName).Collection(u.clientCollection)
+
+	result, err := collection.DeleteOne(context.Background(), bson.M{"clientId": clientID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("client not found")
+	}
+
+	return nil
+}
+
+// AuthenticateClient checks the secret against the stored hash and returns the client
+func (u *UserDB) AuthenticateClient(clientID string, secret string) (*models.Client, error) {
+	client, err := u.GetClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if client.Public || client.SecretHash == "" {
+		return nil, errors.New("client has no secret")
+	}
+
+	if !checkClientSecret(client.SecretHash, secret) {
+		return nil, errors.New("incorrect client secret")
+	}
+
+	// Secrets of clients registered before they were hashed like opaque tokens are bcrypt hashes, they are replaced on
+	// the first use
+	if password.Identify(client.SecretHash) != "" {
+		u.rehashClientSecret(client.ClientID, client.SecretHash, secret)
+	}
+
+	return client, nil
+}
+
+// checkClientSecret compares the secret with the stored hash in constant time, older bcrypt hashes are still accepted
+func checkClientSecret(hash string, secret string) bool {
+	if password.Identify(hash) != "" {
+		return password.Verify(secret, hash) == nil
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token.HashOpaqueToken(secret)), []byte(hash)) == 1
+}
+
+// rehashClientSecret stores the opaque token hash of a secret in place of its bcrypt hash
+func (u *UserDB) rehashClientSecret(clientID string, oldHash string, secret string) {
+	collection := u.client.Database(u.databaseName).Collection(u.clientCollection)
+
+	filter := bson.M{"clientId": clientID, "secretHash": oldHash}
+	_, err := collection.UpdateOne(context.Background(), filter, bson.M{"$set": bson.M{"secretHash": token.HashOpaqueToken(secret)}})
+	if err != nil {
+		logrus.Warnf("Error rehashing secret of client %s: %v", clientID, err)
+	}
+}
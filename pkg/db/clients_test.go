@@ -0,0 +1,31 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/geeksheik9/login-service/pkg/password"
+	"github.com/geeksheik9/login-service/pkg/token"
+)
+
+func TestCheckClientSecret(t *testing.T) {
+	hasher, _ := password.NewBcryptHasher(4)
+	legacy, _ := hasher.Hash("secret")
+
+	tests := []struct {
+		name     string
+		hash     string
+		secret   string
+		expected bool
+	}{
+		{"opaque hash", token.HashOpaqueToken("secret"), "secret", true},
+		{"wrong secret", token.HashOpaqueToken("secret"), "guess", false},
+		{"empty secret", token.HashOpaqueToken("secret"), "", false},
+		{"bcrypt hash", legacy, "secret", true},
+		{"wrong secret for bcrypt hash", legacy, "guess", false},
+	}
+	for _, test := range tests {
+		if ok := checkClientSecret(test.hash, test.secret); ok != test.expected {
+			t.Errorf("checkClientSecret() %s error:\n   expected: %v\n   got:      %v", test.name, test.expected, ok)
+		}
+	}
+}
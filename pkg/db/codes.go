@@ -0,0 +1,36 @@
+package db
+
+import (
+	"context"
+
+	"github.com/geeksheik9/login-service/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CreateAuthorizationCode stores a newly issued authorization code
+func (u *UserDB) CreateAuthorizationCode(code *models.AuthorizationCode) error {
+	logrus.Debug("BEGIN - CreateAuthorizationCode")
+
+	collection := u.client.Database(u.databaseName).Collection(u.authorizationCodeCollection)
+
+	_, err := collection.InsertOne(context.Background(), code)
+
+	return err
+}
+
+// ConsumeAuthorizationCode finds and deletes an authorization code in one step so a code can only be redeemed once
+func (u *UserDB) ConsumeAuthorizationCode(hash string) (*models.AuthorizationCode, error) {
+	logrus.Debug("BEGIN - ConsumeAuthorizationCode")
+
+	collection := u.client.Database(u.databaseName).Collection(u.authorizationCodeCollection)
+
+	var result models.AuthorizationCode
+	err := collection.FindOneAndDelete(context.Background(), bson.M{"hash": hash}).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
@@ -6,24 +6,47 @@ import (
 	"net/url"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/geeksheik9/login-service/models"
 	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/password"
 
 	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // UserDB is the data access object for user login
 type UserDB struct {
-	client         *mongo.Client
-	databaseName   string
-	userCollection string
-	roleCollection string
+	client                      *mongo.Client
+	databaseName                string
+	userCollection              string
+	roleCollection              string
+	refreshTokenCollection      string
+	authorizationCodeCollection string
+	clientCollection            string
+	deviceCodeCollection        string
+	passwordResetCollection     string
+	mfaChallengeCollection      string
+	webauthnSessionCollection   string
+	magicLinkCollection         string
+	reservationCollection       string
+	passwordHistory             int
+	lockoutThreshold            int
+	lockoutDurationBase         time.Duration
+	lockoutDurationMax          time.Duration
+	deletionPurgeDelay          time.Duration
+	reservationPeriod           time.Duration
+	hasher                      password.Hasher
+}
+
+// KeyDB is the data access object for token signing keys
+type KeyDB struct {
+	client        *mongo.Client
+	databaseName  string
+	keyCollection string
 }
 
 // Ping checks that the database is running
@@ -43,56 +66,201 @@ func (u *UserDB) RegisterUser(user *models.User) error {
 	err := collection.FindOne(context.TODO(), bson.M{"username": user.Username}).Decode(&result)
 	if err != nil {
 		if err.Error() == "mongo: no documents in result" {
-			hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), 5)
+			// The password is hashed first so a taken email address takes as long to answer as a registration
+			hash, err := u.hasher.Hash(user.Password)
 			if err != nil {
 				return err
 			}
-			user.Password = string(hash)
 
-			_, err = collection.InsertOne(context.TODO(), user)
+			err = u.checkEmailAvailable(collection, user.Email)
+			if err != nil {
+				return err
+			}
+			err = u.checkNotReserved(user.Username, primitive.NilObjectID)
 			if err != nil {
 				return err
 			}
+			user.Password = hash
+
+			res, err := collection.InsertOne(context.TODO(), user)
+			if err != nil {
+				return err
+			}
+			// The ID is the token subject, the caller needs it to send the verification email
+			user.ID = res.InsertedID.(primitive.ObjectID)
 			return nil
 		}
 		return err
 	}
 
-	err = errors.New("Username already Exists")
-	return err
+	return models.ErrUsernameTaken
 }
 
-// LoginUser is the implementation to login a user in the database
-func (u *UserDB) LoginUser(user *models.User) (string, error) {
+// GetUser finds a user by username, the password hash is never returned
+func (u *UserDB) GetUser(username string) (*models.User, error) {
 	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
 
 	var result models.User
-	err := collection.FindOne(context.TODO(), bson.M{"username": user.Username}).Decode(&result)
+	err := collection.FindOne(context.TODO(), activeUser(bson.M{"username": username})).Decode(&result)
 	if err != nil {
-		return result.Token, err
+		return nil, err
 	}
+	result.Password = ""
+	result.PasswordHistory = nil
+
+	return &result, nil
+}
 
-	err = bcrypt.CompareHashAndPassword([]byte(result.Password), []byte(user.Password))
+// GetUserByID finds a user by the immutable ID tokens carry as their subject, the password hash is never returned
+func (u *UserDB) GetUserByID(userID string) (*models.User, error) {
+	id, err := api.StringToObjectID(userID)
 	if err != nil {
-		return result.Token, err
+		return nil, mongo.ErrNoDocuments
+	}
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	var result models.User
+	err = collection.FindOne(context.TODO(), activeUser(bson.M{"_id": id})).Decode(&result)
+	if err != nil {
+		return nil, err
 	}
+	result.Password = ""
+	result.PasswordHistory = nil
+
+	return &result, nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username":  result.Username,
-		"firstname": result.FirstName,
-		"lastname":  result.LastName,
-		"roles":     result.Roles,
-	})
+// AuthenticateUser checks the password against the stored hash and returns the stored user without the hash.
+// Failed logins are counted and lock the account once there are too many in a row
+func (u *UserDB) AuthenticateUser(user *models.User) (*models.User, error) {
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
 
-	tokenString, err := token.SignedString([]byte("secret"))
+	var result models.User
+	err := collection.FindOne(context.TODO(), activeUser(bson.M{"username": user.Username})).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		_ = password.Verify(user.Password, u.dummyHash())
+		return nil, password.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// A locked account is refused even with the right password, the hash is still checked so it takes as long
+	err = password.Verify(user.Password, result.Password)
+	if isLocked(&result) {
+		return nil, password.ErrInvalidCredentials
+	}
 	if err != nil {
-		return result.Token, err
+		u.recordFailedLogin(collection, result.Username)
+		return nil, password.ErrInvalidCredentials
+	}
+	u.resetFailedLogins(collection, &result)
+
+	// Hashes made with an older algorithm or weaker parameters are replaced now that the password is known
+	if u.hasher.NeedsRehash(result.Password) {
+		u.rehashPassword(collection, result.Username, result.Password, user.Password)
 	}
 
-	result.Token = tokenString
 	result.Password = ""
+	result.PasswordHistory = nil
+
+	return &result, nil
+}
+
+// rehashPassword stores a new hash of the password, the old hash is part of the filter so a password changed in the
+// meantime is not overwritten. Failures are only logged, the user can still log in with the old hash
+func (u *UserDB) rehashPassword(collection *mongo.Collection, username string, oldHash string, plaintext string) {
+	hash, err := u.hasher.Hash(plaintext)
+	if err != nil {
+		logrus.Warnf("Error rehashing password for %s: %v", username, err)
+		return
+	}
+
+	filter := bson.M{"username": username, "password": oldHash}
+	_, err = collection.UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"password": hash}})
+	if err != nil {
+		logrus.Warnf("Error rehashing password for %s: %v", username, err)
+	}
+}
+
+// CheckPasswordHistory returns password.ErrPasswordReused when the password is the current one or one of the
+// user's recent passwords
+func (u *UserDB) CheckPasswordHistory(username string, newPassword string) error {
+	logrus.Debug("BEGIN - CheckPasswordHistory")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	var result models.User
+	err := collection.FindOne(context.Background(), activeUser(bson.M{"username": username})).Decode(&result)
+	if err != nil {
+		return err
+	}
+
+	return u.checkPasswordHistory(&result, newPassword)
+}
+
+func (u *UserDB) checkPasswordHistory(user *models.User, newPassword string) error {
+	if u.passwordHistory < 1 {
+		return nil
+	}
+
+	if password.Reused(newPassword, recentPasswords(user, u.passwordHistory)...) {
+		return password.ErrPasswordReused
+	}
+
+	return nil
+}
+
+// recentPasswords is the current hash followed by the newest hashes of the history, n in total
+func recentPasswords(user *models.User, n int) []string {
+	hashes := append([]string{user.Password}, user.PasswordHistory...)
+	if len(hashes) > n {
+		hashes = hashes[:n]
+	}
+
+	return hashes
+}
+
+// ChangePassword stores a hash of the new password for the user, the old hash goes to the front of the password
+// history which keeps the configured number of passwords including the current one
+func (u *UserDB) ChangePassword(username string, newPassword string) error {
+	logrus.Debug("BEGIN - ChangePassword")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	var result models.User
+	err := collection.FindOne(context.Background(), activeUser(bson.M{"username": username})).Decode(&result)
+	if err != nil {
+		return err
+	}
+
+	err = u.checkPasswordHistory(&result, newPassword)
+	if err != nil {
+		return err
+	}
+
+	hash, err := u.hasher.Hash(newPassword)
+	if err != nil {
+		return err
+	}
+
+	update := bson.M{"password": hash, "passwordHistory": []string{}}
+	if u.passwordHistory > 1 {
+		update["passwordHistory"] = recentPasswords(&result, u.passwordHistory-1)
+	}
+
+	// Filtering on the old hash keeps two changes at the same time from both passing the history check
+	filter := activeUser(bson.M{"username": username, "password": result.Password})
+	updated, err := collection.UpdateOne(context.Background(), filter, bson.M{"$set": update})
+	if err != nil {
+		return err
+	}
+	if updated.MatchedCount == 0 {
+		return errors.New("user not found")
+	}
 
-	return result.Token, nil
+	return nil
 }
 
 // CreateRole inserts role into the role collection
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// activeUser adds the condition that the user is not deleted to a filter, soft deleted users can not be found or log
+// in until they are restored
+func activeUser(filter bson.M) bson.M {
+	filter["deletedAt"] = bson.M{"$exists": false}
+
+	return filter
+}
+
+// DeleteUser deletes a user and returns when the user will be purged. With a purge delay the user is only marked
+// deleted and the TTL index on purgeAt removes the document once the delay is over, the username and email stay taken
+// until then. Without a delay, or with purge, the user is removed at once and nil is returned. Either way the user's
+// pending emails, second factor challenges and passkey ceremonies are removed now
+func (u *UserDB) DeleteUser(username string, purge bool) (*time.Time, error) {
+	logrus.Debug("BEGIN - DeleteUser")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	var purgeAt *time.Time
+	if u.softDeletes(purge) {
+		now := time.Now().UTC()
+		at := now.Add(u.deletionPurgeDelay)
+		update := bson.M{"$set": bson.M{"deletedAt": now, "purgeAt": at}}
+		result, err := collection.UpdateOne(context.Background(), activeUser(bson.M{"username": username}), update)
+		if err != nil {
+			return nil, err
+		}
+		if result.MatchedCount == 0 {
+			return nil, mongo.ErrNoDocuments
+		}
+		purgeAt = &at
+	} else {
+		result, err := collection.DeleteOne(context.Background(), bson.M{"username": username})
+		if err != nil {
+			return nil, err
+		}
+		if result.DeletedCount == 0 {
+			return nil, mongo.ErrNoDocuments
+		}
+	}
+
+	return purgeAt, u.deletePending(username)
+}
+
+// deletePending removes the user's pending emails, second factor challenges, passkey ceremonies, device codes and
+// authorization codes, which are all looked up by username
+func (u *UserDB) deletePending(username string) error {
+	collections := []string{u.passwordResetCollection, u.magicLinkCollection, u.mfaChallengeCollection,
+		u.webauthnSessionCollection, u.deviceCodeCollection, u.authorizationCodeCollection}
+	for _, name := range collections {
+		_, err := u.client.Database(u.databaseName).Collection(name).DeleteMany(context.Background(), bson.M{"username": username})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// softDeletes is whether a delete only marks the user deleted, deletes are soft while there is a purge delay unless the
+// user is purged
+func (u *UserDB) softDeletes(purge bool) bool {
+	return u.deletionPurgeDelay > 0 && !purge
+}
+
+// RestoreUser undoes a soft delete that has not been purged yet, sessions revoked by the delete stay revoked
+func (u *UserDB) RestoreUser(username string) error {
+	logrus.Debug("BEGIN - RestoreUser")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	filter := bson.M{"username": username, "deletedAt": bson.M{"$exists": true}}
+	result, err := collection.UpdateOne(context.Background(), filter, bson.M{"$unset": bson.M{"deletedAt": "", "purgeAt": ""}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestSoftDeletes(t *testing.T) {
+	tests := []struct {
+		delay    time.Duration
+		purge    bool
+		expected bool
+	}{
+		{0, false, false},
+		{0, true, false},
+		{time.Hour, false, true},
+		{time.Hour, true, false},
+	}
+	for _, test := range tests {
+		u := &UserDB{deletionPurgeDelay: test.delay}
+		if soft := u.softDeletes(test.purge); soft != test.expected {
+			t.Errorf("softDeletes(%v) with delay %v error:\n   expected: %v\n   got:      %v", test.purge, test.delay, test.expected, soft)
+		}
+	}
+}
+
+func TestActiveUser(t *testing.T) {
+	filter := activeUser(bson.M{"username": "alice"})
+
+	deletedAt, ok := filter["deletedAt"].(bson.M)
+	if filter["username"] != "alice" || !ok || deletedAt["$exists"] != false {
+		t.Errorf("activeUser() error:\n   expected: username alice and no deletedAt\n   got:      %v", filter)
+	}
+}
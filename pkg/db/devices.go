@@ -0,0 +1,84 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateDeviceCode stores a pending device authorization, the collection's TTL index removes it once it expires
+func (u *UserDB) CreateDeviceCode(code *models.DeviceCode) error {
+	logrus.Debug("BEGIN - CreateDeviceCode")
+
+	collection := u.client.Database(u.databaseName).Collection(u.deviceCodeCollection)
+
+	_, err := collection.InsertOne(context.Background(), code)
+
+	return err
+}
+
+// DecideDeviceCode approves or denies a pending, unexpired device authorization by its user code
+func (u *UserDB) DecideDeviceCode(userCode string, username string, status string) error {
+	logrus.Debug("BEGIN - DecideDeviceCode")
+
+	collection := u.client.Database(u.databaseName).Collection(u.deviceCodeCollection)
+
+	filter := bson.M{
+		"userCode":  userCode,
+		"status":    models.DeviceCodePending,
+		"expiresAt": bson.M{"$gt": time.Now().UTC()},
+	}
+	update := bson.M{"$set": bson.M{"status": status, "username": username}}
+
+	result, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("device code not found")
+	}
+
+	return nil
+}
+
+// PollDeviceCode records a poll and returns the device authorization as it was before, so the caller can see how
+// long ago the device last polled
+func (u *UserDB) PollDeviceCode(hash string) (*models.DeviceCode, error) {
+	logrus.Debug("BEGIN - PollDeviceCode")
+
+	collection := u.client.Database(u.databaseName).Collection(u.deviceCodeCollection)
+
+	var result models.DeviceCode
+	update := bson.M{"$set": bson.M{"lastPolledAt": time.Now().UTC()}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.Before)
+	err := collection.FindOneAndUpdate(context.Background(), bson.M{"hash": hash}, update, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// DeleteDeviceCode removes a device authorization once it has been decided, an approved code must be deleted by the
+// poll that issues tokens for it so tokens are only issued once
+func (u *UserDB) DeleteDeviceCode(hash string, status string) error {
+	logrus.Debug("BEGIN - DeleteDeviceCode")
+
+	collection := u.client.Database(u.databaseName).Collection(u.deviceCodeCollection)
+
+	result, err := collection.DeleteOne(context.Background(), bson.M{"hash": hash, "status": status})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("device code not found")
+	}
+
+	return nil
+}
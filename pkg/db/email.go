@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// checkEmailAvailable refuses an address another user already has, the unique index catches registrations racing
+// each other
+func (u *UserDB) checkEmailAvailable(collection *mongo.Collection, email string) error {
+	if email == "" {
+		return nil
+	}
+
+	count, err := collection.CountDocuments(context.TODO(), bson.M{"email": email})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return models.ErrEmailTaken
+	}
+
+	return nil
+}
+
+// GetUserByEmail finds a user by their normalized email address, the password hash is never returned
+func (u *UserDB) GetUserByEmail(email string) (*models.User, error) {
+	logrus.Debug("BEGIN - GetUserByEmail")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	var result models.User
+	err := collection.FindOne(context.TODO(), activeUser(bson.M{"email": email})).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	result.Password = ""
+	result.PasswordHistory = nil
+
+	return &result, nil
+}
+
+// VerifyEmail marks the address of the user with the ID as verified, the address is part of the filter so a
+// verification of an address the user no longer has does nothing
+func (u *UserDB) VerifyEmail(userID string, email string) error {
+	logrus.Debug("BEGIN - VerifyEmail")
+
+	id, err := api.StringToObjectID(userID)
+	if err != nil {
+		return err
+	}
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	filter := bson.M{"_id": id, "email": email}
+	result, err := collection.UpdateOne(context.TODO(), filter, bson.M{"$set": bson.M{"emailVerified": true}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
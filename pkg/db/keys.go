@@ -0,0 +1,43 @@
+package db
+
+import (
+	"context"
+
+	"github.com/geeksheik9/login-service/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetSigningKeys returns every signing key in the key collection
+func (k *KeyDB) GetSigningKeys() ([]models.SigningKey, error) {
+	logrus.Debug("BEGIN - GetSigningKeys")
+
+	collection := k.client.Database(k.databaseName).Collection(k.keyCollection)
+
+	cur, err := collection.Find(context.Background(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var keys []models.SigningKey
+	err = cur.All(context.Background(), &keys)
+	if err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// SaveSigningKey inserts the signing key or replaces the stored key with the same kid
+func (k *KeyDB) SaveSigningKey(key *models.SigningKey) error {
+	logrus.Debug("BEGIN - SaveSigningKey")
+
+	collection := k.client.Database(k.databaseName).Collection(k.keyCollection)
+
+	_, err := collection.ReplaceOne(context.Background(), bson.M{"kid": key.KeyID}, key, options.Replace().SetUpsert(true))
+
+	return err
+}
@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// dummyHash is verified against when the user does not exist, so unknown usernames take as long as wrong passwords
+var dummy struct {
+	once sync.Once
+	hash string
+}
+
+func (u *UserDB) dummyHash() string {
+	dummy.once.Do(func() {
+		dummy.hash, _ = u.hasher.Hash("not the password of any user")
+	})
+
+	return dummy.hash
+}
+
+// lockoutDuration doubles with every lockout in a row, starting from the configured duration up to the maximum
+func (u *UserDB) lockoutDuration(lockouts int) time.Duration {
+	duration := u.lockoutDurationBase
+	for i := 1; i < lockouts && duration < u.lockoutDurationMax; i++ {
+		duration *= 2
+	}
+	if duration > u.lockoutDurationMax {
+		duration = u.lockoutDurationMax
+	}
+
+	return duration
+}
+
+// nextLockout is how long a user with the failed logins in a row and earlier lockouts is locked out, false while they
+// are under the threshold or lockouts are turned off
+func (u *UserDB) nextLockout(failedLogins int, lockouts int) (time.Duration, bool) {
+	if u.lockoutThreshold < 1 || failedLogins < u.lockoutThreshold {
+		return 0, false
+	}
+
+	return u.lockoutDuration(lockouts + 1), true
+}
+
+// recordFailedLogin counts a failed login and locks the account once the threshold is reached
+func (u *UserDB) recordFailedLogin(collection *mongo.Collection, username string) {
+	if u.lockoutThreshold < 1 {
+		return
+	}
+
+	var result models.User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	update := bson.M{"$inc": bson.M{"failedLogins": 1}}
+	err := collection.FindOneAndUpdate(context.Background(), bson.M{"username": username}, update, opts).Decode(&result)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			logrus.Warnf("Error recording failed login for %s: %v", username, err)
+		}
+		return
+	}
+	duration, lock := u.nextLockout(result.FailedLogins, result.Lockouts)
+	if !lock {
+		return
+	}
+
+	lockouts := result.Lockouts + 1
+	lockedUntil := time.Now().UTC().Add(duration)
+	filter := bson.M{"username": username, "failedLogins": result.FailedLogins}
+	update = bson.M{"$set": bson.M{"failedLogins": 0, "lockouts": lockouts, "lockedUntil": lockedUntil}}
+	_, err = collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		logrus.Warnf("Error locking %s: %v", username, err)
+		return
+	}
+
+	logrus.Warnf("Locked %s until %v after %d failed logins", username, lockedUntil, result.FailedLogins)
+}
+
+// resetFailedLogins clears the failed login count after a successful login
+func (u *UserDB) resetFailedLogins(collection *mongo.Collection, user *models.User) {
+	if user.FailedLogins == 0 && user.Lockouts == 0 && user.LockedUntil == nil {
+		return
+	}
+
+	update := bson.M{"$unset": bson.M{"failedLogins": "", "lockouts": "", "lockedUntil": ""}}
+	_, err := collection.UpdateOne(context.Background(), bson.M{"username": user.Username}, update)
+	if err != nil {
+		logrus.Warnf("Error resetting failed logins for %s: %v", user.Username, err)
+	}
+}
+
+// GetLockState returns whether a user is locked out and how many failed logins they have
+func (u *UserDB) GetLockState(username string) (*models.LockState, error) {
+	logrus.Debug("BEGIN - GetLockState")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	var result models.User
+	err := collection.FindOne(context.Background(), bson.M{"username": username}).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &models.LockState{
+		Username:     result.Username,
+		FailedLogins: result.FailedLogins,
+		Lockouts:     result.Lockouts,
+	}
+	if result.LockedUntil != nil && result.LockedUntil.After(time.Now()) {
+		state.Locked = true
+		state.LockedUntil = result.LockedUntil
+	}
+
+	return state, nil
+}
+
+// UnlockUser clears a user's lockout and failed logins
+func (u *UserDB) UnlockUser(username string) error {
+	logrus.Debug("BEGIN - UnlockUser")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	update := bson.M{"$unset": bson.M{"failedLogins": "", "lockouts": "", "lockedUntil": ""}}
+	result, err := collection.UpdateOne(context.Background(), bson.M{"username": username}, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// isLocked reports whether the user is locked out right now
+func isLocked(user *models.User) bool {
+	return user.LockedUntil != nil && user.LockedUntil.After(time.Now())
+}
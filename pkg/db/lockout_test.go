@@ -0,0 +1,76 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+)
+
+func TestLockoutDuration(t *testing.T) {
+	u := &UserDB{lockoutDurationBase: time.Minute, lockoutDurationMax: 10 * time.Minute}
+
+	tests := []struct {
+		lockouts int
+		expected time.Duration
+	}{
+		{1, time.Minute},
+		{2, 2 * time.Minute},
+		{3, 4 * time.Minute},
+		{4, 8 * time.Minute},
+		{5, 10 * time.Minute},
+		{50, 10 * time.Minute},
+	}
+	for _, test := range tests {
+		if duration := u.lockoutDuration(test.lockouts); duration != test.expected {
+			t.Errorf("lockoutDuration(%d) error:\n   expected: %v\n   got:      %v", test.lockouts, test.expected, duration)
+		}
+	}
+}
+
+func TestNextLockout(t *testing.T) {
+	u := &UserDB{lockoutThreshold: 5, lockoutDurationBase: time.Minute, lockoutDurationMax: time.Hour}
+
+	tests := []struct {
+		failedLogins int
+		lockouts     int
+		duration     time.Duration
+		lock         bool
+	}{
+		{4, 0, 0, false},
+		{5, 0, time.Minute, true},
+		{5, 1, 2 * time.Minute, true},
+		{5, 3, 8 * time.Minute, true},
+	}
+	for _, test := range tests {
+		duration, lock := u.nextLockout(test.failedLogins, test.lockouts)
+		if duration != test.duration || lock != test.lock {
+			t.Errorf("nextLockout(%d, %d) error:\n   expected: %v %v\n   got:      %v %v", test.failedLogins, test.lockouts,
+				test.duration, test.lock, duration, lock)
+		}
+	}
+
+	u.lockoutThreshold = 0
+	if _, lock := u.nextLockout(100, 0); lock {
+		t.Errorf("nextLockout() error:\n   expected: no lockout when the threshold is 0\n   got:      %v", lock)
+	}
+}
+
+func TestIsLocked(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		user     *models.User
+		expected bool
+	}{
+		{&models.User{}, false},
+		{&models.User{LockedUntil: &past}, false},
+		{&models.User{LockedUntil: &future}, true},
+	}
+	for _, test := range tests {
+		if locked := isLocked(test.user); locked != test.expected {
+			t.Errorf("isLocked(%v) error:\n   expected: %v\n   got:      %v", test.user.LockedUntil, test.expected, locked)
+		}
+	}
+}
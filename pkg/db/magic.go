@@ -0,0 +1,67 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CreateMagicLink stores a passwordless login, any earlier link or code of the user stops working
+func (u *UserDB) CreateMagicLink(link *models.MagicLink) error {
+	logrus.Debug("BEGIN - CreateMagicLink")
+
+	collection := u.client.Database(u.databaseName).Collection(u.magicLinkCollection)
+
+	_, err := collection.DeleteMany(context.Background(), bson.M{"username": link.Username})
+	if err != nil {
+		return err
+	}
+
+	_, err = collection.InsertOne(context.Background(), link)
+
+	return err
+}
+
+// AttemptMagicCode counts an attempt at the user's unexpired emailed code and returns the login it belongs to, once
+// the attempts are used up the code is not found any more
+func (u *UserDB) AttemptMagicCode(username string, maxAttempts int) (*models.MagicLink, error) {
+	logrus.Debug("BEGIN - AttemptMagicCode")
+
+	collection := u.client.Database(u.databaseName).Collection(u.magicLinkCollection)
+
+	filter := bson.M{
+		"username":  username,
+		"attempts":  bson.M{"$lt": maxAttempts},
+		"expiresAt": bson.M{"$gt": time.Now().UTC()},
+	}
+	var result models.MagicLink
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := collection.FindOneAndUpdate(context.Background(), filter, bson.M{"$inc": bson.M{"attempts": 1}}, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ConsumeMagicLink deletes an unexpired passwordless login by the hash of its link token and returns it, only the
+// caller that deletes it may log in with it
+func (u *UserDB) ConsumeMagicLink(hash string) (*models.MagicLink, error) {
+	logrus.Debug("BEGIN - ConsumeMagicLink")
+
+	collection := u.client.Database(u.databaseName).Collection(u.magicLinkCollection)
+
+	var result models.MagicLink
+	filter := bson.M{"hash": hash, "expiresAt": bson.M{"$gt": time.Now().UTC()}}
+	err := collection.FindOneAndDelete(context.Background(), filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
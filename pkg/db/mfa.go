@@ -0,0 +1,186 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrTOTPCodeUsed is returned when a code for a time step that was already used is presented again
+var ErrTOTPCodeUsed = errors.New("totp code has already been used")
+
+// StartTOTPEnrollment stores a new unconfirmed secret for the user, an unconfirmed enrollment is replaced but a
+// confirmed one has to be disabled first
+func (u *UserDB) StartTOTPEnrollment(username string, secret string) error {
+	logrus.Debug("BEGIN - StartTOTPEnrollment")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	filter := bson.M{"username": username, "totp.confirmed": bson.M{"$ne": true}}
+	update := bson.M{"$set": bson.M{"totp": models.TOTP{Secret: secret, CreatedAt: time.Now().UTC()}}}
+	result, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("user not found or two-factor authentication is already enabled")
+	}
+
+	return nil
+}
+
+// ConfirmTOTP enables the enrollment of the secret once the user has shown a code for it, the secret is part of the
+// filter so an enrollment restarted in the meantime is not confirmed
+func (u *UserDB) ConfirmTOTP(username string, secret string, counter int64, recoveryCodes []string) error {
+	logrus.Debug("BEGIN - ConfirmTOTP")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	filter := bson.M{"username": username, "totp.secret": secret, "totp.confirmed": false}
+	update := bson.M{"$set": bson.M{
+		"totp.confirmed":     true,
+		"totp.confirmedAt":   time.Now().UTC(),
+		"totp.lastCounter":   counter,
+		"totp.recoveryCodes": recoveryCodes,
+	}}
+	result, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("totp enrollment not found")
+	}
+
+	return nil
+}
+
+// UseTOTPCode records the time step of a code the user logged in with, a step at or before the last one used is
+// refused so every code works once
+func (u *UserDB) UseTOTPCode(username string, counter int64) error {
+	logrus.Debug("BEGIN - UseTOTPCode")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	filter := bson.M{"username": username, "totp.confirmed": true, "totp.lastCounter": bson.M{"$lt": counter}}
+	result, err := collection.UpdateOne(context.Background(), filter, bson.M{"$set": bson.M{"totp.lastCounter": counter}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrTOTPCodeUsed
+	}
+
+	return nil
+}
+
+// UseRecoveryCode removes a recovery code by its hash, only the caller that removes it may log in with it
+func (u *UserDB) UseRecoveryCode(username string, hash string) error {
+	logrus.Debug("BEGIN - UseRecoveryCode")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	filter := bson.M{"username": username, "totp.confirmed": true, "totp.recoveryCodes": hash}
+	result, err := collection.UpdateOne(context.Background(), filter, bson.M{"$pull": bson.M{"totp.recoveryCodes": hash}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("recovery code not found")
+	}
+
+	return nil
+}
+
+// ReplaceRecoveryCodes swaps every recovery code of a confirmed enrollment for new ones
+func (u *UserDB) ReplaceRecoveryCodes(username string, recoveryCodes []string) error {
+	logrus.Debug("BEGIN - ReplaceRecoveryCodes")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	filter := bson.M{"username": username, "totp.confirmed": true}
+	result, err := collection.UpdateOne(context.Background(), filter, bson.M{"$set": bson.M{"totp.recoveryCodes": recoveryCodes}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("totp enrollment not found")
+	}
+
+	return nil
+}
+
+// DisableTOTP removes the user's enrollment, confirmed or not
+func (u *UserDB) DisableTOTP(username string) error {
+	logrus.Debug("BEGIN - DisableTOTP")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	filter := bson.M{"username": username, "totp": bson.M{"$exists": true}}
+	result, err := collection.UpdateOne(context.Background(), filter, bson.M{"$unset": bson.M{"totp": ""}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("totp enrollment not found")
+	}
+
+	return nil
+}
+
+// CreateMFAChallenge stores the interim token of a login waiting for a second factor, the collection's TTL index
+// removes it once it expires
+func (u *UserDB) CreateMFAChallenge(challenge *models.MFAChallenge) error {
+	logrus.Debug("BEGIN - CreateMFAChallenge")
+
+	collection := u.client.Database(u.databaseName).Collection(u.mfaChallengeCollection)
+
+	_, err := collection.InsertOne(context.Background(), challenge)
+
+	return err
+}
+
+// AttemptMFAChallenge counts an attempt at an unexpired challenge and returns it, a challenge that has used up its
+// attempts is not found any more
+func (u *UserDB) AttemptMFAChallenge(hash string, maxAttempts int) (*models.MFAChallenge, error) {
+	logrus.Debug("BEGIN - AttemptMFAChallenge")
+
+	collection := u.client.Database(u.databaseName).Collection(u.mfaChallengeCollection)
+
+	filter := bson.M{
+		"hash":      hash,
+		"attempts":  bson.M{"$lt": maxAttempts},
+		"expiresAt": bson.M{"$gt": time.Now().UTC()},
+	}
+	var result models.MFAChallenge
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := collection.FindOneAndUpdate(context.Background(), filter, bson.M{"$inc": bson.M{"attempts": 1}}, opts).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ConsumeMFAChallenge deletes a challenge once the second factor checked out, only the caller that deletes it gets
+// tokens
+func (u *UserDB) ConsumeMFAChallenge(hash string) error {
+	logrus.Debug("BEGIN - ConsumeMFAChallenge")
+
+	collection := u.client.Database(u.databaseName).Collection(u.mfaChallengeCollection)
+
+	result, err := collection.DeleteOne(context.Background(), bson.M{"hash": hash})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("mfa challenge not found")
+	}
+
+	return nil
+}
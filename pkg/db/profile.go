@@ -0,0 +1,62 @@
+package db
+
+import (
+	"context"
+
+	"github.com/geeksheik9/login-service/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UpdateProfile sets the changed profile fields of the user, a nil value removes the field, and returns the updated
+// user. The update only applies while the user is still at the version it was based on, otherwise
+// models.ErrVersionConflict is returned and nothing is written
+func (u *UserDB) UpdateProfile(username string, version int64, changes map[string]*string) (*models.User, error) {
+	logrus.Debug("BEGIN - UpdateProfile")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	set := bson.M{}
+	unset := bson.M{}
+	for field, value := range changes {
+		if value == nil {
+			unset[field] = ""
+		} else {
+			set[field] = *value
+		}
+	}
+	update := bson.M{"$inc": bson.M{"version": 1}}
+	if len(set) > 0 {
+		update["$set"] = set
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	// Users from before profiles had versions have no version field, they are at version 0
+	filter := activeUser(bson.M{"username": username, "version": version})
+	if version == 0 {
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	}
+
+	var result models.User
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	err := collection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		_, err = u.GetUser(username)
+		if err != nil {
+			return nil, err
+		}
+		return nil, models.ErrVersionConflict
+	}
+	if err != nil {
+		return nil, err
+	}
+	result.Password = ""
+	result.PasswordHistory = nil
+
+	return &result, nil
+}
@@ -0,0 +1,71 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/geeksheik9/login-service/pkg/ratelimit"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RateLimitDB is the data access object for rate limit buckets shared by every replica
+type RateLimitDB struct {
+	client              *mongo.Client
+	databaseName        string
+	rateLimitCollection string
+}
+
+// rateLimitBucket is the stored form of a token bucket
+type rateLimitBucket struct {
+	Key       string    `bson:"_id"`
+	Tokens    float64   `bson:"tokens"`
+	Allowed   bool      `bson:"allowed"`
+	UpdatedAt time.Time `bson:"updatedAt"`
+	ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// Take refills the bucket and takes a token in a single update pipeline, so replicas taking from the same bucket at
+// the same time can not both spend its last token. The bucket expires once it would be full again
+func (l *RateLimitDB) Take(ctx context.Context, key string, limit ratelimit.Limit, now time.Time) (bool, time.Duration, error) {
+	collection := l.client.Database(l.databaseName).Collection(l.rateLimitCollection)
+
+	now = now.UTC()
+	burst := float64(limit.Burst)
+	elapsed := bson.M{"$divide": bson.A{bson.M{"$subtract": bson.A{now, bson.M{"$ifNull": bson.A{"$updatedAt", now}}}}, 1000}}
+	refilled := bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$tokens", burst}}, bson.M{"$multiply": bson.A{elapsed, limit.Rate}}}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{"tokens": bson.M{"$min": bson.A{burst, bson.M{"$max": bson.A{0, refilled}}}}, "updatedAt": now}}},
+		{{Key: "$set", Value: bson.M{"allowed": bson.M{"$gte": bson.A{"$tokens", 1}}}}},
+		{{Key: "$set", Value: bson.M{
+			"tokens":    bson.M{"$cond": bson.A{"$allowed", bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+			"expiresAt": now.Add(time.Duration(burst / limit.Rate * float64(time.Second))),
+		}}},
+	}
+
+	var bucket rateLimitBucket
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	err := collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline, opts).Decode(&bucket)
+	if err != nil {
+		return false, 0, err
+	}
+	if bucket.Allowed {
+		return true, 0, nil
+	}
+
+	return false, ratelimit.RetryAfter(bucket.Tokens, limit), nil
+}
+
+// EnsureIndexes drops buckets once they have filled up again
+func (l *RateLimitDB) EnsureIndexes(ctx context.Context) error {
+	collection := l.client.Database(l.databaseName).Collection(l.rateLimitCollection)
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+
+	return err
+}
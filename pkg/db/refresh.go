@@ -0,0 +1,89 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/token"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CreateRefreshToken stores a newly issued refresh token
+func (u *UserDB) CreateRefreshToken(refreshToken *models.RefreshToken) error {
+	logrus.Debug("BEGIN - CreateRefreshToken")
+
+	collection := u.client.Database(u.databaseName).Collection(u.refreshTokenCollection)
+
+	_, err := collection.InsertOne(context.Background(), refreshToken)
+
+	return err
+}
+
+// GetRefreshToken finds a refresh token by its hash
+func (u *UserDB) GetRefreshToken(hash string) (*models.RefreshToken, error) {
+	logrus.Debug("BEGIN - GetRefreshToken")
+
+	collection := u.client.Database(u.databaseName).Collection(u.refreshTokenCollection)
+
+	var result models.RefreshToken
+	err := collection.FindOne(context.Background(), bson.M{"hash": hash}).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// RotateRefreshToken marks the refresh token as used and stores its replacement, it fails with
+// token.ErrRefreshTokenReused when the token was already used or revoked so concurrent rotations can not both win
+func (u *UserDB) RotateRefreshToken(hash string, next *models.RefreshToken) error {
+	logrus.Debug("BEGIN - RotateRefreshToken")
+
+	collection := u.client.Database(u.databaseName).Collection(u.refreshTokenCollection)
+
+	filter := bson.M{"hash": hash, "usedAt": bson.M{"$exists": false}, "revoked": false}
+	update := bson.M{"$set": bson.M{"usedAt": time.Now().UTC()}}
+	err := collection.FindOneAndUpdate(context.Background(), filter, update).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return token.ErrRefreshTokenReused
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = collection.InsertOne(context.Background(), next)
+
+	return err
+}
+
+// RevokeRefreshTokenFamily revokes every refresh token descended from the same login
+func (u *UserDB) RevokeRefreshTokenFamily(family string) error {
+	logrus.Debug("BEGIN - RevokeRefreshTokenFamily")
+
+	collection := u.client.Database(u.databaseName).Collection(u.refreshTokenCollection)
+
+	_, err := collection.UpdateMany(context.Background(), bson.M{"family": family}, bson.M{"$set": bson.M{"revoked": true}})
+
+	return err
+}
+
+// RevokeRefreshTokens revokes every refresh token issued to the user, by ID, and the older ones that only have the
+// username
+func (u *UserDB) RevokeRefreshTokens(user *models.User) error {
+	logrus.Debug("BEGIN - RevokeRefreshTokens")
+
+	collection := u.client.Database(u.databaseName).Collection(u.refreshTokenCollection)
+
+	filter := bson.M{"$or": []bson.M{
+		{"userId": user.ID},
+		{"userId": bson.M{"$exists": false}, "username": user.Username},
+	}}
+	_, err := collection.UpdateMany(context.Background(), filter, bson.M{"$set": bson.M{"revoked": true}})
+
+	return err
+}
@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// checkNotReserved refuses a username reserved for someone other than the user with the ID, a user may take back
+// their own old name
+func (u *UserDB) checkNotReserved(username string, userID primitive.ObjectID) error {
+	collection := u.client.Database(u.databaseName).Collection(u.reservationCollection)
+
+	filter := bson.M{"username": username, "userId": bson.M{"$ne": userID}, "expiresAt": bson.M{"$gt": time.Now().UTC()}}
+	count, err := collection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return models.ErrUsernameReserved
+	}
+
+	return nil
+}
+
+// RenameUser changes a username, the user's ID stays the same. The old name is reserved for the user for the
+// reservation period before the username changes, so there is no moment anyone else could register it
+func (u *UserDB) RenameUser(username string, newUsername string) error {
+	logrus.Debug("BEGIN - RenameUser")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+	reservations := u.client.Database(u.databaseName).Collection(u.reservationCollection)
+
+	var user models.User
+	err := collection.FindOne(context.Background(), activeUser(bson.M{"username": username})).Decode(&user)
+	if err != nil {
+		return err
+	}
+
+	// Soft deleted users keep their name until they are purged, so they are counted as well
+	count, err := collection.CountDocuments(context.Background(), bson.M{"username": newUsername})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return models.ErrUsernameTaken
+	}
+	err = u.checkNotReserved(newUsername, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if u.reservationPeriod > 0 {
+		now := time.Now().UTC()
+		reservation := &models.UsernameReservation{
+			Username:  username,
+			UserID:    user.ID,
+			CreatedAt: now,
+			ExpiresAt: now.Add(u.reservationPeriod),
+		}
+		opts := options.Replace().SetUpsert(true)
+		_, err = reservations.ReplaceOne(context.Background(), bson.M{"username": username}, reservation, opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := collection.UpdateOne(context.Background(), bson.M{"_id": user.ID, "username": username},
+		bson.M{"$set": bson.M{"username": newUsername}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	// The new name is the user's own now, a reservation of it from an earlier rename is not needed any more
+	_, err = reservations.DeleteOne(context.Background(), bson.M{"username": newUsername, "userId": user.ID})
+	if err != nil {
+		return err
+	}
+
+	return u.deletePending(username)
+}
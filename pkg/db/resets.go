@@ -0,0 +1,73 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// CreatePasswordReset stores a password reset token, any earlier token of the user stops working
+func (u *UserDB) CreatePasswordReset(reset *models.PasswordReset) error {
+	logrus.Debug("BEGIN - CreatePasswordReset")
+
+	err := u.DeletePasswordResets(reset.Username)
+	if err != nil {
+		return err
+	}
+
+	collection := u.client.Database(u.databaseName).Collection(u.passwordResetCollection)
+
+	_, err = collection.InsertOne(context.Background(), reset)
+
+	return err
+}
+
+// GetPasswordReset finds an unexpired password reset token by its hash
+func (u *UserDB) GetPasswordReset(hash string) (*models.PasswordReset, error) {
+	logrus.Debug("BEGIN - GetPasswordReset")
+
+	collection := u.client.Database(u.databaseName).Collection(u.passwordResetCollection)
+
+	var result models.PasswordReset
+	filter := bson.M{"hash": hash, "expiresAt": bson.M{"$gt": time.Now().UTC()}}
+	err := collection.FindOne(context.Background(), filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// ConsumePasswordReset deletes a password reset token, only the caller that deletes it may use it
+func (u *UserDB) ConsumePasswordReset(hash string) error {
+	logrus.Debug("BEGIN - ConsumePasswordReset")
+
+	collection := u.client.Database(u.databaseName).Collection(u.passwordResetCollection)
+
+	filter := bson.M{"hash": hash, "expiresAt": bson.M{"$gt": time.Now().UTC()}}
+	result, err := collection.DeleteOne(context.Background(), filter)
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return errors.New("password reset not found")
+	}
+
+	return nil
+}
+
+// DeletePasswordResets removes every password reset token of a user
+func (u *UserDB) DeletePasswordResets(username string) error {
+	logrus.Debug("BEGIN - DeletePasswordResets")
+
+	collection := u.client.Database(u.databaseName).Collection(u.passwordResetCollection)
+
+	_, err := collection.DeleteMany(context.Background(), bson.M{"username": username})
+
+	return err
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"context"
+
+	"github.com/geeksheik9/login-service/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RevocationDB is the data access object for revoked tokens
+type RevocationDB struct {
+	client               *mongo.Client
+	databaseName         string
+	revocationCollection string
+}
+
+// GetRevocations returns every revocation that has not expired yet
+func (v *RevocationDB) GetRevocations() ([]models.Revocation, error) {
+	logrus.Debug("BEGIN - GetRevocations")
+
+	collection := v.client.Database(v.databaseName).Collection(v.revocationCollection)
+
+	cur, err := collection.Find(context.Background(), bson.M{})
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(context.Background())
+
+	var revocations []models.Revocation
+	err = cur.All(context.Background(), &revocations)
+	if err != nil {
+		return nil, err
+	}
+
+	return revocations, nil
+}
+
+// SaveRevocation inserts a revocation
+func (v *RevocationDB) SaveRevocation(revocation *models.Revocation) error {
+	logrus.Debug("BEGIN - SaveRevocation")
+
+	collection := v.client.Database(v.databaseName).Collection(v.revocationCollection)
+
+	_, err := collection.InsertOne(context.Background(), revocation)
+
+	return err
+}
+
+// EnsureIndexes expires revocations once the tokens they cover can no longer be used
+func (v *RevocationDB) EnsureIndexes(ctx context.Context) error {
+	collection := v.client.Database(v.databaseName).Collection(v.revocationCollection)
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+
+	return err
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// EnsureWebAuthnID gives the user a WebAuthn user handle unless they already have one and returns the stored handle.
+// The handle stays the same for every passkey of the user
+func (u *UserDB) EnsureWebAuthnID(username string, candidate string) (string, error) {
+	logrus.Debug("BEGIN - EnsureWebAuthnID")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	filter := bson.M{"username": username, "webauthnId": bson.M{"$exists": false}}
+	_, err := collection.UpdateOne(context.Background(), filter, bson.M{"$set": bson.M{"webauthnId": candidate}})
+	if err != nil {
+		return "", err
+	}
+
+	var result models.User
+	err = collection.FindOne(context.Background(), bson.M{"username": username}).Decode(&result)
+	if err != nil {
+		return "", err
+	}
+
+	return result.WebAuthnID, nil
+}
+
+// AddWebAuthnCredential stores a new passkey for the user, a credential ID can only be registered once
+func (u *UserDB) AddWebAuthnCredential(username string, credential *models.WebAuthnCredential) error {
+	logrus.Debug("BEGIN - AddWebAuthnCredential")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	filter := bson.M{"username": username, "webauthnCredentials.id": bson.M{"$ne": credential.ID}}
+	result, err := collection.UpdateOne(context.Background(), filter, bson.M{"$push": bson.M{"webauthnCredentials": credential}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("user not found or credential is already registered")
+	}
+
+	return nil
+}
+
+// GetUserByWebAuthnCredential finds the user a passkey belongs to, the password hash is never returned
+func (u *UserDB) GetUserByWebAuthnCredential(id string) (*models.User, error) {
+	logrus.Debug("BEGIN - GetUserByWebAuthnCredential")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	var result models.User
+	err := collection.FindOne(context.Background(), activeUser(bson.M{"webauthnCredentials.id": id})).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	result.Password = ""
+	result.PasswordHistory = nil
+
+	return &result, nil
+}
+
+// UpdateWebAuthnSignCount stores the signature counter of a passkey after a login, the old counter is part of the
+// filter so two logins with the same assertion can not both succeed
+func (u *UserDB) UpdateWebAuthnSignCount(username string, id string, oldCount uint32, newCount uint32) error {
+	logrus.Debug("BEGIN - UpdateWebAuthnSignCount")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	filter := bson.M{
+		"username":            username,
+		"webauthnCredentials": bson.M{"$elemMatch": bson.M{"id": id, "signCount": oldCount}},
+	}
+	update := bson.M{"$set": bson.M{
+		"webauthnCredentials.$.signCount":  newCount,
+		"webauthnCredentials.$.lastUsedAt": time.Now().UTC(),
+	}}
+	result, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("credential not found")
+	}
+
+	return nil
+}
+
+// DeleteWebAuthnCredential removes one of the user's passkeys
+func (u *UserDB) DeleteWebAuthnCredential(username string, id string) error {
+	logrus.Debug("BEGIN - DeleteWebAuthnCredential")
+
+	collection := u.client.Database(u.databaseName).Collection(u.userCollection)
+
+	filter := bson.M{"username": username, "webauthnCredentials.id": id}
+	update := bson.M{"$pull": bson.M{"webauthnCredentials": bson.M{"id": id}}}
+	result, err := collection.UpdateOne(context.Background(), filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errors.New("credential not found")
+	}
+
+	return nil
+}
+
+// CreateWebAuthnSession stores the challenge of a ceremony, the collection's TTL index removes it once it expires
+func (u *UserDB) CreateWebAuthnSession(session *models.WebAuthnSession) error {
+	logrus.Debug("BEGIN - CreateWebAuthnSession")
+
+	collection := u.client.Database(u.databaseName).Collection(u.webauthnSessionCollection)
+
+	_, err := collection.InsertOne(context.Background(), session)
+
+	return err
+}
+
+// ConsumeWebAuthnSession deletes the unexpired session of a challenge and returns it, a challenge can only be
+// answered once
+func (u *UserDB) ConsumeWebAuthnSession(challenge string) (*models.WebAuthnSession, error) {
+	logrus.Debug("BEGIN - ConsumeWebAuthnSession")
+
+	collection := u.client.Database(u.databaseName).Collection(u.webauthnSessionCollection)
+
+	var result models.WebAuthnSession
+	filter := bson.M{"challenge": challenge, "expiresAt": bson.M{"$gt": time.Now().UTC()}}
+	err := collection.FindOneAndDelete(context.Background(), filter).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
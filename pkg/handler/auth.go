@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/token"
+)
+
+// ErrMissingToken is returned when a request has no bearer token
+var ErrMissingToken = errors.New("missing bearer token")
+
+// ErrNotUserToken is returned when a route acting on the signed in user gets a token issued to a client, for the client
+// itself or for a user
+var ErrNotUserToken = errors.New("token was not issued to a user by a first party login")
+
+// bearerToken returns the token from the Authorization header
+func bearerToken(r *http.Request) string {
+	header := strings.TrimSpace(r.Header.Get("Authorization"))
+	if len(header) > len("Bearer ") && strings.EqualFold(header[:len("Bearer ")], "Bearer ") {
+		header = header[len("Bearer "):]
+	}
+
+	return strings.TrimSpace(header)
+}
+
+// authenticate verifies the bearer token on the request, every token check goes through here
+func (s *LoginService) authenticate(r *http.Request) (*token.Claims, error) {
+	tokenString := bearerToken(r)
+	if tokenString == "" {
+		return nil, ErrMissingToken
+	}
+
+	return s.Tokens.Validate(tokenString)
+}
+
+// authenticateUser verifies the bearer token like authenticate and only accepts first party logins. client_credentials
+// tokens name no user, and tokens a client got for a user through the token endpoint carry its client ID and a scope,
+// neither may act on the user's account
+func (s *LoginService) authenticateUser(r *http.Request) (*token.Claims, error) {
+	claims, err := s.authenticate(r)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Username == "" || claims.ClientID != "" || claims.Scope != "" {
+		return nil, ErrNotUserToken
+	}
+
+	return claims, nil
+}
+
+// currentUser finds the user a token was issued to by the token's subject, the user's ID, so it is the same user even
+// when the username changed after the token was issued
+func (s *LoginService) currentUser(claims *token.Claims) (*models.User, error) {
+	return s.Database.GetUserByID(claims.Subject)
+}
+
+// requireRole only calls the handler for requests with a valid token carrying the role
+func (s *LoginService) requireRole(role string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, err := s.authenticateUser(r)
+		if err != nil {
+			api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		if !claims.HasRole(role) {
+			api.RespondWithError(w, http.StatusForbidden, "Missing required role "+role)
+			return
+		}
+
+		next(w, r)
+	}
+}
@@ -0,0 +1,222 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/token"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrInvalidClient is returned when a client's credentials are missing or wrong
+var ErrInvalidClient = errors.New("invalid client credentials")
+
+// introspectScope lets a registered client call the introspection endpoint
+const introspectScope = "introspect"
+
+// supportedGrantTypes are the grant types a registered client may be given
+var supportedGrantTypes = map[string]bool{
+	"authorization_code": true,
+	"refresh_token":      true,
+	"client_credentials": true,
+	deviceCodeGrantType:  true,
+}
+
+// ClientCredentials identifies a client that calls the service on its own behalf
+type ClientCredentials struct {
+	ID     string
+	Secret string
+}
+
+// clientCredentials reads client credentials from HTTP basic auth, falling back to the client_id and client_secret
+// form fields
+func clientCredentials(r *http.Request) (string, string) {
+	if id, secret, ok := r.BasicAuth(); ok {
+		return id, secret
+	}
+
+	return r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+}
+
+// authenticateClient checks the request's client credentials against the client registry
+func (s *LoginService) authenticateClient(r *http.Request) (*models.Client, error) {
+	id, secret := clientCredentials(r)
+	if id == "" || secret == "" {
+		return nil, ErrInvalidClient
+	}
+
+	client, err := s.Database.AuthenticateClient(id, secret)
+	if err != nil {
+		return nil, ErrInvalidClient
+	}
+
+	return client, nil
+}
+
+// authenticateIntrospectionClient accepts the configured introspection client or a registered client with the
+// introspect scope
+func (s *LoginService) authenticateIntrospectionClient(r *http.Request) (string, error) {
+	id, secret := clientCredentials(r)
+	expected := s.IntrospectionClient
+
+	if expected.ID != "" && expected.Secret != "" && id == expected.ID &&
+		subtle.ConstantTimeCompare([]byte(secret), []byte(expected.Secret)) == 1 {
+		return id, nil
+	}
+
+	client, err := s.authenticateClient(r)
+	if err != nil || !client.HasScope(introspectScope) {
+		return "", ErrInvalidClient
+	}
+
+	return client.ClientID, nil
+}
+
+// validateClient checks the parts of a client an admin can set
+func validateClient(client *models.Client) error {
+	if len(client.GrantTypes) == 0 {
+		return errors.New("at least one grant type is required")
+	}
+
+	for _, grantType := range client.GrantTypes {
+		if !supportedGrantTypes[grantType] {
+			return errors.New("unsupported grant type " + grantType)
+		}
+	}
+
+	if client.Public && client.HasGrantType("client_credentials") {
+		return errors.New("public clients can not use the client_credentials grant")
+	}
+	if client.HasGrantType("authorization_code") && len(client.RedirectURIs) == 0 {
+		return errors.New("the authorization_code grant requires redirect URIs")
+	}
+
+	return nil
+}
+
+// CreateClient registers a client, the generated secret is only ever returned in this response
+func (s *LoginService) CreateClient(w http.ResponseWriter, r *http.Request) {
+	log.Infof("CreateClient invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	var client models.Client
+	err := json.NewDecoder(r.Body).Decode(&client)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	err = validateClient(&client)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if client.ClientID == "" {
+		client.ClientID, err = token.NewID()
+		if err != nil {
+			api.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	client.Secret = ""
+	if !client.Public {
+		client.Secret, _, err = token.NewOpaqueToken()
+		if err != nil {
+			api.RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+	client.CreatedAt = time.Now().UTC()
+
+	err = s.Database.CreateClient(&client)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusCreated, client)
+}
+
+// GetClients lists the registered clients
+func (s *LoginService) GetClients(w http.ResponseWriter, r *http.Request) {
+	log.Infof("GetClients invoked with URL: %v", r.URL)
+
+	clients, err := s.Database.GetClients(r.URL.Query())
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, clients)
+}
+
+// GetClient returns a registered client
+func (s *LoginService) GetClient(w http.ResponseWriter, r *http.Request) {
+	log.Infof("GetClient invoked with URL: %v", r.URL)
+
+	client, err := s.Database.GetClient(mux.Vars(r)["clientId"])
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, client)
+}
+
+// UpdateClient changes a client's name, scopes, grant types and redirect URIs
+func (s *LoginService) UpdateClient(w http.ResponseWriter, r *http.Request) {
+	log.Infof("UpdateClient invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	existing, err := s.Database.GetClient(mux.Vars(r)["clientId"])
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	var client models.Client
+	err = json.NewDecoder(r.Body).Decode(&client)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+	client.ClientID = existing.ClientID
+	client.Public = existing.Public
+	client.CreatedAt = existing.CreatedAt
+
+	err = validateClient(&client)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	err = s.Database.UpdateClient(&client)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, client)
+}
+
+// DeleteClient removes a registered client
+func (s *LoginService) DeleteClient(w http.ResponseWriter, r *http.Request) {
+	log.Infof("DeleteClient invoked with URL: %v", r.URL)
+
+	err := s.Database.DeleteClient(mux.Vars(r)["clientId"])
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, "Client Deleted")
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/audit"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// DeleteAccountRequest is the body of DELETE /users/me, the password is asked for again so a stolen token is not
+// enough to delete the account
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// DeleteAccount deletes the account of the user of the bearer token after checking their password
+func (s *LoginService) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	log.Infof("DeleteAccount invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request DeleteAccountRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Password == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	user, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	_, err = s.Database.AuthenticateUser(&models.User{Username: user.Username, Password: request.Password})
+	if err != nil {
+		api.RespondWithError(w, http.StatusForbidden, "Password is incorrect")
+		return
+	}
+
+	err = s.deleteUser(user.Username, user.Username, false)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, "User Deleted")
+}
+
+// DeleteUser is the admin operation deleting a user, purge=true removes a user at once even when deletes are soft
+func (s *LoginService) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	log.Infof("DeleteUser invoked with URL: %v", r.URL)
+
+	err := s.deleteUser(s.usernameFromToken(r), mux.Vars(r)["username"], r.URL.Query().Get("purge") == "true")
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, "User Deleted")
+}
+
+// RestoreUser is the admin operation undoing a soft delete before the user is purged
+func (s *LoginService) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	log.Infof("RestoreUser invoked with URL: %v", r.URL)
+
+	username := mux.Vars(r)["username"]
+
+	err := s.Database.RestoreUser(username)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	s.recordAudit(audit.UserRestored, s.usernameFromToken(r), username, nil)
+
+	api.RespondWithJSON(w, http.StatusOK, "User Restored")
+}
+
+// deleteUser deletes the user and then revokes every token of theirs, refreshes after the delete no longer find the
+// user so no session outlives the account
+func (s *LoginService) deleteUser(actor string, username string, purge bool) error {
+	// An unknown user is reported before anything is deleted, a soft deleted user being purged had their sessions
+	// revoked when they were deleted
+	user, err := s.Database.GetUser(username)
+	if err != nil && !purge {
+		return err
+	}
+
+	purgeAt, err := s.Database.DeleteUser(username, purge)
+	if err != nil {
+		return err
+	}
+
+	if user != nil {
+		err = s.revokeSessions(user)
+		if err != nil {
+			return err
+		}
+	}
+
+	details := map[string]string{"mode": "purged"}
+	if purgeAt != nil {
+		details = map[string]string{"mode": "soft", "purgeAt": purgeAt.Format(time.RFC3339)}
+	}
+	s.recordAudit(audit.UserDeleted, actor, username, details)
+
+	return nil
+}
+
+// recordAudit records an event, a failure to record is logged but does not fail the request that already happened
+func (s *LoginService) recordAudit(eventType string, actor string, subject string, details map[string]string) {
+	if s.Audit == nil {
+		return
+	}
+
+	err := s.Audit.Record(&audit.Event{
+		Type:    eventType,
+		Actor:   actor,
+		Subject: subject,
+		Time:    time.Now().UTC(),
+		Details: details,
+	})
+	if err != nil {
+		log.Errorf("Error recording %s audit event for %s: %v", eventType, subject, err)
+	}
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/audit"
+	"github.com/geeksheik9/login-service/pkg/token"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Username: "alice", Password: "pw"}
+	database := newFakeDatabase(user)
+	s := newTestService(t, database)
+	bearer, _, _ := s.Tokens.Issue(user, "")
+
+	w := serve(s.DeleteAccount, http.MethodDelete, DeleteAccountRequest{Password: "wrong"}, bearer)
+	if w.Code != http.StatusForbidden || database.users["alice"] == nil {
+		t.Fatalf("DeleteAccount() wrong password error:\n   expected: %v and alice kept\n   got:      %v %s", http.StatusForbidden, w.Code, w.Body)
+	}
+
+	w = serve(s.DeleteAccount, http.MethodDelete, DeleteAccountRequest{Password: "pw"}, bearer)
+	if w.Code != http.StatusOK || database.users["alice"] != nil {
+		t.Fatalf("DeleteAccount() error:\n   expected: %v and alice removed\n   got:      %v %s", http.StatusOK, w.Code, w.Body)
+	}
+
+	_, err := s.Tokens.Validate(bearer)
+	if !errors.Is(err, token.ErrTokenRevoked) {
+		t.Errorf("Validate() error:\n   expected: %v\n   got:      %v", token.ErrTokenRevoked, err)
+	}
+}
+
+func TestDeleteAccount_RefreshDuringDelete(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Username: "alice", Password: "pw"}
+	database := newFakeDatabase(user)
+	database.purgeDelay = time.Hour
+	s := newTestService(t, database)
+
+	bearer, _, _ := s.Tokens.Issue(user, "")
+	session, err := s.newTokenResponse(user, "", bearer)
+	if err != nil {
+		t.Fatalf("newTokenResponse() returned error: %v", err)
+	}
+
+	// The refresh lands after the access tokens are revoked but before the refresh tokens are
+	var refresh *httptest.ResponseRecorder
+	database.beforeRevoke = func() {
+		refresh = serve(s.RefreshToken, http.MethodPost, RefreshRequest{RefreshToken: session.RefreshToken}, "")
+	}
+
+	w := serve(s.DeleteAccount, http.MethodDelete, DeleteAccountRequest{Password: "pw"}, bearer)
+	if w.Code != http.StatusOK {
+		t.Fatalf("DeleteAccount() error:\n   expected: %v\n   got:      %v %s", http.StatusOK, w.Code, w.Body)
+	}
+	if refresh == nil {
+		t.Fatalf("RefreshToken() during delete error:\n   expected: a refresh between the delete and the revocation\n   got:      none")
+	}
+	if refresh.Code == http.StatusOK {
+		t.Errorf("RefreshToken() during delete error:\n   expected: the refresh refused\n   got:      %v %s", refresh.Code, refresh.Body)
+	}
+}
+
+func TestDeleteUser_SoftDeleteAndPurge(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Username: "alice"}
+	database := newFakeDatabase(user)
+	database.purgeDelay = time.Hour
+	recorder := &memoryRecorder{}
+	s := newTestService(t, database)
+	s.Audit = recorder
+
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{username}", s.DeleteUser).Methods(http.MethodDelete)
+	router.HandleFunc("/users/{username}/restore", s.RestoreUser).Methods(http.MethodPost)
+	request := func(method string, path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w.Code
+	}
+
+	// With a purge delay a delete is soft, the user can be restored
+	if code := request(http.MethodDelete, "/users/alice"); code != http.StatusOK || database.deleted["alice"] == nil {
+		t.Fatalf("DeleteUser() error:\n   expected: %v and alice soft deleted\n   got:      %v", http.StatusOK, code)
+	}
+	if code := request(http.MethodPost, "/users/alice/restore"); code != http.StatusOK || database.users["alice"] == nil {
+		t.Fatalf("RestoreUser() error:\n   expected: %v and alice restored\n   got:      %v", http.StatusOK, code)
+	}
+
+	// A soft deleted user can still be purged, after that there is nothing to restore
+	request(http.MethodDelete, "/users/alice")
+	if code := request(http.MethodDelete, "/users/alice?purge=true"); code != http.StatusOK || database.deleted["alice"] != nil {
+		t.Fatalf("DeleteUser() purge error:\n   expected: %v and alice purged\n   got:      %v", http.StatusOK, code)
+	}
+	if code := request(http.MethodPost, "/users/alice/restore"); code != http.StatusNotFound {
+		t.Errorf("RestoreUser() error:\n   expected: %v\n   got:      %v", http.StatusNotFound, code)
+	}
+	if code := request(http.MethodDelete, "/users/nobody"); code != http.StatusNotFound {
+		t.Errorf("DeleteUser() unknown user error:\n   expected: %v\n   got:      %v", http.StatusNotFound, code)
+	}
+
+	modes := []string{}
+	for _, event := range recorder.events {
+		if event.Type == audit.UserDeleted {
+			modes = append(modes, event.Details["mode"])
+		}
+	}
+	if len(modes) != 3 || modes[0] != "soft" || modes[1] != "soft" || modes[2] != "purged" {
+		t.Errorf("DeleteUser() audit error:\n   expected: [soft soft purged]\n   got:      %v", modes)
+	}
+}
@@ -0,0 +1,335 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// deviceCodeGrantType is the RFC 8628 grant type a device polls the token endpoint with
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// devicePollInterval is the minimum time a device has to wait between polls
+const devicePollInterval = 5 * time.Second
+
+var devicePage = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Connect a device</title></head>
+<body>
+<h1>Connect a device</h1>
+{{if .Message}}<p>{{.Message}}</p>{{end}}
+{{if not .Done}}
+<form method="post" action="device">
+<label>Code <input name="user_code" value="{{.UserCode}}" autocomplete="off"></label>
+<label>Username <input name="username" autocomplete="username"></label>
+<label>Password <input name="password" type="password" autocomplete="current-password"></label>
+<label>Two-factor code <input name="code" autocomplete="one-time-code"></label>
+<button type="submit" name="action" value="approve">Approve</button>
+<button type="submit" name="action" value="deny">Deny</button>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+// devicePageData is what the device verification page shows
+type devicePageData struct {
+	UserCode string
+	Message  string
+	Done     bool
+}
+
+// DeviceVerificationRequest is the body of POST /device/verify
+type DeviceVerificationRequest struct {
+	UserCode string `json:"userCode"`
+	Approve  bool   `json:"approve"`
+}
+
+// DeviceAuthorization starts the device authorization grant for a client that can not show a browser
+func (s *LoginService) DeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	log.Infof("DeviceAuthorization invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "unable to parse form")
+		return
+	}
+
+	client, oauthError, err := s.deviceClient(r)
+	if err != nil {
+		if oauthError == "invalid_client" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+			respondWithOAuthError(w, http.StatusUnauthorized, oauthError, err.Error())
+			return
+		}
+		respondWithOAuthError(w, http.StatusBadRequest, oauthError, err.Error())
+		return
+	}
+
+	deviceCode, hash, err := token.NewOpaqueToken()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	userCode, err := token.NewUserCode()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	err = s.Database.CreateDeviceCode(&models.DeviceCode{
+		Hash:      hash,
+		UserCode:  userCode,
+		ClientID:  client.ClientID,
+		Scope:     strings.Join(strings.Fields(r.PostFormValue("scope")), " "),
+		Status:    models.DeviceCodePending,
+		ExpiresAt: time.Now().UTC().Add(s.DeviceCodeLifetime),
+	})
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	verificationURI := strings.TrimSuffix(s.Tokens.Issuer, "/") + "/device"
+	w.Header().Set("Cache-Control", "no-store")
+	api.RespondWithJSON(w, http.StatusOK, models.DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                userCode,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?" + url.Values{"user_code": {userCode}}.Encode(),
+		ExpiresIn:               int64(s.DeviceCodeLifetime.Seconds()),
+		Interval:                int64(devicePollInterval.Seconds()),
+	})
+}
+
+// deviceClient finds the registered client a device request is for, confidential clients have to authenticate
+func (s *LoginService) deviceClient(r *http.Request) (*models.Client, string, error) {
+	clientID, _ := clientCredentials(r)
+	if clientID == "" {
+		return nil, "invalid_request", errors.New("client_id is required")
+	}
+
+	client, err := s.Database.GetClient(clientID)
+	if err != nil {
+		return nil, "invalid_client", ErrInvalidClient
+	}
+	if !client.Public {
+		client, err = s.authenticateClient(r)
+		if err != nil {
+			return nil, "invalid_client", err
+		}
+	}
+	if !client.HasGrantType(deviceCodeGrantType) {
+		return nil, "unauthorized_client", errors.New("client may not use the device_code grant")
+	}
+
+	return client, "", nil
+}
+
+// deviceCodeGrant answers a device polling the token endpoint, tokens are issued once the user has approved the code
+func (s *LoginService) deviceCodeGrant(w http.ResponseWriter, r *http.Request) {
+	deviceCode := r.PostFormValue("device_code")
+	if deviceCode == "" {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "device_code is required")
+		return
+	}
+
+	client, oauthError, err := s.deviceClient(r)
+	if err != nil {
+		if oauthError == "invalid_client" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+			respondWithOAuthError(w, http.StatusUnauthorized, oauthError, err.Error())
+			return
+		}
+		respondWithOAuthError(w, http.StatusBadRequest, oauthError, err.Error())
+		return
+	}
+
+	hash := token.HashOpaqueToken(deviceCode)
+	stored, err := s.Database.PollDeviceCode(hash)
+	if err != nil {
+		if api.CheckError(err) == http.StatusNotFound {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "device code is invalid")
+			return
+		}
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	now := time.Now()
+	switch {
+	case stored.ClientID != client.ClientID:
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "device code was issued to another client")
+		return
+	case now.After(stored.ExpiresAt):
+		respondWithOAuthError(w, http.StatusBadRequest, "expired_token", "device code is expired")
+		return
+	case stored.Status == models.DeviceCodeDenied:
+		_ = s.Database.DeleteDeviceCode(hash, models.DeviceCodeDenied)
+		respondWithOAuthError(w, http.StatusBadRequest, "access_denied", "the user denied the request")
+		return
+	case stored.Status == models.DeviceCodePending:
+		if stored.LastPolledAt != nil && now.Sub(*stored.LastPolledAt) < devicePollInterval {
+			respondWithOAuthError(w, http.StatusBadRequest, "slow_down", "polling too frequently")
+			return
+		}
+		respondWithOAuthError(w, http.StatusBadRequest, "authorization_pending", "the user has not approved the request yet")
+		return
+	}
+
+	// Deleting the approved code is what makes it single use, only the poll that deletes it gets tokens
+	err = s.Database.DeleteDeviceCode(hash, models.DeviceCodeApproved)
+	if err != nil {
+		if api.CheckError(err) == http.StatusNotFound {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "device code is invalid")
+			return
+		}
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	user, err := s.Database.GetUser(stored.Username)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "user no longer exists")
+		return
+	}
+
+	accessToken, _, err := s.Tokens.IssueForClient(user, client.ClientID, stored.Scope)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	response, err := s.newClientTokenResponse(user, client.ClientID, stored.Scope, accessToken)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	respondWithTokens(w, response)
+}
+
+// DeviceVerification shows the page a user enters the code from their device on
+func (s *LoginService) DeviceVerification(w http.ResponseWriter, r *http.Request) {
+	log.Infof("DeviceVerification invoked with URL: %v", r.URL)
+
+	s.renderDevice(w, http.StatusOK, &devicePageData{UserCode: r.URL.Query().Get("user_code")})
+}
+
+// DeviceVerificationLogin checks the credentials posted from the device page and approves or denies the code
+func (s *LoginService) DeviceVerificationLogin(w http.ResponseWriter, r *http.Request) {
+	log.Infof("DeviceVerificationLogin invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	err := r.ParseForm()
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	page := &devicePageData{UserCode: r.PostFormValue("user_code")}
+	user, err := s.Database.AuthenticateUser(&models.User{
+		Username: r.PostFormValue("username"),
+		Password: r.PostFormValue("password"),
+	})
+	if err != nil {
+		page.Message = "Incorrect username or password"
+		s.renderDevice(w, http.StatusUnauthorized, page)
+		return
+	}
+	if s.checkEmailVerified(user) != nil {
+		page.Message = "Verify your email address before logging in"
+		s.renderDevice(w, http.StatusForbidden, page)
+		return
+	}
+	if message := s.checkFormSecondFactor(user, r.PostFormValue("code")); message != "" {
+		page.Message = message
+		s.renderDevice(w, http.StatusUnauthorized, page)
+		return
+	}
+
+	approve := r.PostFormValue("action") == "approve"
+	err = s.decideDeviceCode(page.UserCode, user.Username, approve)
+	if err != nil {
+		page.Message = "The code is invalid or has expired"
+		s.renderDevice(w, api.CheckError(err), page)
+		return
+	}
+
+	page.Done = true
+	page.Message = "The device has been denied, you can close this window"
+	if approve {
+		page.Message = "The device is connected, you can close this window"
+	}
+	s.renderDevice(w, http.StatusOK, page)
+}
+
+// VerifyDeviceCode approves or denies a device code for the user of the bearer token
+func (s *LoginService) VerifyDeviceCode(w http.ResponseWriter, r *http.Request) {
+	log.Infof("VerifyDeviceCode invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request DeviceVerificationRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.UserCode == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	user, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	err = s.decideDeviceCode(request.UserCode, user.Username, request.Approve)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	if request.Approve {
+		api.RespondWithJSON(w, http.StatusOK, "Device Approved")
+		return
+	}
+	api.RespondWithJSON(w, http.StatusOK, "Device Denied")
+}
+
+// decideDeviceCode records the user's decision on a pending device code
+func (s *LoginService) decideDeviceCode(userCode string, username string, approve bool) error {
+	status := models.DeviceCodeDenied
+	if approve {
+		status = models.DeviceCodeApproved
+	}
+
+	return s.Database.DecideDeviceCode(token.NormalizeUserCode(userCode), username, status)
+}
+
+func (s *LoginService) renderDevice(w http.ResponseWriter, code int, page *devicePageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(code)
+
+	err := devicePage.Execute(w, page)
+	if err != nil {
+		log.Errorf("Error rendering device page: %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/mail"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// resendVerificationResponse is sent whether or not the address has an account, so the endpoint can not be used to
+// find accounts
+const resendVerificationResponse = "If the address has an unverified account a verification email has been sent"
+
+// registrationResponse is sent for registrations with an email address whether or not the address is taken, so
+// /register can not be used to find accounts by email
+const registrationResponse = "If the email address is not registered already the user was created and a verification " +
+	"email has been sent"
+
+// ErrEmailNotVerified is returned when a user with an unverified address logs in and verified addresses are required
+var ErrEmailNotVerified = errors.New("email address is not verified")
+
+// VerifyEmailRequest is the body of POST /email/verify, the token from the verification email
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+// ResendVerificationRequest is the body of POST /email/verify/resend
+type ResendVerificationRequest struct {
+	Email string `json:"email"`
+}
+
+// VerifyEmail marks the address in a verification link as verified
+func (s *LoginService) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	log.Infof("VerifyEmail invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	var request VerifyEmailRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Token == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	claims, err := s.Tokens.ValidateEmailVerification(request.Token)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid or expired verification link")
+		return
+	}
+
+	err = s.Database.VerifyEmail(claims.Subject, claims.Email)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid or expired verification link")
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, "Email Verified")
+}
+
+// ResendEmailVerification sends a new verification email to an address that has not been verified yet
+func (s *LoginService) ResendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	log.Infof("ResendEmailVerification invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	var request ResendVerificationRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+	address, err := mail.NormalizeAddress(request.Email)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	// Like /password/forgot the lookup and the mail are done after responding
+	go func() {
+		user, err := s.Database.GetUserByEmail(address)
+		if err != nil || user.EmailVerified {
+			log.Debugf("No verification email sent for %s: %v", address, err)
+			return
+		}
+		s.sendEmailVerification(user)
+	}()
+
+	api.RespondWithJSON(w, http.StatusAccepted, resendVerificationResponse)
+}
+
+// sendEmailVerification mails the user a signed link to verify their address, failures can only be logged
+func (s *LoginService) sendEmailVerification(user *models.User) {
+	if user.Email == "" || s.Mailer == nil {
+		log.Warnf("No verification email sent for %s: no email address or mailer", user.Username)
+		return
+	}
+
+	value, err := s.Tokens.IssueEmailVerification(user, s.EmailVerificationLifetime)
+	if err != nil {
+		log.Errorf("Error creating verification email for %s: %v", user.Username, err)
+		return
+	}
+
+	body := "Confirm that " + user.Email + " is the email address of your account " + user.Username + ".\n\n" +
+		"Use this code to verify it, it expires in " + s.EmailVerificationLifetime.String() + ":\n\n" + value + "\n"
+	if s.EmailVerificationURL != "" {
+		body += "\nOr open " + s.EmailVerificationURL + "?" + url.Values{"token": {value}}.Encode() + "\n"
+	}
+	body += "\nIf you did not create this account you can ignore this email.\n"
+
+	err = s.Mailer.Send(&mail.Message{To: user.Email, Subject: "Verify your email address", Body: body})
+	if err != nil {
+		log.Errorf("Error sending verification email for %s: %v", user.Username, err)
+	}
+}
+
+// sendRegistrationNotice tells the owner of an address that someone tried to register it again, the caller of /register
+// is not told that the address is taken
+func (s *LoginService) sendRegistrationNotice(address string) {
+	if s.Mailer == nil {
+		log.Warn("No registration notice sent: no mailer")
+		return
+	}
+
+	body := "Someone tried to create an account with this email address, which already belongs to an account.\n\n" +
+		"If it was you, sign in or reset your password through /password/forgot.\n" +
+		"\nIf it was not you, you can ignore this email.\n"
+
+	err := s.Mailer.Send(&mail.Message{To: address, Subject: "Your email address is already registered", Body: body})
+	if err != nil {
+		log.Errorf("Error sending registration notice: %v", err)
+	}
+}
+
+// checkEmailVerified refuses users whose address is not verified when verified addresses are required, it is only
+// checked where a login starts so sessions from before the requirement keep working
+func (s *LoginService) checkEmailVerified(user *models.User) error {
+	if s.EmailVerificationRequired && !user.EmailVerified {
+		return ErrEmailNotVerified
+	}
+
+	return nil
+}
@@ -5,12 +5,17 @@ import (
 	"errors"
 	"net/http"
 	"net/url"
-	"strings"
+	"time"
 
 	"github.com/geeksheik9/login-service/models"
 	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/audit"
+	"github.com/geeksheik9/login-service/pkg/mail"
+	"github.com/geeksheik9/login-service/pkg/password"
+	"github.com/geeksheik9/login-service/pkg/ratelimit"
+	"github.com/geeksheik9/login-service/pkg/token"
+	"github.com/geeksheik9/login-service/pkg/webauthn"
 
-	"github.com/dgrijalva/jwt-go"
 	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 )
@@ -18,19 +23,108 @@ import (
 // LoginDatabase is the interface setup for the login service
 type LoginDatabase interface {
 	RegisterUser(user *models.User) error
-	LoginUser(user *models.User) (string, error)
+	AuthenticateUser(user *models.User) (*models.User, error)
+	ChangePassword(username string, newPassword string) error
+	CheckPasswordHistory(username string, newPassword string) error
+	GetLockState(username string) (*models.LockState, error)
+	UnlockUser(username string) error
+	CreatePasswordReset(reset *models.PasswordReset) error
+	GetPasswordReset(hash string) (*models.PasswordReset, error)
+	ConsumePasswordReset(hash string) error
 	CreateRole(role *models.Role) error
 	DeleteRole(role *models.Role) error
 	GetRoles(queryParams url.Values) ([]models.Role, error)
 	AddUserRole(user models.User, role *models.Role) error
 	RemoveUserRole(user models.User, role *models.Role) error
+	GetUser(username string) (*models.User, error)
+	GetUserByID(userID string) (*models.User, error)
+	GetUserByEmail(email string) (*models.User, error)
+	VerifyEmail(userID string, email string) error
+	DeleteUser(username string, purge bool) (*time.Time, error)
+	RestoreUser(username string) error
+	UpdateProfile(username string, version int64, changes map[string]*string) (*models.User, error)
+	RenameUser(username string, newUsername string) error
+	CreateRefreshToken(refreshToken *models.RefreshToken) error
+	GetRefreshToken(hash string) (*models.RefreshToken, error)
+	RotateRefreshToken(hash string, next *models.RefreshToken) error
+	RevokeRefreshTokenFamily(family string) error
+	RevokeRefreshTokens(user *models.User) error
+	CreateAuthorizationCode(code *models.AuthorizationCode) error
+	ConsumeAuthorizationCode(hash string) (*models.AuthorizationCode, error)
+	CreateClient(client *models.Client) error
+	GetClient(clientID string) (*models.Client, error)
+	GetClients(queryParams url.Values) ([]models.Client, error)
+	UpdateClient(client *models.Client) error
+	DeleteClient(clientID string) error
+	AuthenticateClient(clientID string, secret string) (*models.Client, error)
+	CreateDeviceCode(code *models.DeviceCode) error
+	DecideDeviceCode(userCode string, username string, status string) error
+	PollDeviceCode(hash string) (*models.DeviceCode, error)
+	DeleteDeviceCode(hash string, status string) error
+	StartTOTPEnrollment(username string, secret string) error
+	ConfirmTOTP(username string, secret string, counter int64, recoveryCodes []string) error
+	UseTOTPCode(username string, counter int64) error
+	UseRecoveryCode(username string, hash string) error
+	ReplaceRecoveryCodes(username string, recoveryCodes []string) error
+	DisableTOTP(username string) error
+	CreateMFAChallenge(challenge *models.MFAChallenge) error
+	AttemptMFAChallenge(hash string, maxAttempts int) (*models.MFAChallenge, error)
+	ConsumeMFAChallenge(hash string) error
+	EnsureWebAuthnID(username string, candidate string) (string, error)
+	AddWebAuthnCredential(username string, credential *models.WebAuthnCredential) error
+	GetUserByWebAuthnCredential(id string) (*models.User, error)
+	UpdateWebAuthnSignCount(username string, id string, oldCount uint32, newCount uint32) error
+	DeleteWebAuthnCredential(username string, id string) error
+	CreateWebAuthnSession(session *models.WebAuthnSession) error
+	ConsumeWebAuthnSession(challenge string) (*models.WebAuthnSession, error)
+	CreateMagicLink(link *models.MagicLink) error
+	AttemptMagicCode(username string, maxAttempts int) (*models.MagicLink, error)
+	ConsumeMagicLink(hash string) (*models.MagicLink, error)
 	Ping() error
 }
 
+// KeyManager is the interface setup for rotating the token signing keys
+type KeyManager interface {
+	Records() []models.SigningKey
+	Rotate() (*models.SigningKey, error)
+}
+
 // LoginService is the implementation of a service to login to an application
 type LoginService struct {
-	Version  string
-	Database LoginDatabase
+	Version    string
+	Database   LoginDatabase
+	Keys       token.KeyProvider
+	KeyManager KeyManager
+	Tokens     *token.Issuer
+	AdminRole  string
+
+	RefreshLifetime     time.Duration
+	IntrospectionClient ClientCredentials
+	OIDCClients         map[string][]string
+	DeviceCodeLifetime  time.Duration
+	PasswordPolicy      *password.Policy
+
+	Mailer                mail.Mailer
+	PasswordResetLifetime time.Duration
+	PasswordResetURL      string
+
+	RateLimiter *ratelimit.Limiter
+
+	MFAChallengeLifetime time.Duration
+	TOTPIssuer           string
+	RecoveryCodeCount    int
+
+	WebAuthn        *webauthn.RelyingParty
+	WebAuthnTimeout time.Duration
+
+	MagicLinkLifetime time.Duration
+	MagicLinkURL      string
+
+	EmailVerificationRequired bool
+	EmailVerificationLifetime time.Duration
+	EmailVerificationURL      string
+
+	Audit audit.Recorder
 }
 
 // Routes sets up the routes for the RESTful interface
@@ -48,9 +142,11 @@ func (s *LoginService) Routes(r *mux.Router) *mux.Router {
 	//
 	// responses:
 	// 200: description:User Created
-	// 400: description:Bad request
+	// 400: description:Bad request or password does not meet the password policy
+	// 409: description:Username is taken or reserved
+	// 429: description:Too Many Requests
 	// 500: description:Internal Server Error
-	r.HandleFunc("/register", s.RegisterUser).Methods(http.MethodPost)
+	r.HandleFunc("/register", s.RateLimiter.Limit("register", usernameFromBody, s.RegisterUser)).Methods(http.MethodPost)
 	// swagger:route POST /login LoginUser
 	//
 	// Login Service
@@ -60,25 +156,753 @@ func (s *LoginService) Routes(r *mux.Router) *mux.Router {
 	// Schemes: http, https
 	//
 	// responses:
-	// 200: description:Success, returns JWT token
+	// 200: description:Success, returns a JWT access token and a refresh token, or an mfa_required token when the user has a second factor
+	// 400: description:Bad request
+	// 401: description:Incorrect username or password
+	// 429: description:Too Many Requests
+	// 500: description:Internal Server Error
+	r.HandleFunc("/login", s.RateLimiter.Limit("login", usernameFromBody, s.LoginUser)).Methods(http.MethodPost)
+	// swagger:route POST /login/mfa LoginMFA
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Success, returns a JWT access token and a refresh token
+	// 400: description:Bad request
+	// 401: description:Invalid code, or the mfa token is invalid, expired or out of attempts
+	// 429: description:Too Many Requests
+	// 500: description:Internal Server Error
+	r.HandleFunc("/login/mfa", s.RateLimiter.Limit("login/mfa", nil, s.LoginMFA)).Methods(http.MethodPost)
+	// swagger:route POST /login/mfa/webauthn/begin BeginWebAuthnMFA
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Options for navigator.credentials.get()
+	// 400: description:Bad request
+	// 401: description:The mfa token is invalid, expired or out of attempts, or the user has no passkey
+	// 429: description:Too Many Requests
+	r.HandleFunc("/login/mfa/webauthn/begin", s.RateLimiter.Limit("login/mfa", nil, s.BeginWebAuthnMFA)).Methods(http.MethodPost)
+	// swagger:route POST /login/mfa/webauthn/finish FinishWebAuthnMFA
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Success, returns a JWT access token and a refresh token
+	// 400: description:Bad request
+	// 401: description:Passkey could not be verified or the mfa token is invalid
+	// 429: description:Too Many Requests
+	r.HandleFunc("/login/mfa/webauthn/finish", s.RateLimiter.Limit("login/mfa", nil, s.FinishWebAuthnMFA)).Methods(http.MethodPost)
+	// swagger:route POST /login/magic SendMagicLink
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 202: description:Accepted, a sign in email is sent if the account exists
+	// 400: description:Bad request
+	// 429: description:Too Many Requests
+	r.HandleFunc("/login/magic", s.RateLimiter.Limit("login/magic", emailFromBody, s.SendMagicLink)).Methods(http.MethodPost)
+	// swagger:route POST /login/magic/verify VerifyMagicLink
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Success, returns a JWT access token and a refresh token, or an mfa token when a second factor is needed
+	// 400: description:Bad request
+	// 401: description:The link or code is invalid, used, expired or out of attempts
+	// 429: description:Too Many Requests
+	r.HandleFunc("/login/magic/verify", s.RateLimiter.Limit("login/magic/verify", emailFromBody, s.VerifyMagicLink)).Methods(http.MethodPost)
+	// swagger:route POST /webauthn/login/begin BeginWebAuthnLogin
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Options for navigator.credentials.get()
 	// 400: description:Bad request
+	// 429: description:Too Many Requests
+	r.HandleFunc("/webauthn/login/begin", s.RateLimiter.Limit("webauthn/login", nil, s.BeginWebAuthnLogin)).Methods(http.MethodPost)
+	// swagger:route POST /webauthn/login/finish FinishWebAuthnLogin
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Success, returns a JWT access token and a refresh token
+	// 400: description:Bad request
+	// 401: description:Passkey could not be verified
+	// 429: description:Too Many Requests
+	r.HandleFunc("/webauthn/login/finish", s.RateLimiter.Limit("webauthn/login", nil, s.FinishWebAuthnLogin)).Methods(http.MethodPost)
+	// swagger:route POST /webauthn/register/begin BeginWebAuthnRegistration
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Options for navigator.credentials.create()
+	// 400: description:Bad request
+	// 401: description:Unauthorized
+	// 403: description:Password or second factor is incorrect
+	r.HandleFunc("/webauthn/register/begin", s.BeginWebAuthnRegistration).Methods(http.MethodPost)
+	// swagger:route POST /webauthn/register/finish FinishWebAuthnRegistration
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 201: description:Passkey registered
+	// 400: description:Bad request, or the challenge or the attestation is invalid
+	// 401: description:Unauthorized
+	// 409: description:Passkey is already registered
+	r.HandleFunc("/webauthn/register/finish", s.FinishWebAuthnRegistration).Methods(http.MethodPost)
+	// swagger:route GET /webauthn/credentials GetWebAuthnCredentials
+	//
+	// Login Service
+	//
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Passkeys of the user
+	// 401: description:Unauthorized
+	r.HandleFunc("/webauthn/credentials", s.GetWebAuthnCredentials).Methods(http.MethodGet)
+	// swagger:route DELETE /webauthn/credentials/{id} DeleteWebAuthnCredential
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Passkey deleted
+	// 400: description:Bad request
+	// 401: description:Unauthorized
+	// 403: description:Password is incorrect
 	// 404: description:Not Found
+	r.HandleFunc("/webauthn/credentials/{id}", s.DeleteWebAuthnCredential).Methods(http.MethodDelete)
+	// swagger:route POST /mfa/totp EnrollTOTP
+	//
+	// Login Service
+	//
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Secret and otpauth URI of the pending enrollment
+	// 401: description:Unauthorized
+	// 409: description:Two-factor authentication is already enabled
 	// 500: description:Internal Server Error
-	r.HandleFunc("/login", s.LoginUser).Methods(http.MethodPost)
-	// swagger:route GET /profile GetUserProfile
+	r.HandleFunc("/mfa/totp", s.EnrollTOTP).Methods(http.MethodPost)
+	// swagger:route POST /mfa/totp/confirm ConfirmTOTP
 	//
 	// Login Service
 	//
 	// Consumes:
 	// - application/json
+	// Produces:
+	// - application/json
 	// Schemes: http, https
 	//
 	// responses:
-	// 200:	User
+	// 200: description:Two-factor authentication enabled, the response holds the only copy of the recovery codes
+	// 400: description:Bad request or invalid code
+	// 401: description:Unauthorized
+	// 409: description:No pending enrollment
+	// 500: description:Internal Server Error
+	r.HandleFunc("/mfa/totp/confirm", s.ConfirmTOTP).Methods(http.MethodPost)
+	// swagger:route DELETE /mfa/totp DisableTOTP
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Two-factor authentication disabled
 	// 400: description:Bad request
-	// 404: description:NotFound
+	// 401: description:Unauthorized
+	// 403: description:Password or code is incorrect
+	// 404: description:Not enrolled
+	r.HandleFunc("/mfa/totp", s.DisableTOTP).Methods(http.MethodDelete)
+	// swagger:route POST /mfa/recovery-codes RegenerateRecoveryCodes
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:New recovery codes, the old ones stop working
+	// 400: description:Bad request
+	// 401: description:Unauthorized
+	// 403: description:Code is incorrect
+	// 409: description:Two-factor authentication is not enabled
+	r.HandleFunc("/mfa/recovery-codes", s.RegenerateRecoveryCodes).Methods(http.MethodPost)
+	// swagger:route POST /password ChangePassword
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Password Changed, with new tokens when other sessions were revoked
+	// 400: description:Bad request or password does not meet the password policy
+	// 401: description:Unauthorized
+	// 403: description:Current password is incorrect
+	// 429: description:Too Many Requests
 	// 500: description:Internal Server Error
+	r.HandleFunc("/password", s.RateLimiter.Limit("password", s.usernameFromToken, s.ChangePassword)).Methods(http.MethodPost)
+	// swagger:route POST /email/verify VerifyEmail
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Email address verified
+	// 400: description:Bad request, or the link is invalid or expired
+	// 429: description:Too Many Requests
+	r.HandleFunc("/email/verify", s.RateLimiter.Limit("email/verify", nil, s.VerifyEmail)).Methods(http.MethodPost)
+	// swagger:route POST /email/verify/resend ResendEmailVerification
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 202: description:Verification email sent if the address has an unverified account
+	// 400: description:Bad request
+	// 429: description:Too Many Requests
+	r.HandleFunc("/email/verify/resend", s.RateLimiter.Limit("email/verify/resend", emailFromBody, s.ResendEmailVerification)).Methods(http.MethodPost)
+	// swagger:route POST /password/forgot ForgotPassword
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 202: description:Reset email sent if the account exists
+	// 400: description:Bad request
+	// 429: description:Too Many Requests
+	r.HandleFunc("/password/forgot", s.RateLimiter.Limit("password/forgot", usernameFromBody, s.ForgotPassword)).Methods(http.MethodPost)
+	// swagger:route POST /password/reset ResetPassword
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Password Reset
+	// 400: description:Invalid or expired reset token, or password does not meet the password policy
+	// 429: description:Too Many Requests
+	// 500: description:Internal Server Error
+	r.HandleFunc("/password/reset", s.RateLimiter.Limit("password/reset", nil, s.ResetPassword)).Methods(http.MethodPost)
+	// swagger:route POST /token/refresh RefreshToken
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Success, returns a new JWT access token and a new refresh token
+	// 400: description:Bad request
+	// 401: description:Unauthorized, the refresh token is invalid, expired or was already used
+	// 500: description:Internal Server Error
+	r.HandleFunc("/token/refresh", s.RefreshToken).Methods(http.MethodPost)
+	// swagger:route POST /logout Logout
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Logged out, the access token and refresh token are revoked
+	// 400: description:Bad request
+	// 401: description:Unauthorized
+	// 500: description:Internal Server Error
+	r.HandleFunc("/logout", s.Logout).Methods(http.MethodPost)
+	// swagger:route POST /introspect IntrospectToken
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/x-www-form-urlencoded
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Whether the token is active and its claims
+	// 400: description:Bad request
+	// 401: description:Unauthorized, invalid client credentials
+	r.HandleFunc("/introspect", s.IntrospectToken).Methods(http.MethodPost)
+	// swagger:route GET /.well-known/openid-configuration OpenIDConfiguration
+	//
+	// Login Service
+	//
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:OpenID Connect discovery document
+	r.HandleFunc("/.well-known/openid-configuration", s.OpenIDConfiguration).Methods(http.MethodGet)
+	// swagger:route GET /authorize Authorize
+	//
+	// Login Service
+	//
+	// Produces:
+	// - text/html
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Login form
+	// 302: description:Redirect to the client with an error
+	// 400: description:Unknown client or redirect URI
+	r.HandleFunc("/authorize", s.Authorize).Methods(http.MethodGet)
+	// swagger:route POST /authorize AuthorizeLogin
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/x-www-form-urlencoded
+	// Schemes: http, https
+	//
+	// responses:
+	// 302: description:Redirect to the client with an authorization code or an error
+	// 400: description:Unknown client or redirect URI
+	// 401: description:Login form with an error
+	// 429: description:Too Many Requests
+	r.HandleFunc("/authorize", s.RateLimiter.Limit("authorize", usernameFromBody, s.AuthorizeLogin)).Methods(http.MethodPost)
+	// swagger:route POST /token Token
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/x-www-form-urlencoded
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Access token, refresh token and ID token
+	// 400: description:OAuth 2.0 error
+	// 429: description:Too Many Requests
+	// 500: description:Internal Server Error
+	r.HandleFunc("/token", s.RateLimiter.Limit("token", nil, s.Token)).Methods(http.MethodPost)
+	// swagger:route POST /device/code DeviceAuthorization
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/x-www-form-urlencoded
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Device code, user code and verification URI
+	// 400: description:OAuth 2.0 error
+	// 401: description:Invalid client
+	// 500: description:Internal Server Error
+	r.HandleFunc("/device/code", s.DeviceAuthorization).Methods(http.MethodPost)
+	// swagger:route GET /device DeviceVerification
+	//
+	// Login Service
+	//
+	// Produces:
+	// - text/html
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Device verification page
+	r.HandleFunc("/device", s.DeviceVerification).Methods(http.MethodGet)
+	// swagger:route POST /device DeviceVerificationLogin
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/x-www-form-urlencoded
+	// Produces:
+	// - text/html
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Device approved or denied
+	// 401: description:Incorrect username or password
+	// 404: description:Unknown or expired user code
+	// 429: description:Too Many Requests
+	r.HandleFunc("/device", s.RateLimiter.Limit("device", usernameFromBody, s.DeviceVerificationLogin)).Methods(http.MethodPost)
+	// swagger:route POST /device/verify VerifyDeviceCode
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Device approved or denied
+	// 400: description:Bad request
+	// 401: description:Unauthorized
+	// 404: description:Unknown or expired user code
+	// 429: description:Too Many Requests
+	r.HandleFunc("/device/verify", s.RateLimiter.Limit("device", s.usernameFromToken, s.VerifyDeviceCode)).Methods(http.MethodPost)
+	// swagger:route GET /userinfo UserInfo
+	//
+	// Login Service
+	//
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Claims about the user
+	// 401: description:Unauthorized
+	// 403: description:Token was not issued with the openid scope
+	r.HandleFunc("/userinfo", s.UserInfo).Methods(http.MethodGet, http.MethodPost)
+	// swagger:route POST /clients CreateClient
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 201: description:Client registered, the response holds the only copy of the client secret
+	// 400: description:Bad request
+	// 401: description:Unauthorized
+	// 403: description:Forbidden
+	// 409: description:Client ID already exists
+	r.HandleFunc("/clients", s.requireRole(s.AdminRole, s.CreateClient)).Methods(http.MethodPost)
+	// swagger:route GET /clients GetClients
+	//
+	// Login Service
+	//
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Registered clients
+	// 401: description:Unauthorized
+	// 403: description:Forbidden
+	r.HandleFunc("/clients", s.requireRole(s.AdminRole, s.GetClients)).Methods(http.MethodGet)
+	// swagger:route GET /clients/{clientId} GetClient
+	//
+	// Login Service
+	//
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Registered client
+	// 401: description:Unauthorized
+	// 403: description:Forbidden
+	// 404: description:Not Found
+	r.HandleFunc("/clients/{clientId}", s.requireRole(s.AdminRole, s.GetClient)).Methods(http.MethodGet)
+	// swagger:route PUT /clients/{clientId} UpdateClient
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Client updated
+	// 400: description:Bad request
+	// 401: description:Unauthorized
+	// 403: description:Forbidden
+	// 404: description:Not Found
+	r.HandleFunc("/clients/{clientId}", s.requireRole(s.AdminRole, s.UpdateClient)).Methods(http.MethodPut)
+	// swagger:route DELETE /clients/{clientId} DeleteClient
+	//
+	// Login Service
+	//
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Client deleted
+	// 401: description:Unauthorized
+	// 403: description:Forbidden
+	// 404: description:Not Found
+	r.HandleFunc("/clients/{clientId}", s.requireRole(s.AdminRole, s.DeleteClient)).Methods(http.MethodDelete)
+	// swagger:route GET /users/me GetMyProfile
+	//
+	// Login Service
+	//
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Profile of the signed in user, the ETag header holds its version
+	// 401: description:Unauthorized
+	// 404: description:Not Found
+	r.HandleFunc("/users/me", s.GetMyProfile).Methods(http.MethodGet)
+	// swagger:route PATCH /users/me UpdateMyProfile
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/merge-patch+json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Updated profile, the ETag header holds its new version
+	// 400: description:Bad request, or an attribute that can not be changed
+	// 401: description:Unauthorized
+	// 412: description:The profile changed since the ETag in If-Match
+	// 415: description:Unsupported Media Type
+	// 428: description:If-Match header is missing
+	r.HandleFunc("/users/me", s.UpdateMyProfile).Methods(http.MethodPatch)
+	// swagger:route POST /users/me/username ChangeUsername
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Username changed, returns a new JWT access token and refresh token
+	// 400: description:Bad request
+	// 401: description:Unauthorized
+	// 403: description:Password is incorrect
+	// 409: description:Username is taken or reserved
+	// 429: description:Too Many Requests
+	r.HandleFunc("/users/me/username", s.RateLimiter.Limit("users/me/username", s.usernameFromToken, s.ChangeUsername)).Methods(http.MethodPost)
+	// swagger:route DELETE /users/me DeleteAccount
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:User deleted and every token of the user revoked
+	// 400: description:Bad request
+	// 401: description:Unauthorized
+	// 403: description:Password is incorrect
+	// 429: description:Too Many Requests
+	r.HandleFunc("/users/me", s.RateLimiter.Limit("users/me", s.usernameFromToken, s.DeleteAccount)).Methods(http.MethodDelete)
+	// swagger:route DELETE /users/{username} DeleteUser
+	//
+	// Login Service
+	//
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:User deleted and every token of the user revoked
+	// 401: description:Unauthorized
+	// 403: description:Forbidden
+	// 404: description:Not Found
+	r.HandleFunc("/users/{username}", s.requireRole(s.AdminRole, s.DeleteUser)).Methods(http.MethodDelete)
+	// swagger:route POST /users/{username}/restore RestoreUser
+	//
+	// Login Service
+	//
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Soft deleted user restored
+	// 401: description:Unauthorized
+	// 403: description:Forbidden
+	// 404: description:Not Found, or the user is not deleted or already purged
+	r.HandleFunc("/users/{username}/restore", s.requireRole(s.AdminRole, s.RestoreUser)).Methods(http.MethodPost)
+	// swagger:route DELETE /users/{username}/sessions RevokeUserSessions
+	//
+	// Login Service
+	//
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Every token of the user is revoked
+	// 401: description:Unauthorized
+	// 403: description:Forbidden
+	// 500: description:Internal Server Error
+	r.HandleFunc("/users/{username}/sessions", s.requireRole(s.AdminRole, s.RevokeUserSessions)).Methods(http.MethodDelete)
+	// swagger:route GET /users/{username}/lock GetLockState
+	//
+	// Login Service
+	//
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Lock state of the user
+	// 401: description:Unauthorized
+	// 403: description:Forbidden
+	// 404: description:User not found
+	r.HandleFunc("/users/{username}/lock", s.requireRole(s.AdminRole, s.GetLockState)).Methods(http.MethodGet)
+	// swagger:route DELETE /users/{username}/lock UnlockUser
+	//
+	// Login Service
+	//
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:User Unlocked
+	// 401: description:Unauthorized
+	// 403: description:Forbidden
+	// 404: description:User not found
+	r.HandleFunc("/users/{username}/lock", s.requireRole(s.AdminRole, s.UnlockUser)).Methods(http.MethodDelete)
+	// swagger:route DELETE /users/{username}/mfa ResetUserMFA
+	//
+	// Login Service
+	//
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Two-factor authentication disabled
+	// 401: description:Unauthorized
+	// 403: description:Forbidden
+	// 404: description:User not found or not enrolled
+	r.HandleFunc("/users/{username}/mfa", s.requireRole(s.AdminRole, s.ResetUserMFA)).Methods(http.MethodDelete)
+	// swagger:route GET /profile GetUserProfile
+	//
+	// Login Service
+	//
+	// Consumes:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200:	User
+	// 401: description:Unauthorized, missing, expired, not yet valid or wrong issuer or audience token
 	r.HandleFunc("/profile", s.GetUserProfile).Methods(http.MethodGet)
+	// swagger:route GET /.well-known/jwks.json GetJSONWebKeySet
+	//
+	// Login Service
+	//
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Public keys used to verify tokens
+	r.HandleFunc("/.well-known/jwks.json", s.GetJSONWebKeySet).Methods(http.MethodGet)
+	// swagger:route GET /keys GetSigningKeys
+	//
+	// Login Service
+	//
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Signing keys and their status
+	// 401: description:Unauthorized
+	// 403: description:Forbidden
+	r.HandleFunc("/keys", s.requireRole(s.AdminRole, s.GetSigningKeys)).Methods(http.MethodGet)
+	// swagger:route POST /keys/rotate RotateSigningKeys
+	//
+	// Login Service
+	//
+	// Produces:
+	// - application/json
+	// Schemes: http, https
+	//
+	// responses:
+	// 200: description:Promoted signing key
+	// 401: description:Unauthorized
+	// 403: description:Forbidden
+	// 500: description:Internal Server Error
+	r.HandleFunc("/keys/rotate", s.requireRole(s.AdminRole, s.RotateSigningKeys)).Methods(http.MethodPost)
 
 	/*r.HandleFunc("/role", s.CreateRole).Methods(http.MethodPost)
 
@@ -124,25 +948,78 @@ func (s *LoginService) healthCheck(database LoginDatabase) http.Handler {
 	})
 }
 
+// RegisterRequest is the body of POST /register
+type RegisterRequest struct {
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+	Email     string `json:"email"`
+}
+
 // RegisterUser allows a user to register if a username does not already exist
 func (s *LoginService) RegisterUser(w http.ResponseWriter, r *http.Request) {
 	log.Infof("RegisterUser invoked with URL: %v", r.URL)
 	defer r.Body.Close()
 
-	var user models.User
+	var request RegisterRequest
 
-	err := json.NewDecoder(r.Body).Decode(&user)
+	err := json.NewDecoder(r.Body).Decode(&request)
 	if err != nil {
 		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
 		return
 	}
 
+	// Only the fields a user may choose are copied, roles and everything else start out empty
+	user := models.User{
+		Username:  request.Username,
+		Password:  request.Password,
+		FirstName: request.FirstName,
+		LastName:  request.LastName,
+		Email:     request.Email,
+	}
+
+	// Usernames that are email addresses were the only way to reach users before there was an email field
+	if user.Email == "" && mail.IsAddress(user.Username) {
+		user.Email = user.Username
+	}
+	if user.Email != "" {
+		user.Email, err = mail.NormalizeAddress(user.Email)
+		if err != nil {
+			api.RespondWithError(w, http.StatusBadRequest, "Invalid email address")
+			return
+		}
+	} else if s.EmailVerificationRequired {
+		api.RespondWithError(w, http.StatusBadRequest, "An email address is required")
+		return
+	}
+
+	if !s.checkPasswordPolicy(w, user.Password, &user) {
+		return
+	}
+
 	err = s.Database.RegisterUser(&user)
+	if errors.Is(err, models.ErrEmailTaken) {
+		// A taken address gets the same answer as a registration, the owner of the address is told by email instead
+		go s.sendRegistrationNotice(user.Email)
+		api.RespondWithJSON(w, http.StatusOK, registrationResponse)
+		return
+	}
+	if errors.Is(err, models.ErrUsernameTaken) || errors.Is(err, models.ErrUsernameReserved) {
+		api.RespondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
 	if err != nil {
 		api.RespondWithError(w, api.CheckError(err), err.Error())
 		return
 	}
 
+	if user.Email != "" {
+		go s.sendEmailVerification(&user)
+		api.RespondWithJSON(w, http.StatusOK, registrationResponse)
+		return
+	}
+
 	api.RespondWithJSON(w, http.StatusOK, "User Created")
 }
 
@@ -159,48 +1036,58 @@ func (s *LoginService) LoginUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := s.Database.LoginUser(&user)
+	authenticated, err := s.Database.AuthenticateUser(&user)
+	if errors.Is(err, password.ErrInvalidCredentials) {
+		api.RespondWithError(w, http.StatusUnauthorized, "Incorrect username or password")
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+	if s.checkEmailVerified(authenticated) != nil {
+		api.RespondWithError(w, http.StatusForbidden, "Verify your email address before logging in")
+		return
+	}
+
+	// Users with a second factor get an interim token to finish at /login/mfa instead of tokens
+	if authenticated.MFAEnabled() {
+		s.respondWithMFARequired(w, authenticated)
+		return
+	}
+
+	accessToken, _, err := s.Tokens.Issue(authenticated, "")
 	if err != nil {
 		api.RespondWithError(w, api.CheckError(err), err.Error())
 		return
 	}
 
-	api.RespondWithJSON(w, http.StatusOK, token)
+	response, err := s.newTokenResponse(authenticated, "", accessToken)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, response)
 }
 
 // GetUserProfile returns all the information for users
 func (s *LoginService) GetUserProfile(w http.ResponseWriter, r *http.Request) {
 	log.Infof("GetUserProfile invoked with URL: %v", r.URL)
-	tokenString := r.Header.Get("Authorization")
-	if strings.Contains(tokenString, "Bearer") {
-		tokenString = strings.Trim(tokenString, "Bearer")
-		tokenString = strings.Trim(tokenString, " ")
-	}
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return []byte("secret"), nil
-	})
-
-	var result models.User
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		result.Username = claims["username"].(string)
-		result.FirstName = claims["firstname"].(string)
-		result.LastName = claims["lastname"].(string)
-		roles := claims["roles"].([]interface{})
-		for _, role := range roles {
-			str, _ := json.Marshal(role)
-			var roleValue models.Role
-			json.Unmarshal(str, &roleValue)
-			result.Roles = append(result.Roles, roleValue)
-		}
-		log.Info(result)
-		api.RespondWithJSON(w, http.StatusOK, result)
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
 		return
 	}
 
-	api.RespondWithError(w, api.CheckError(err), err.Error())
+	result := models.User{
+		Username:  claims.Username,
+		FirstName: claims.FirstName,
+		LastName:  claims.LastName,
+		Roles:     claims.Roles,
+	}
+	log.Info(result)
+	api.RespondWithJSON(w, http.StatusOK, result)
 }
 
 // CreateRole is the handler func to add a role to the roles collection
@@ -0,0 +1,660 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/audit"
+	"github.com/geeksheik9/login-service/pkg/mail"
+	"github.com/geeksheik9/login-service/pkg/password"
+	"github.com/geeksheik9/login-service/pkg/token"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fakeDatabase keeps users in memory, methods a test does not need are left to the embedded nil interface and panic
+// when called
+type fakeDatabase struct {
+	LoginDatabase
+
+	users         map[string]*models.User
+	refreshTokens map[string]*models.RefreshToken
+	reservations  map[string]primitive.ObjectID
+	clients       map[string]*models.Client
+	deleted       map[string]*models.User
+	purgeDelay    time.Duration
+	// beforeRevoke runs when a user's refresh tokens are about to be revoked
+	beforeRevoke func()
+}
+
+func newFakeDatabase(users ...*models.User) *fakeDatabase {
+	database := &fakeDatabase{
+		users:         map[string]*models.User{},
+		refreshTokens: map[string]*models.RefreshToken{},
+		reservations:  map[string]primitive.ObjectID{},
+		clients:       map[string]*models.Client{},
+		deleted:       map[string]*models.User{},
+	}
+	for _, user := range users {
+		database.users[user.Username] = user
+	}
+
+	return database
+}
+
+// RegisterUser refuses taken usernames, reserved usernames and taken email addresses, like the real one
+func (f *fakeDatabase) RegisterUser(user *models.User) error {
+	if _, taken := f.users[user.Username]; taken {
+		return models.ErrUsernameTaken
+	}
+	for _, existing := range f.users {
+		if user.Email != "" && existing.Email == user.Email {
+			return models.ErrEmailTaken
+		}
+	}
+	if _, reserved := f.reservations[user.Username]; reserved {
+		return models.ErrUsernameReserved
+	}
+
+	user.ID = primitive.NewObjectID()
+	stored := *user
+	f.users[user.Username] = &stored
+
+	return nil
+}
+
+func (f *fakeDatabase) GetUser(username string) (*models.User, error) {
+	user, ok := f.users[username]
+	if !ok {
+		return nil, errNotFound
+	}
+	found := *user
+
+	return &found, nil
+}
+
+func (f *fakeDatabase) GetUserByID(userID string) (*models.User, error) {
+	for _, user := range f.users {
+		if user.ID.Hex() == userID {
+			return f.GetUser(user.Username)
+		}
+	}
+
+	return nil, errNotFound
+}
+
+// RenameUser keeps the rules of the real one, taken and reserved names are refused unless the reservation is the
+// user's own, and the old name is reserved for the user
+func (f *fakeDatabase) RenameUser(username string, newUsername string) error {
+	user, ok := f.users[username]
+	if !ok {
+		return errNotFound
+	}
+	if _, taken := f.users[newUsername]; taken {
+		return models.ErrUsernameTaken
+	}
+	if id, reserved := f.reservations[newUsername]; reserved && id != user.ID {
+		return models.ErrUsernameReserved
+	}
+
+	f.reservations[username] = user.ID
+	delete(f.reservations, newUsername)
+	delete(f.users, username)
+	user.Username = newUsername
+	f.users[newUsername] = user
+
+	return nil
+}
+
+func (f *fakeDatabase) CheckPasswordHistory(username string, newPassword string) error {
+	if f.users[username].Password == newPassword {
+		return password.ErrPasswordReused
+	}
+
+	return nil
+}
+
+func (f *fakeDatabase) ChangePassword(username string, newPassword string) error {
+	f.users[username].Password = newPassword
+
+	return nil
+}
+
+func (f *fakeDatabase) GetLockState(username string) (*models.LockState, error) {
+	user, ok := f.users[username]
+	if !ok {
+		return nil, errNotFound
+	}
+
+	return &models.LockState{Username: username, FailedLogins: user.FailedLogins, Lockouts: user.Lockouts,
+		Locked: user.LockedUntil != nil, LockedUntil: user.LockedUntil}, nil
+}
+
+func (f *fakeDatabase) UnlockUser(username string) error {
+	user, ok := f.users[username]
+	if !ok {
+		return errNotFound
+	}
+	user.FailedLogins, user.Lockouts, user.LockedUntil = 0, 0, nil
+
+	return nil
+}
+
+// DeleteUser moves users to deleted while there is a purge delay, the same split between soft deletes and purges as
+// the real one
+func (f *fakeDatabase) DeleteUser(username string, purge bool) (*time.Time, error) {
+	user, active := f.users[username]
+	_, deleted := f.deleted[username]
+	if !active && (!purge || !deleted) {
+		return nil, errNotFound
+	}
+	delete(f.users, username)
+
+	if f.purgeDelay > 0 && !purge {
+		purgeAt := time.Now().Add(f.purgeDelay)
+		f.deleted[username] = user
+		return &purgeAt, nil
+	}
+	delete(f.deleted, username)
+
+	return nil, nil
+}
+
+func (f *fakeDatabase) RestoreUser(username string) error {
+	user, ok := f.deleted[username]
+	if !ok {
+		return errNotFound
+	}
+	delete(f.deleted, username)
+	f.users[username] = user
+
+	return nil
+}
+
+// UpdateProfile refuses a version that is not the stored one, like the real one
+func (f *fakeDatabase) UpdateProfile(username string, version int64, changes map[string]*string) (*models.User, error) {
+	user, ok := f.users[username]
+	if !ok {
+		return nil, errNotFound
+	}
+	if user.Version != version {
+		return nil, models.ErrVersionConflict
+	}
+
+	fields := map[string]*string{"firstName": &user.FirstName, "lastName": &user.LastName}
+	for field, value := range changes {
+		*fields[field] = ""
+		if value != nil {
+			*fields[field] = *value
+		}
+	}
+	user.Version++
+
+	return f.GetUser(username)
+}
+
+// AuthenticateUser compares passwords as they were given, the fake never hashes them
+func (f *fakeDatabase) AuthenticateUser(user *models.User) (*models.User, error) {
+	found, err := f.GetUser(user.Username)
+	if err != nil || found.Password != user.Password {
+		return nil, errors.New("Incorrect username or password")
+	}
+
+	return found, nil
+}
+
+func (f *fakeDatabase) VerifyEmail(userID string, email string) error {
+	id, err := api.StringToObjectID(userID)
+	if err != nil {
+		return err
+	}
+	for _, user := range f.users {
+		if user.ID == id && user.Email == email {
+			user.EmailVerified = true
+			return nil
+		}
+	}
+
+	return errNotFound
+}
+
+func (f *fakeDatabase) GetClient(clientID string) (*models.Client, error) {
+	client, ok := f.clients[clientID]
+	if !ok {
+		return nil, errNotFound
+	}
+
+	return client, nil
+}
+
+// AuthenticateClient compares secrets as they were given, the fake never hashes them
+func (f *fakeDatabase) AuthenticateClient(clientID string, secret string) (*models.Client, error) {
+	client, err := f.GetClient(clientID)
+	if err != nil || client.Public || client.Secret != secret {
+		return nil, errors.New("incorrect client secret")
+	}
+
+	return client, nil
+}
+
+func (f *fakeDatabase) CreateRefreshToken(refreshToken *models.RefreshToken) error {
+	stored := *refreshToken
+	f.refreshTokens[refreshToken.Hash] = &stored
+
+	return nil
+}
+
+func (f *fakeDatabase) GetRefreshToken(hash string) (*models.RefreshToken, error) {
+	refreshToken, ok := f.refreshTokens[hash]
+	if !ok {
+		return nil, errNotFound
+	}
+	found := *refreshToken
+
+	return &found, nil
+}
+
+// RotateRefreshToken refuses a refresh token that was used already, like the real one
+func (f *fakeDatabase) RotateRefreshToken(hash string, next *models.RefreshToken) error {
+	refreshToken, ok := f.refreshTokens[hash]
+	if !ok {
+		return errNotFound
+	}
+	if refreshToken.UsedAt != nil {
+		return token.ErrRefreshTokenReused
+	}
+	now := time.Now()
+	refreshToken.UsedAt = &now
+
+	return f.CreateRefreshToken(next)
+}
+
+func (f *fakeDatabase) RevokeRefreshTokens(user *models.User) error {
+	if f.beforeRevoke != nil {
+		f.beforeRevoke()
+	}
+	for _, refreshToken := range f.refreshTokens {
+		if refreshToken.UserID == user.ID {
+			refreshToken.Revoked = true
+		}
+	}
+
+	return nil
+}
+
+// memoryRevocationStore keeps revocations for the revocation list of the test service
+type memoryRevocationStore struct {
+	revocations []models.Revocation
+}
+
+func (m *memoryRevocationStore) GetRevocations() ([]models.Revocation, error) {
+	return m.revocations, nil
+}
+
+func (m *memoryRevocationStore) SaveRevocation(revocation *models.Revocation) error {
+	m.revocations = append(m.revocations, *revocation)
+	return nil
+}
+
+// memoryRecorder keeps audit events for the tests to look at
+type memoryRecorder struct {
+	events []audit.Event
+}
+
+func (m *memoryRecorder) Record(event *audit.Event) error {
+	m.events = append(m.events, *event)
+	return nil
+}
+
+type fakeError string
+
+func (e fakeError) Error() string { return string(e) }
+
+const errNotFound = fakeError("mongo: no documents in result")
+
+func newTestService(t *testing.T, database LoginDatabase) *LoginService {
+	keys, err := token.NewHMACKeyProvider([]byte("a-much-better-secret"))
+	if err != nil {
+		t.Fatalf("NewHMACKeyProvider() returned error: %v", err)
+	}
+
+	revocations, err := token.NewRevocationList(&memoryRevocationStore{}, time.Hour)
+	if err != nil {
+		t.Fatalf("NewRevocationList() returned error: %v", err)
+	}
+
+	return &LoginService{
+		Database: database,
+		Tokens: &token.Issuer{Keys: keys, Issuer: "login-service", Audience: "games", Lifetime: time.Hour,
+			Revocations: revocations},
+		AdminRole:       "admin",
+		RefreshLifetime: time.Hour,
+	}
+}
+
+// serve calls the handler with a JSON body, a non empty bearer token is sent in the Authorization header
+func serve(handler http.HandlerFunc, method string, body interface{}, bearer string) *httptest.ResponseRecorder {
+	var buffer bytes.Buffer
+	if body != nil {
+		_ = json.NewEncoder(&buffer).Encode(body)
+	}
+
+	r := httptest.NewRequest(method, "/", &buffer)
+	r.Header.Set("Content-Type", "application/json")
+	if bearer != "" {
+		r.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	return w
+}
+
+func TestRegisterUser_IgnoresRoles(t *testing.T) {
+	database := newFakeDatabase()
+	s := newTestService(t, database)
+
+	body := map[string]interface{}{
+		"username": "mallory",
+		"password": "correct horse battery staple",
+		"roles":    []map[string]string{{"name": "admin"}},
+		"token":    "forged",
+	}
+	w := serve(s.RegisterUser, http.MethodPost, body, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("RegisterUser() error:\n   expected: %v\n   got:      %v %s", http.StatusOK, w.Code, w.Body)
+	}
+
+	stored := database.users["mallory"]
+	if stored == nil || len(stored.Roles) != 0 || stored.Token != "" {
+		t.Fatalf("RegisterUser() stored user error:\n   expected: no roles and no token\n   got:      %+v", stored)
+	}
+
+	_, claims, err := s.Tokens.Issue(stored, "")
+	if err != nil {
+		t.Fatalf("Issue() returned error: %v", err)
+	}
+	if claims.HasRole("admin") {
+		t.Errorf("RegisterUser() error:\n   expected: a token without the admin role\n   got:      %v", claims.Roles)
+	}
+}
+
+func TestRegisterUser_VerificationEmailVerifies(t *testing.T) {
+	database := newFakeDatabase()
+	mailer := &mail.MemoryMailer{}
+	s := newTestService(t, database)
+	s.Mailer = mailer
+	s.EmailVerificationLifetime = time.Hour
+
+	body := map[string]string{"username": "alice", "password": "correct horse battery staple", "email": "alice@example.com"}
+	w := serve(s.RegisterUser, http.MethodPost, body, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("RegisterUser() error:\n   expected: %v\n   got:      %v %s", http.StatusOK, w.Code, w.Body)
+	}
+
+	// The email is sent in the background
+	deadline := time.Now().Add(time.Second)
+	for len(mailer.Messages()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	messages := mailer.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("RegisterUser() error:\n   expected: 1 verification email\n   got:      %v", len(messages))
+	}
+
+	var code string
+	for _, line := range strings.Split(messages[0].Body, "\n") {
+		if strings.Count(line, ".") == 2 && !strings.Contains(line, " ") {
+			code = line
+		}
+	}
+
+	w = serve(s.VerifyEmail, http.MethodPost, map[string]string{"token": code}, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("VerifyEmail() error:\n   expected: %v\n   got:      %v %s", http.StatusOK, w.Code, w.Body)
+	}
+	if !database.users["alice"].EmailVerified {
+		t.Errorf("VerifyEmail() error:\n   expected: a verified email address\n   got:      %+v", database.users["alice"])
+	}
+}
+
+func TestRegisterUser_Conflicts(t *testing.T) {
+	alice := &models.User{ID: primitive.NewObjectID(), Username: "alice", Email: "alice@example.com"}
+	database := newFakeDatabase(alice)
+	database.reservations["alicia"] = alice.ID
+	mailer := &mail.MemoryMailer{}
+	s := newTestService(t, database)
+	s.Mailer = mailer
+
+	tests := []struct {
+		name     string
+		username string
+		email    string
+		expected int
+	}{
+		{"taken username", "alice", "", http.StatusConflict},
+		{"reserved username", "alicia", "", http.StatusConflict},
+		{"taken email", "mallory", "alice@example.com", http.StatusOK},
+		{"new email", "bob", "bob@example.com", http.StatusOK},
+	}
+	bodies := map[string]string{}
+	for _, test := range tests {
+		body := map[string]string{"username": test.username, "password": "correct horse battery staple", "email": test.email}
+		w := serve(s.RegisterUser, http.MethodPost, body, "")
+		if w.Code != test.expected {
+			t.Errorf("RegisterUser() %s error:\n   expected: %v\n   got:      %v %s", test.name, test.expected, w.Code, w.Body)
+		}
+		bodies[test.name] = w.Body.String()
+	}
+
+	// A taken email address can not be told apart from a registration, its owner is told by email
+	if bodies["taken email"] != bodies["new email"] {
+		t.Errorf("RegisterUser() taken email error:\n   expected: %s\n   got:      %s", bodies["new email"], bodies["taken email"])
+	}
+	if database.users["mallory"] != nil {
+		t.Errorf("RegisterUser() taken email error:\n   expected: no user created\n   got:      %+v", database.users["mallory"])
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(mailer.Messages()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	recipients := map[string]bool{}
+	for _, message := range mailer.Messages() {
+		recipients[message.To] = true
+	}
+	if !recipients["alice@example.com"] || !recipients["bob@example.com"] {
+		t.Errorf("RegisterUser() error:\n   expected: emails to alice and bob\n   got:      %v", recipients)
+	}
+}
+
+func TestDeviceVerificationLogin_SecondFactor(t *testing.T) {
+	database := newFakeDatabase(
+		&models.User{Username: "passkey", Password: "pw", WebAuthnCredentials: []models.WebAuthnCredential{{ID: "credential"}}},
+		&models.User{Username: "totp", Password: "pw", TOTP: &models.TOTP{Secret: "JBSWY3DPEHPK3PXP", Confirmed: true}},
+	)
+	s := newTestService(t, database)
+
+	tests := []struct {
+		username string
+		message  string
+	}{
+		{"passkey", "Sign in with your passkey through /login"},
+		{"totp", "Enter a valid code from your authenticator app"},
+	}
+	for _, test := range tests {
+		form := url.Values{"user_code": {"BKTZ-QMRW"}, "username": {test.username}, "password": {"pw"}, "action": {"approve"}}
+		r := httptest.NewRequest(http.MethodPost, "/device", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.DeviceVerificationLogin(w, r)
+
+		if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), test.message) {
+			t.Errorf("DeviceVerificationLogin(%s) error:\n   expected: %v %q\n   got:      %v %s", test.username, http.StatusUnauthorized, test.message, w.Code, w.Body)
+		}
+	}
+}
+
+func TestIntrospectRefreshToken_SubjectIsUserID(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Username: "alice"}
+	s := newTestService(t, newFakeDatabase(user))
+
+	response, err := s.newTokenResponse(user, "", "access")
+	if err != nil {
+		t.Fatalf("newTokenResponse() returned error: %v", err)
+	}
+
+	introspection := s.introspectRefreshToken(response.RefreshToken)
+	if !introspection.Active || introspection.Subject != user.ID.Hex() || introspection.Username != "alice" {
+		t.Errorf("introspectRefreshToken() error:\n   expected: active with subject %v\n   got:      %+v", user.ID.Hex(), introspection)
+	}
+}
+
+func TestChangeUsername_RevokesOldSessions(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Username: "alice", Password: "pw"}
+	database := newFakeDatabase(user)
+	s := newTestService(t, database)
+
+	oldToken, _, _ := s.Tokens.Issue(user, "")
+	oldSession, err := s.newTokenResponse(user, "", oldToken)
+	if err != nil {
+		t.Fatalf("newTokenResponse() returned error: %v", err)
+	}
+
+	w := serve(s.ChangeUsername, http.MethodPost, ChangeUsernameRequest{NewUsername: "alicia", Password: "pw"}, oldToken)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ChangeUsername() error:\n   expected: %v\n   got:      %v %s", http.StatusOK, w.Code, w.Body)
+	}
+
+	_, err = s.Tokens.Validate(oldToken)
+	if !errors.Is(err, token.ErrTokenRevoked) {
+		t.Errorf("Validate() old token error:\n   expected: %v\n   got:      %v", token.ErrTokenRevoked, err)
+	}
+	refreshToken, _ := database.GetRefreshToken(token.HashOpaqueToken(oldSession.RefreshToken))
+	if !refreshToken.Revoked {
+		t.Errorf("ChangeUsername() error:\n   expected: the old refresh token revoked\n   got:      %+v", refreshToken)
+	}
+
+	var response models.TokenResponse
+	_ = json.NewDecoder(w.Body).Decode(&response)
+	claims, err := s.Tokens.Validate(response.AccessToken)
+	if err != nil || claims.Username != "alicia" || claims.Subject != user.ID.Hex() {
+		t.Errorf("ChangeUsername() new token error:\n   expected: alicia with subject %v\n   got:      %+v %v", user.ID.Hex(), claims, err)
+	}
+}
+
+func TestChangeUsername_Conflicts(t *testing.T) {
+	alice := &models.User{ID: primitive.NewObjectID(), Username: "alice", Password: "pw"}
+	bob := &models.User{ID: primitive.NewObjectID(), Username: "bob", Password: "pw"}
+	s := newTestService(t, newFakeDatabase(alice, bob))
+
+	aliceToken, _, _ := s.Tokens.Issue(alice, "")
+	bobToken, _, _ := s.Tokens.Issue(bob, "")
+
+	tests := []struct {
+		name     string
+		bearer   *string
+		username string
+		expected int
+	}{
+		{"taken", &aliceToken, "bob", http.StatusConflict},
+		{"rename", &aliceToken, "alicia", http.StatusOK},
+		{"reserved for someone else", &bobToken, "alice", http.StatusConflict},
+		{"reclaiming your own name", &aliceToken, "alice", http.StatusOK},
+	}
+	for _, test := range tests {
+		w := serve(s.ChangeUsername, http.MethodPost, ChangeUsernameRequest{NewUsername: test.username, Password: "pw"}, *test.bearer)
+		if w.Code != test.expected {
+			t.Fatalf("ChangeUsername() %s error:\n   expected: %v\n   got:      %v %s", test.name, test.expected, w.Code, w.Body)
+		}
+
+		// The old token is revoked by a rename, the caller carries on with the new one
+		if w.Code == http.StatusOK {
+			var response models.TokenResponse
+			_ = json.NewDecoder(w.Body).Decode(&response)
+			*test.bearer = response.AccessToken
+		}
+	}
+}
+
+func TestAuthenticateUser_RefusesClientTokens(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Username: "alice"}
+	s := newTestService(t, newFakeDatabase(user))
+
+	clientToken, _, _ := s.Tokens.IssueClientToken("service", "")
+	userToken, _, _ := s.Tokens.Issue(user, "")
+
+	w := serve(s.GetMyProfile, http.MethodGet, nil, clientToken)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GetMyProfile() client token error:\n   expected: %v\n   got:      %v %s", http.StatusUnauthorized, w.Code, w.Body)
+	}
+
+	w = serve(s.GetMyProfile, http.MethodGet, nil, userToken)
+	if w.Code != http.StatusOK {
+		t.Errorf("GetMyProfile() user token error:\n   expected: %v\n   got:      %v %s", http.StatusOK, w.Code, w.Body)
+	}
+}
+
+func TestAuthenticateUser_RefusesClientUserTokens(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Username: "alice"}
+	s := newTestService(t, newFakeDatabase(user))
+
+	clientToken, _, _ := s.Tokens.IssueForClient(user, "app", "openid")
+
+	w := serve(s.GetMyProfile, http.MethodGet, nil, clientToken)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("GetMyProfile() client user token error:\n   expected: %v\n   got:      %v %s", http.StatusUnauthorized, w.Code, w.Body)
+	}
+
+	w = serve(s.UserInfo, http.MethodGet, nil, clientToken)
+	if w.Code != http.StatusOK {
+		t.Errorf("UserInfo() client user token error:\n   expected: %v\n   got:      %v %s", http.StatusOK, w.Code, w.Body)
+	}
+}
+
+func TestVerifyDeviceCode_RefusesClientUserTokens(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Username: "alice"}
+	s := newTestService(t, newFakeDatabase(user))
+
+	// A device that got a token for the user must not approve more devices with it
+	clientToken, _, _ := s.Tokens.IssueForClient(user, "tv", "")
+
+	w := serve(s.VerifyDeviceCode, http.MethodPost, map[string]interface{}{"userCode": "BKTZ-QMRW", "approve": true}, clientToken)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("VerifyDeviceCode() client user token error:\n   expected: %v\n   got:      %v %s", http.StatusUnauthorized, w.Code, w.Body)
+	}
+}
+
+func TestBeginWebAuthnRegistration_RequiresPasswordAndSecondFactor(t *testing.T) {
+	plain := &models.User{ID: primitive.NewObjectID(), Username: "plain", Password: "pw"}
+	totp := &models.User{ID: primitive.NewObjectID(), Username: "totp", Password: "pw", TOTP: &models.TOTP{Secret: "JBSWY3DPEHPK3PXP", Confirmed: true}}
+	passkey := &models.User{ID: primitive.NewObjectID(), Username: "passkey", Password: "pw", WebAuthnCredentials: []models.WebAuthnCredential{{ID: "credential"}}}
+	s := newTestService(t, newFakeDatabase(plain, totp, passkey))
+
+	tests := []struct {
+		name    string
+		user    *models.User
+		request BeginWebAuthnRegistrationRequest
+		code    int
+	}{
+		{"missing password", plain, BeginWebAuthnRegistrationRequest{}, http.StatusBadRequest},
+		{"wrong password", plain, BeginWebAuthnRegistrationRequest{Password: "wrong"}, http.StatusForbidden},
+		{"missing code", totp, BeginWebAuthnRegistrationRequest{Password: "pw"}, http.StatusForbidden},
+		{"missing assertion", passkey, BeginWebAuthnRegistrationRequest{Password: "pw", Code: "123456"}, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		accessToken, _, _ := s.Tokens.Issue(test.user, "")
+
+		w := serve(s.BeginWebAuthnRegistration, http.MethodPost, test.request, accessToken)
+		if w.Code != test.code {
+			t.Errorf("BeginWebAuthnRegistration() %s error:\n   expected: %v\n   got:      %v %s", test.name, test.code, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// IntrospectToken tells an authenticated client whether a token is active as described in RFC 7662, access tokens go
+// through the same validation as every other token check, including expiry and revocation
+func (s *LoginService) IntrospectToken(w http.ResponseWriter, r *http.Request) {
+	log.Infof("IntrospectToken invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	err := r.ParseForm()
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	_, err = s.authenticateIntrospectionClient(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="introspect"`)
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	tokenString := r.PostFormValue("token")
+	if tokenString == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Missing token")
+		return
+	}
+
+	if r.PostFormValue("token_type_hint") == "refresh_token" {
+		api.RespondWithJSON(w, http.StatusOK, s.introspectRefreshToken(tokenString))
+		return
+	}
+
+	response := s.introspectAccessToken(tokenString)
+	if !response.Active {
+		// The hint is optional, so an unknown token may still be a refresh token
+		response = s.introspectRefreshToken(tokenString)
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, response)
+}
+
+func (s *LoginService) introspectAccessToken(tokenString string) models.IntrospectionResponse {
+	claims, err := s.Tokens.Validate(tokenString)
+	if err != nil {
+		return models.IntrospectionResponse{Active: false}
+	}
+
+	return models.IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		Username:  claims.Username,
+		TokenType: "Bearer",
+		ExpiresAt: claims.ExpiresAt,
+		IssuedAt:  claims.IssuedAt,
+		NotBefore: claims.NotBefore,
+		Subject:   claims.Subject,
+		Audience:  claims.Audience,
+		Issuer:    claims.Issuer,
+		JTI:       claims.Id,
+		Roles:     claims.Roles,
+	}
+}
+
+func (s *LoginService) introspectRefreshToken(tokenString string) models.IntrospectionResponse {
+	stored, err := s.Database.GetRefreshToken(token.HashOpaqueToken(tokenString))
+	if err != nil || stored.UsedAt != nil || stored.Revoked || time.Now().After(stored.ExpiresAt) {
+		return models.IntrospectionResponse{Active: false}
+	}
+
+	// The subject is the user's ID, the same as in the access tokens
+	user, err := s.refreshTokenUser(stored)
+	if err != nil {
+		return models.IntrospectionResponse{Active: false}
+	}
+
+	return models.IntrospectionResponse{
+		Active:    true,
+		Username:  user.Username,
+		TokenType: "refresh_token",
+		ExpiresAt: stored.ExpiresAt.Unix(),
+		IssuedAt:  stored.CreatedAt.Unix(),
+		Subject:   user.ID.Hex(),
+		Issuer:    s.Tokens.Issuer,
+		ClientID:  stored.ClientID,
+	}
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// GetJSONWebKeySet publishes the public keys tokens can be verified with, HMAC secrets are never included
+func (s *LoginService) GetJSONWebKeySet(w http.ResponseWriter, r *http.Request) {
+	log.Infof("GetJSONWebKeySet invoked with URL: %v", r.URL)
+
+	api.RespondWithJSON(w, http.StatusOK, token.NewJSONWebKeySet(s.Keys.VerificationKeys()))
+}
+
+// GetSigningKeys lists the signing keys and their status without any key material
+func (s *LoginService) GetSigningKeys(w http.ResponseWriter, r *http.Request) {
+	log.Infof("GetSigningKeys invoked with URL: %v", r.URL)
+
+	api.RespondWithJSON(w, http.StatusOK, s.KeyManager.Records())
+}
+
+// RotateSigningKeys generates and promotes a new signing key, the previous key keeps verifying for the grace period
+func (s *LoginService) RotateSigningKeys(w http.ResponseWriter, r *http.Request) {
+	log.Infof("RotateSigningKeys invoked with URL: %v", r.URL)
+
+	key, err := s.KeyManager.Rotate()
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	log.Infof("Promoted signing key %v", key.KeyID)
+	api.RespondWithJSON(w, http.StatusOK, key)
+}
@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/geeksheik9/login-service/pkg/api"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// GetLockState is the admin operation showing whether a user is locked out
+func (s *LoginService) GetLockState(w http.ResponseWriter, r *http.Request) {
+	log.Infof("GetLockState invoked with URL: %v", r.URL)
+
+	state, err := s.Database.GetLockState(mux.Vars(r)["username"])
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, state)
+}
+
+// UnlockUser is the admin operation lifting a lockout and clearing the failed logins of a user
+func (s *LoginService) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	log.Infof("UnlockUser invoked with URL: %v", r.URL)
+
+	err := s.Database.UnlockUser(mux.Vars(r)["username"])
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, "User Unlocked")
+}
@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/gorilla/mux"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestUnlockUser(t *testing.T) {
+	lockedUntil := time.Now().Add(time.Hour)
+	locked := &models.User{ID: primitive.NewObjectID(), Username: "alice", FailedLogins: 2, Lockouts: 3, LockedUntil: &lockedUntil}
+	admin := &models.User{ID: primitive.NewObjectID(), Username: "admin", Roles: []models.Role{{Name: "admin"}}}
+	database := newFakeDatabase(locked, admin)
+	s := newTestService(t, database)
+
+	router := mux.NewRouter()
+	router.HandleFunc("/users/{username}/lock", s.requireRole(s.AdminRole, s.UnlockUser)).Methods(http.MethodDelete)
+	adminToken, _, _ := s.Tokens.Issue(admin, "")
+	userToken, _, _ := s.Tokens.Issue(locked, "")
+
+	tests := []struct {
+		name     string
+		bearer   string
+		username string
+		expected int
+	}{
+		{"not an admin", userToken, "alice", http.StatusForbidden},
+		{"unknown user", adminToken, "nobody", http.StatusNotFound},
+		{"admin", adminToken, "alice", http.StatusOK},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodDelete, "/users/"+test.username+"/lock", nil)
+		r.Header.Set("Authorization", "Bearer "+test.bearer)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+
+		if w.Code != test.expected {
+			t.Errorf("UnlockUser() %s error:\n   expected: %v\n   got:      %v %s", test.name, test.expected, w.Code, w.Body)
+		}
+		if test.expected == http.StatusForbidden && database.users["alice"].LockedUntil == nil {
+			t.Errorf("UnlockUser() %s error:\n   expected: alice still locked\n   got:      unlocked", test.name)
+		}
+	}
+
+	state, _ := database.GetLockState("alice")
+	if state.Locked || state.FailedLogins != 0 || state.Lockouts != 0 {
+		t.Errorf("UnlockUser() error:\n   expected: no lockout and no failed logins\n   got:      %+v", state)
+	}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/token"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// LogoutRequest is the optional body of a logout request
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	All          bool   `json:"all"`
+}
+
+// Logout revokes the caller's access token, the refresh token passed with it, and with all every other session
+func (s *LoginService) Logout(w http.ResponseWriter, r *http.Request) {
+	log.Infof("Logout invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request LogoutRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil && err != io.EOF {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	err = s.Tokens.Revocations.RevokeToken(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	if request.RefreshToken != "" {
+		stored, err := s.Database.GetRefreshToken(token.HashOpaqueToken(request.RefreshToken))
+		if err == nil && ownsRefreshToken(claims, stored) {
+			err = s.Database.RevokeRefreshTokenFamily(stored.Family)
+			if err != nil {
+				api.RespondWithError(w, api.CheckError(err), err.Error())
+				return
+			}
+		}
+	}
+
+	if request.All {
+		user, err := s.currentUser(claims)
+		if err != nil {
+			api.RespondWithError(w, api.CheckError(err), err.Error())
+			return
+		}
+
+		err = s.revokeSessions(user)
+		if err != nil {
+			api.RespondWithError(w, api.CheckError(err), err.Error())
+			return
+		}
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, "Logged Out")
+}
+
+// RevokeUserSessions is the admin operation revoking every access and refresh token of a user
+func (s *LoginService) RevokeUserSessions(w http.ResponseWriter, r *http.Request) {
+	log.Infof("RevokeUserSessions invoked with URL: %v", r.URL)
+
+	user, err := s.Database.GetUser(mux.Vars(r)["username"])
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	err = s.revokeSessions(user)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, "Sessions Revoked")
+}
+
+// revokeSessions revokes every access token by the user's ID and every refresh token of the user
+func (s *LoginService) revokeSessions(user *models.User) error {
+	err := s.Tokens.Revocations.RevokeSessions(user.ID.Hex())
+	if err != nil {
+		return err
+	}
+
+	return s.Database.RevokeRefreshTokens(user)
+}
+
+// ownsRefreshToken is whether the refresh token was issued to the user of the access token, refresh tokens from before
+// they carried the user's ID are matched by username
+func ownsRefreshToken(claims *token.Claims, stored *models.RefreshToken) bool {
+	if stored.UserID.IsZero() {
+		return stored.Username == claims.Username
+	}
+
+	return stored.UserID.Hex() == claims.Subject
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/mail"
+	"github.com/geeksheik9/login-service/pkg/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// magicLinkResponse is sent whether or not the account exists, so the endpoint can not be used to find accounts
+const magicLinkResponse = "If the account exists a sign in email has been sent"
+
+// magicCodeDigits is the length of the code in a sign in email
+const magicCodeDigits = 6
+
+// magicCodeAttempts is how many codes can be tried for one sign in email before a new one has to be requested
+const magicCodeAttempts = 5
+
+// MagicLinkRequest is the body of POST /login/magic
+type MagicLinkRequest struct {
+	Email string `json:"email"`
+}
+
+// VerifyMagicLinkRequest is the body of POST /login/magic/verify, either the token from the link or the address and
+// the code from the email
+type VerifyMagicLinkRequest struct {
+	Token string `json:"token"`
+	Email string `json:"email"`
+	Code  string `json:"code"`
+}
+
+// SendMagicLink emails a single use sign in link and code to the user
+func (s *LoginService) SendMagicLink(w http.ResponseWriter, r *http.Request) {
+	log.Infof("SendMagicLink invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	var request MagicLinkRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+	address, err := mail.NormalizeAddress(request.Email)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	// Like /password/forgot the lookup and the mail are done after responding
+	go s.sendMagicLink(address)
+
+	api.RespondWithJSON(w, http.StatusAccepted, magicLinkResponse)
+}
+
+// sendMagicLink creates a sign in link and code for the user with the address and mails them, failures can only be
+// logged
+func (s *LoginService) sendMagicLink(address string) {
+	user, err := s.Database.GetUserByEmail(address)
+	if err != nil {
+		log.Debugf("No sign in email sent for %s: %v", address, err)
+		return
+	}
+
+	if s.Mailer == nil {
+		log.Warnf("No sign in email sent for %s: no mailer", address)
+		return
+	}
+
+	value, hash, err := token.NewOpaqueToken()
+	if err != nil {
+		log.Errorf("Error creating sign in email for %s: %v", address, err)
+		return
+	}
+	code, err := token.NewNumericCode(magicCodeDigits)
+	if err != nil {
+		log.Errorf("Error creating sign in email for %s: %v", address, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	err = s.Database.CreateMagicLink(&models.MagicLink{
+		Hash:      hash,
+		CodeHash:  token.HashOpaqueToken(code),
+		Username:  user.Username,
+		Email:     user.Email,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.MagicLinkLifetime),
+	})
+	if err != nil {
+		log.Errorf("Error creating sign in email for %s: %v", address, err)
+		return
+	}
+
+	body := "Use this code to sign in to your account " + user.Username + ", it works once and expires in " +
+		s.MagicLinkLifetime.String() + ":\n\n" + code + "\n"
+	if s.MagicLinkURL != "" {
+		body += "\nOr open " + s.MagicLinkURL + "?" + url.Values{"token": {value}}.Encode() + "\n"
+	}
+	body += "\nIf you did not ask to sign in you can ignore this email.\n"
+
+	err = s.Mailer.Send(&mail.Message{To: user.Email, Subject: "Your sign in code " + code, Body: body})
+	if err != nil {
+		log.Errorf("Error sending sign in email for %s: %v", address, err)
+	}
+}
+
+// VerifyMagicLink exchanges the token from a sign in link or the code from a sign in email for the tokens /login
+// issues, users with a second factor get the interim token of /login instead
+func (s *LoginService) VerifyMagicLink(w http.ResponseWriter, r *http.Request) {
+	log.Infof("VerifyMagicLink invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	var request VerifyMagicLinkRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || (request.Token == "" && (request.Email == "" || request.Code == "")) {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	hash := token.HashOpaqueToken(request.Token)
+	if request.Token == "" {
+		hash, err = s.checkMagicCode(request.Email, strings.TrimSpace(request.Code))
+		if err != nil {
+			api.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired sign in code")
+			return
+		}
+	}
+
+	link, err := s.Database.ConsumeMagicLink(hash)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired sign in code")
+		return
+	}
+
+	user, err := s.Database.GetUser(link.Username)
+	if err != nil || user.Email != link.Email {
+		api.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired sign in code")
+		return
+	}
+
+	// Getting the email proves the address is the user's, the same as the verification link would
+	if !user.EmailVerified {
+		err = s.Database.VerifyEmail(user.ID.Hex(), user.Email)
+		if err != nil {
+			api.RespondWithError(w, api.CheckError(err), err.Error())
+			return
+		}
+		user.EmailVerified = true
+	}
+
+	// The email stands in for the password only, a second factor is still asked for
+	if user.MFAEnabled() {
+		s.respondWithMFARequired(w, user)
+		return
+	}
+
+	accessToken, _, err := s.Tokens.Issue(user, "")
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	response, err := s.newTokenResponse(user, "", accessToken)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	respondWithTokens(w, response)
+}
+
+// checkMagicCode compares a code against the latest sign in email sent to the address and returns the hash of its link
+// token, every comparison uses up an attempt
+func (s *LoginService) checkMagicCode(address string, code string) (string, error) {
+	address, err := mail.NormalizeAddress(address)
+	if err != nil {
+		return "", err
+	}
+	user, err := s.Database.GetUserByEmail(address)
+	if err != nil {
+		return "", err
+	}
+
+	link, err := s.Database.AttemptMagicCode(user.Username, magicCodeAttempts)
+	if err != nil {
+		return "", err
+	}
+
+	if subtle.ConstantTimeCompare([]byte(token.HashOpaqueToken(code)), []byte(link.CodeHash)) != 1 {
+		return "", ErrInvalidMFACode
+	}
+
+	return link.Hash, nil
+}
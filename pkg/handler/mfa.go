@@ -0,0 +1,369 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/mfa"
+	"github.com/geeksheik9/login-service/pkg/token"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// mfaChallengeAttempts is how many codes can be tried with one interim token before the user has to log in again
+const mfaChallengeAttempts = 5
+
+// ErrInvalidMFACode is returned when a code is neither a valid authenticator code nor an unused recovery code
+var ErrInvalidMFACode = errors.New("invalid two-factor code")
+
+// LoginMFARequest is the body of POST /login/mfa, the code is from the authenticator app or a recovery code
+type LoginMFARequest struct {
+	MFAToken string `json:"mfaToken"`
+	Code     string `json:"code"`
+}
+
+// MFACodeRequest is the body of the endpoints that confirm an action with a second factor
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+// DisableTOTPRequest is the body of DELETE /mfa/totp
+type DisableTOTPRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// respondWithMFARequired stores an interim token for a user who has passed the password check and has to give a
+// second factor before tokens are issued
+func (s *LoginService) respondWithMFARequired(w http.ResponseWriter, user *models.User) {
+	mfaToken, hash, err := token.NewOpaqueToken()
+	if err != nil {
+		api.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	now := time.Now().UTC()
+	err = s.Database.CreateMFAChallenge(&models.MFAChallenge{
+		Hash:      hash,
+		Username:  user.Username,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.MFAChallengeLifetime),
+	})
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	api.RespondWithJSON(w, http.StatusOK, models.MFARequiredResponse{
+		Status:    "mfa_required",
+		MFAToken:  mfaToken,
+		ExpiresIn: int64(s.MFAChallengeLifetime.Seconds()),
+	})
+}
+
+// checkFormSecondFactor checks the second factor on the /authorize and /device pages, they can not run a passkey
+// ceremony so users whose only second factor is a passkey are sent to /login instead of being let through. It
+// returns the message to show, or an empty string when the user may continue
+func (s *LoginService) checkFormSecondFactor(user *models.User, code string) string {
+	switch {
+	case user.TOTPEnabled() && s.verifySecondFactor(user, code) != nil:
+		return "Enter a valid code from your authenticator app or a recovery code"
+	case !user.TOTPEnabled() && user.MFAEnabled():
+		return "Sign in with your passkey through /login, this page only takes authenticator app codes"
+	}
+
+	return ""
+}
+
+// verifySecondFactor checks a code from the user's authenticator app or one of their recovery codes, either can only
+// be used once
+func (s *LoginService) verifySecondFactor(user *models.User, code string) error {
+	if !user.TOTPEnabled() || code == "" {
+		return ErrInvalidMFACode
+	}
+
+	if !mfa.IsTOTPCode(code) {
+		err := s.Database.UseRecoveryCode(user.Username, mfa.HashRecoveryCode(code))
+		if err != nil {
+			return ErrInvalidMFACode
+		}
+		log.Infof("Recovery code used by %s", user.Username)
+		return nil
+	}
+
+	counter, ok := mfa.Validate(user.TOTP.Secret, code, time.Now())
+	if !ok {
+		return ErrInvalidMFACode
+	}
+
+	err := s.Database.UseTOTPCode(user.Username, counter)
+	if err != nil {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// LoginMFA finishes a login that needed a second factor and issues the tokens /login would have
+func (s *LoginService) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	log.Infof("LoginMFA invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	var request LoginMFARequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.MFAToken == "" || request.Code == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	hash := token.HashOpaqueToken(request.MFAToken)
+	challenge, err := s.Database.AttemptMFAChallenge(hash, mfaChallengeAttempts)
+	if err != nil {
+		if api.CheckError(err) == http.StatusNotFound {
+			api.RespondWithError(w, http.StatusUnauthorized, "MFA token is invalid or expired, log in again")
+			return
+		}
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	user, err := s.Database.GetUser(challenge.Username)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, "MFA token is invalid or expired, log in again")
+		return
+	}
+
+	err = s.verifySecondFactor(user, request.Code)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	err = s.Database.ConsumeMFAChallenge(hash)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, "MFA token is invalid or expired, log in again")
+		return
+	}
+
+	accessToken, _, err := s.Tokens.Issue(user, "")
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	response, err := s.newTokenResponse(user, "", accessToken)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	respondWithTokens(w, response)
+}
+
+// EnrollTOTP starts an authenticator app enrollment for the user of the bearer token, it is not used for logins until
+// it is confirmed
+func (s *LoginService) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	log.Infof("EnrollTOTP invoked with URL: %v", r.URL)
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	user, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+	if user.TOTPEnabled() {
+		api.RespondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+
+	secret, err := mfa.NewSecret()
+	if err != nil {
+		api.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	err = s.Database.StartTOTPEnrollment(user.Username, secret)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	api.RespondWithJSON(w, http.StatusOK, models.TOTPEnrollmentResponse{
+		Secret: secret,
+		URI:    mfa.URI(s.TOTPIssuer, user.Username, secret),
+	})
+}
+
+// ConfirmTOTP enables a pending enrollment with a first code from the app and returns the user's recovery codes
+func (s *LoginService) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	log.Infof("ConfirmTOTP invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request MFACodeRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Code == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	user, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+	if user.TOTP == nil || user.TOTP.Confirmed {
+		api.RespondWithError(w, http.StatusConflict, "There is no pending enrollment to confirm")
+		return
+	}
+
+	counter, ok := mfa.Validate(user.TOTP.Secret, request.Code, time.Now())
+	if !ok {
+		api.RespondWithError(w, http.StatusBadRequest, ErrInvalidMFACode.Error())
+		return
+	}
+
+	codes, hashes, err := mfa.NewRecoveryCodes(s.RecoveryCodeCount)
+	if err != nil {
+		api.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	err = s.Database.ConfirmTOTP(user.Username, user.TOTP.Secret, counter, hashes)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	api.RespondWithJSON(w, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes of the user of the bearer token, the old ones stop working
+func (s *LoginService) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	log.Infof("RegenerateRecoveryCodes invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request MFACodeRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Code == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	user, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+	if !user.TOTPEnabled() {
+		api.RespondWithError(w, http.StatusConflict, "Two-factor authentication is not enabled")
+		return
+	}
+
+	err = s.verifySecondFactor(user, request.Code)
+	if err != nil {
+		api.RespondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	codes, hashes, err := mfa.NewRecoveryCodes(s.RecoveryCodeCount)
+	if err != nil {
+		api.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	err = s.Database.ReplaceRecoveryCodes(user.Username, hashes)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	api.RespondWithJSON(w, http.StatusOK, models.RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// DisableTOTP removes the enrollment of the user of the bearer token, it takes the password and, once confirmed, a
+// second factor so a stolen access token is not enough
+func (s *LoginService) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	log.Infof("DisableTOTP invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request DisableTOTPRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Password == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	current, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	user, err := s.Database.AuthenticateUser(&models.User{Username: current.Username, Password: request.Password})
+	if err != nil {
+		api.RespondWithError(w, http.StatusForbidden, "Password is incorrect")
+		return
+	}
+
+	if user.TOTPEnabled() {
+		err = s.verifySecondFactor(user, request.Code)
+		if err != nil {
+			api.RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+	}
+
+	err = s.Database.DisableTOTP(user.Username)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, "Two-Factor Authentication Disabled")
+}
+
+// ResetUserMFA removes the enrollment of any user, for admins helping a user who lost their device and recovery codes
+func (s *LoginService) ResetUserMFA(w http.ResponseWriter, r *http.Request) {
+	log.Infof("ResetUserMFA invoked with URL: %v", r.URL)
+
+	username := mux.Vars(r)["username"]
+	err := s.Database.DisableTOTP(username)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, "Two-Factor Authentication Disabled")
+}
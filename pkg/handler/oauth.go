@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// OAuthError is the error body defined by RFC 6749 section 5.2
+type OAuthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// respondWithOAuthError writes an RFC 6749 error, the token endpoint uses these instead of api.RespondWithError
+func respondWithOAuthError(w http.ResponseWriter, code int, oauthError string, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	api.RespondWithJSON(w, code, OAuthError{Error: oauthError, Description: description})
+}
+
+// respondWithTokens writes a token response that caches must not keep
+func respondWithTokens(w http.ResponseWriter, response *models.TokenResponse) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	api.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// hasScope checks a space separated scope string for a scope
+func hasScope(scope string, name string) bool {
+	for _, value := range strings.Fields(scope) {
+		if value == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Token is the OAuth 2.0 token endpoint, it dispatches on grant_type
+func (s *LoginService) Token(w http.ResponseWriter, r *http.Request) {
+	log.Infof("Token invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "unable to parse form")
+		return
+	}
+
+	switch grantType := r.PostFormValue("grant_type"); grantType {
+	case "authorization_code":
+		s.authorizationCodeGrant(w, r)
+	case "refresh_token":
+		s.refreshTokenGrant(w, r)
+	case "client_credentials":
+		s.clientCredentialsGrant(w, r)
+	case deviceCodeGrantType:
+		s.deviceCodeGrant(w, r)
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "unsupported grant_type "+grantType)
+	}
+}
+
+// authorizationCodeGrant redeems an authorization code, the code verifier must match the PKCE challenge
+func (s *LoginService) authorizationCodeGrant(w http.ResponseWriter, r *http.Request) {
+	code := r.PostFormValue("code")
+	clientID, _ := clientCredentials(r)
+	if code == "" || clientID == "" {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "code and client_id are required")
+		return
+	}
+
+	// Public clients rely on PKCE alone
+	err := s.authenticateConfidentialClient(r, clientID)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	stored, err := s.Database.ConsumeAuthorizationCode(token.HashOpaqueToken(code))
+	if err != nil {
+		if api.CheckError(err) == http.StatusNotFound {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "authorization code is invalid")
+			return
+		}
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	switch {
+	case time.Now().After(stored.ExpiresAt):
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "authorization code is expired")
+		return
+	case stored.ClientID != clientID || stored.RedirectURI != r.PostFormValue("redirect_uri"):
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "authorization code was issued to another client")
+		return
+	case !token.VerifyPKCE(stored.CodeChallenge, r.PostFormValue("code_verifier")):
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "code_verifier does not match the code_challenge")
+		return
+	}
+
+	user, err := s.Database.GetUser(stored.Username)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "user no longer exists")
+		return
+	}
+
+	accessToken, _, err := s.Tokens.IssueForClient(user, clientID, stored.Scope)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	response, err := s.newClientTokenResponse(user, clientID, stored.Scope, accessToken)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	if hasScope(stored.Scope, "openid") {
+		response.IDToken, err = s.Tokens.IssueIDToken(user, clientID, stored.Nonce, stored.AuthTime)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+			return
+		}
+	}
+
+	respondWithTokens(w, response)
+}
+
+// authenticateConfidentialClient makes registered clients that hold a secret prove it, configured OpenID Connect
+// clients and public clients are only identified by their client_id
+func (s *LoginService) authenticateConfidentialClient(r *http.Request, clientID string) error {
+	if _, ok := s.OIDCClients[clientID]; ok {
+		return nil
+	}
+
+	registered, err := s.Database.GetClient(clientID)
+	if err != nil || registered.Public {
+		return nil
+	}
+
+	_, err = s.authenticateClient(r)
+	return err
+}
+
+// refreshTokenGrant is the token endpoint form of POST /token/refresh, refresh tokens issued to a client can only be
+// redeemed by that client, first party refresh tokens are redeemed without a client_id
+func (s *LoginService) refreshTokenGrant(w http.ResponseWriter, r *http.Request) {
+	refreshToken := r.PostFormValue("refresh_token")
+	if refreshToken == "" {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "refresh_token is required")
+		return
+	}
+
+	clientID, _ := clientCredentials(r)
+	if clientID != "" {
+		err := s.authenticateConfidentialClient(r, clientID)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+			respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+			return
+		}
+	}
+
+	response, err := s.exchangeRefreshToken(refreshToken, clientID)
+	if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, token.ErrRefreshTokenReused) {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", err.Error())
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	respondWithTokens(w, response)
+}
+
+// clientCredentialsGrant issues a service token to a registered client, limited to the scopes assigned to it
+func (s *LoginService) clientCredentialsGrant(w http.ResponseWriter, r *http.Request) {
+	client, err := s.authenticateClient(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	if !client.HasGrantType("client_credentials") {
+		respondWithOAuthError(w, http.StatusBadRequest, "unauthorized_client", "client may not use the client_credentials grant")
+		return
+	}
+
+	scope := strings.Join(client.Scopes, " ")
+	if requested := r.PostFormValue("scope"); requested != "" {
+		for _, value := range strings.Fields(requested) {
+			if !client.HasScope(value) {
+				respondWithOAuthError(w, http.StatusBadRequest, "invalid_scope", "scope "+value+" is not assigned to the client")
+				return
+			}
+		}
+		scope = strings.Join(strings.Fields(requested), " ")
+	}
+
+	accessToken, _, err := s.Tokens.IssueClientToken(client.ClientID, scope)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+
+	respondWithTokens(w, &models.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(s.Tokens.Lifetime.Seconds()),
+		Scope:       scope,
+	})
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/geeksheik9/login-service/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestRefreshTokenGrant_Client(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Username: "alice"}
+	database := newFakeDatabase(user)
+	database.clients["cli"] = &models.Client{ClientID: "cli", Secret: "cli secret"}
+	database.clients["other"] = &models.Client{ClientID: "other", Public: true}
+	s := newTestService(t, database)
+
+	token := func(form url.Values) int {
+		form.Set("grant_type", "refresh_token")
+		r := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		s.Token(w, r)
+		return w.Code
+	}
+
+	session, err := s.newClientTokenResponse(user, "cli", "", "access")
+	if err != nil {
+		t.Fatalf("newClientTokenResponse() returned error: %v", err)
+	}
+	firstParty, err := s.newTokenResponse(user, "", "access")
+	if err != nil {
+		t.Fatalf("newTokenResponse() returned error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		form     url.Values
+		expected int
+	}{
+		{"no client", url.Values{"refresh_token": {session.RefreshToken}}, http.StatusBadRequest},
+		{"wrong secret", url.Values{"refresh_token": {session.RefreshToken}, "client_id": {"cli"}, "client_secret": {"guess"}}, http.StatusUnauthorized},
+		{"another client", url.Values{"refresh_token": {session.RefreshToken}, "client_id": {"other"}}, http.StatusBadRequest},
+		{"first party token for a client", url.Values{"refresh_token": {firstParty.RefreshToken}, "client_id": {"other"}}, http.StatusBadRequest},
+		{"the client", url.Values{"refresh_token": {session.RefreshToken}, "client_id": {"cli"}, "client_secret": {"cli secret"}}, http.StatusOK},
+		{"first party token", url.Values{"refresh_token": {firstParty.RefreshToken}}, http.StatusOK},
+	}
+	for _, test := range tests {
+		if code := token(test.form); code != test.expected {
+			t.Errorf("Token() %s error:\n   expected: %v\n   got:      %v", test.name, test.expected, code)
+		}
+	}
+}
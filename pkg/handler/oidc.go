@@ -0,0 +1,299 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// authorizationCodeLifetime is how long a client has to redeem an authorization code
+const authorizationCodeLifetime = time.Minute
+
+var loginPage = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Sign in</title></head>
+<body>
+<h1>Sign in to {{.ClientID}}</h1>
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+<form method="post" action="authorize">
+<input type="hidden" name="response_type" value="{{.ResponseType}}">
+<input type="hidden" name="client_id" value="{{.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+<input type="hidden" name="scope" value="{{.Scope}}">
+<input type="hidden" name="state" value="{{.State}}">
+<input type="hidden" name="nonce" value="{{.Nonce}}">
+<input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.CodeChallengeMethod}}">
+<label>Username <input name="username" autocomplete="username"></label>
+<label>Password <input name="password" type="password" autocomplete="current-password"></label>
+<label>Two-factor code <input name="code" autocomplete="one-time-code"></label>
+<button type="submit">Sign in</button>
+</form>
+</body>
+</html>
+`))
+
+// authorizationRequest holds the parameters of an authorization request, they are carried through the login form
+type authorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Error               string
+}
+
+func newAuthorizationRequest(values url.Values) *authorizationRequest {
+	return &authorizationRequest{
+		ResponseType:        values.Get("response_type"),
+		ClientID:            values.Get("client_id"),
+		RedirectURI:         values.Get("redirect_uri"),
+		Scope:               values.Get("scope"),
+		State:               values.Get("state"),
+		Nonce:               values.Get("nonce"),
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+	}
+}
+
+// redirectURIs returns the redirect URIs of a configured client or a registered client allowed the code flow
+func (s *LoginService) redirectURIs(clientID string) []string {
+	if redirectURIs, ok := s.OIDCClients[clientID]; ok {
+		return redirectURIs
+	}
+
+	client, err := s.Database.GetClient(clientID)
+	if err != nil || !client.HasGrantType("authorization_code") {
+		return nil
+	}
+
+	return client.RedirectURIs
+}
+
+// validRedirect checks the client and redirect URI, nothing may be redirected to until both are known to be good
+func (s *LoginService) validRedirect(request *authorizationRequest) bool {
+	if request.ClientID == "" {
+		return false
+	}
+
+	for _, redirectURI := range s.redirectURIs(request.ClientID) {
+		if redirectURI == request.RedirectURI {
+			return true
+		}
+	}
+
+	return false
+}
+
+// validate returns the RFC 6749 error code for a bad request after the redirect has been checked
+func (request *authorizationRequest) validate() (string, string) {
+	switch {
+	case request.ResponseType != "code":
+		return "unsupported_response_type", "only the code response type is supported"
+	case !hasScope(request.Scope, "openid"):
+		return "invalid_scope", "scope must include openid"
+	case request.CodeChallenge == "" || request.CodeChallengeMethod != token.PKCEMethodS256:
+		return "invalid_request", "a PKCE code_challenge with the S256 method is required"
+	}
+
+	return "", ""
+}
+
+// redirect sends the user agent back to the client with the parameters and the state
+func (request *authorizationRequest) redirect(w http.ResponseWriter, r *http.Request, params url.Values) {
+	if request.State != "" {
+		params.Set("state", request.State)
+	}
+
+	target, _ := url.Parse(request.RedirectURI)
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	target.RawQuery = query.Encode()
+
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// OpenIDConfiguration serves the OpenID Connect discovery document
+func (s *LoginService) OpenIDConfiguration(w http.ResponseWriter, r *http.Request) {
+	log.Infof("OpenIDConfiguration invoked with URL: %v", r.URL)
+
+	issuer := strings.TrimSuffix(s.Tokens.Issuer, "/")
+	algs := []string{}
+	if key, err := s.Keys.SigningKey(); err == nil {
+		algs = append(algs, key.Method.Alg())
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, models.OpenIDConfiguration{
+		Issuer:                            s.Tokens.Issuer,
+		AuthorizationEndpoint:             issuer + "/authorize",
+		TokenEndpoint:                     issuer + "/token",
+		UserInfoEndpoint:                  issuer + "/userinfo",
+		JWKSURI:                           issuer + "/.well-known/jwks.json",
+		IntrospectionEndpoint:             issuer + "/introspect",
+		DeviceAuthorizationEndpoint:       issuer + "/device/code",
+		ScopesSupported:                   []string{"openid", "profile", "email"},
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token", "client_credentials", deviceCodeGrantType},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algs,
+		TokenEndpointAuthMethodsSupported: []string{"none", "client_secret_basic", "client_secret_post"},
+		CodeChallengeMethodsSupported:     []string{token.PKCEMethodS256},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "name", "given_name", "family_name", "preferred_username", "email", "email_verified", "roles"},
+	})
+}
+
+// Authorize starts the authorization code flow by showing the login form
+func (s *LoginService) Authorize(w http.ResponseWriter, r *http.Request) {
+	log.Infof("Authorize invoked with URL: %v", r.URL)
+
+	request := newAuthorizationRequest(r.URL.Query())
+	if !s.validRedirect(request) {
+		api.RespondWithError(w, http.StatusBadRequest, "Unknown client_id or redirect_uri")
+		return
+	}
+
+	if oauthError, description := request.validate(); oauthError != "" {
+		request.redirect(w, r, url.Values{"error": {oauthError}, "error_description": {description}})
+		return
+	}
+
+	s.renderLogin(w, http.StatusOK, request)
+}
+
+// AuthorizeLogin checks the credentials posted from the login form and redirects back to the client with a code
+func (s *LoginService) AuthorizeLogin(w http.ResponseWriter, r *http.Request) {
+	log.Infof("AuthorizeLogin invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	err := r.ParseForm()
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	request := newAuthorizationRequest(r.PostForm)
+	if !s.validRedirect(request) {
+		api.RespondWithError(w, http.StatusBadRequest, "Unknown client_id or redirect_uri")
+		return
+	}
+
+	if oauthError, description := request.validate(); oauthError != "" {
+		request.redirect(w, r, url.Values{"error": {oauthError}, "error_description": {description}})
+		return
+	}
+
+	user, err := s.Database.AuthenticateUser(&models.User{
+		Username: r.PostFormValue("username"),
+		Password: r.PostFormValue("password"),
+	})
+	if err != nil {
+		request.Error = "Incorrect username or password"
+		s.renderLogin(w, http.StatusUnauthorized, request)
+		return
+	}
+	if s.checkEmailVerified(user) != nil {
+		request.Error = "Verify your email address before logging in"
+		s.renderLogin(w, http.StatusForbidden, request)
+		return
+	}
+	if message := s.checkFormSecondFactor(user, r.PostFormValue("code")); message != "" {
+		request.Error = message
+		s.renderLogin(w, http.StatusUnauthorized, request)
+		return
+	}
+
+	code, hash, err := token.NewOpaqueToken()
+	if err != nil {
+		request.redirect(w, r, url.Values{"error": {"server_error"}})
+		return
+	}
+
+	now := time.Now().UTC()
+	err = s.Database.CreateAuthorizationCode(&models.AuthorizationCode{
+		Hash:          hash,
+		ClientID:      request.ClientID,
+		RedirectURI:   request.RedirectURI,
+		Username:      user.Username,
+		Scope:         request.Scope,
+		Nonce:         request.Nonce,
+		CodeChallenge: request.CodeChallenge,
+		AuthTime:      now,
+		ExpiresAt:     now.Add(authorizationCodeLifetime),
+	})
+	if err != nil {
+		request.redirect(w, r, url.Values{"error": {"server_error"}})
+		return
+	}
+
+	request.redirect(w, r, url.Values{"code": {code}})
+}
+
+func (s *LoginService) renderLogin(w http.ResponseWriter, code int, request *authorizationRequest) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(code)
+
+	err := loginPage.Execute(w, request)
+	if err != nil {
+		log.Errorf("Error rendering login page: %v", err)
+	}
+}
+
+// UserInfo returns the claims about the user an openid scoped access token was issued for
+func (s *LoginService) UserInfo(w http.ResponseWriter, r *http.Request) {
+	log.Infof("UserInfo invoked with URL: %v", r.URL)
+
+	// The one route taking tokens a client got for a user, they are the ones with the openid scope
+	claims, err := s.authenticate(r)
+	if err == nil && claims.Username == "" {
+		err = ErrNotUserToken
+	}
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	if !hasScope(claims.Scope, "openid") {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		api.RespondWithError(w, http.StatusForbidden, "Token was not issued with the openid scope")
+		return
+	}
+
+	user, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	response := models.UserInfoResponse{
+		Subject: claims.Subject,
+		Roles:   user.Roles,
+	}
+	if hasScope(claims.Scope, "profile") {
+		response.Name = token.FullName(user)
+		response.GivenName = user.FirstName
+		response.FamilyName = user.LastName
+		response.PreferredUsername = user.Username
+	}
+	if hasScope(claims.Scope, "email") && user.Email != "" {
+		response.Email = user.Email
+		response.EmailVerified = &user.EmailVerified
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, response)
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/password"
+	"github.com/geeksheik9/login-service/pkg/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// PasswordPolicyError lists every password rule a request failed, not only the first
+type PasswordPolicyError struct {
+	Error      string               `json:"error"`
+	Violations []password.Violation `json:"violations"`
+}
+
+// checkPasswordPolicy responds with a 400 listing the failed rules when the new password does not pass the policy
+func (s *LoginService) checkPasswordPolicy(w http.ResponseWriter, value string, user *models.User) bool {
+	if s.PasswordPolicy == nil {
+		return true
+	}
+
+	violations := s.PasswordPolicy.Check(value, user.Username, user.FirstName, user.LastName, token.FullName(user))
+	if len(violations) > 0 {
+		api.RespondWithJSON(w, http.StatusBadRequest, PasswordPolicyError{
+			Error:      "Password does not meet the password policy",
+			Violations: violations,
+		})
+		return false
+	}
+
+	return true
+}
+
+// checkPasswordHistory responds like a failed policy rule when the new password is one the user had recently
+func (s *LoginService) checkPasswordHistory(w http.ResponseWriter, username string, value string) bool {
+	err := s.Database.CheckPasswordHistory(username, value)
+	if errors.Is(err, password.ErrPasswordReused) {
+		respondWithPasswordReused(w)
+		return false
+	}
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return false
+	}
+
+	return true
+}
+
+func respondWithPasswordReused(w http.ResponseWriter) {
+	api.RespondWithJSON(w, http.StatusBadRequest, PasswordPolicyError{
+		Error:      "Password does not meet the password policy",
+		Violations: []password.Violation{{Rule: password.RuleHistory, Message: "must not be one of your recent passwords"}},
+	})
+}
+
+// ChangePasswordRequest is the body of POST /password
+type ChangePasswordRequest struct {
+	CurrentPassword     string `json:"currentPassword"`
+	NewPassword         string `json:"newPassword"`
+	RevokeOtherSessions bool   `json:"revokeOtherSessions"`
+}
+
+// ChangePassword changes the password of the user of the bearer token after checking their current password
+func (s *LoginService) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	log.Infof("ChangePassword invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request ChangePasswordRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.CurrentPassword == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	current, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	user, err := s.Database.AuthenticateUser(&models.User{Username: current.Username, Password: request.CurrentPassword})
+	if err != nil {
+		api.RespondWithError(w, http.StatusForbidden, "Current password is incorrect")
+		return
+	}
+
+	if !s.checkPasswordPolicy(w, request.NewPassword, user) || !s.checkPasswordHistory(w, user.Username, request.NewPassword) {
+		return
+	}
+
+	err = s.Database.ChangePassword(user.Username, request.NewPassword)
+	if errors.Is(err, password.ErrPasswordReused) {
+		respondWithPasswordReused(w)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	if !request.RevokeOtherSessions {
+		api.RespondWithJSON(w, http.StatusOK, "Password Changed")
+		return
+	}
+
+	// Every token issued before now is revoked, the caller carries on with the new tokens in the response
+	err = s.revokeSessions(user)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	accessToken, _, err := s.Tokens.Issue(user, "")
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	response, err := s.newTokenResponse(user, "", accessToken)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	respondWithTokens(w, response)
+}
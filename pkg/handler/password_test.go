@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/token"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestChangePassword_WrongCurrentPassword(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Username: "alice", Password: "old password"}
+	database := newFakeDatabase(user)
+	s := newTestService(t, database)
+	bearer, _, _ := s.Tokens.Issue(user, "")
+
+	body := ChangePasswordRequest{CurrentPassword: "wrong password", NewPassword: "new password"}
+	w := serve(s.ChangePassword, http.MethodPost, body, bearer)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("ChangePassword() error:\n   expected: %v\n   got:      %v %s", http.StatusForbidden, w.Code, w.Body)
+	}
+	if database.users["alice"].Password != "old password" {
+		t.Errorf("ChangePassword() error:\n   expected: the password unchanged\n   got:      %v", database.users["alice"].Password)
+	}
+}
+
+func TestChangePassword_RevokeOtherSessions(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Username: "alice", Password: "old password"}
+	database := newFakeDatabase(user)
+	s := newTestService(t, database)
+	bearer, _, _ := s.Tokens.Issue(user, "")
+
+	body := ChangePasswordRequest{CurrentPassword: "old password", NewPassword: "new password", RevokeOtherSessions: true}
+	w := serve(s.ChangePassword, http.MethodPost, body, bearer)
+	if w.Code != http.StatusOK {
+		t.Fatalf("ChangePassword() error:\n   expected: %v\n   got:      %v %s", http.StatusOK, w.Code, w.Body)
+	}
+	if database.users["alice"].Password != "new password" {
+		t.Errorf("ChangePassword() error:\n   expected: new password\n   got:      %v", database.users["alice"].Password)
+	}
+
+	_, err := s.Tokens.Validate(bearer)
+	if !errors.Is(err, token.ErrTokenRevoked) {
+		t.Errorf("Validate() old token error:\n   expected: %v\n   got:      %v", token.ErrTokenRevoked, err)
+	}
+	var response models.TokenResponse
+	_ = json.NewDecoder(w.Body).Decode(&response)
+	_, err = s.Tokens.Validate(response.AccessToken)
+	if err != nil {
+		t.Errorf("Validate() new token error:\n   expected: <nil>\n   got:      %v", err)
+	}
+}
+
+func TestChangePassword_Reused(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Username: "alice", Password: "old password"}
+	s := newTestService(t, newFakeDatabase(user))
+	bearer, _, _ := s.Tokens.Issue(user, "")
+
+	body := ChangePasswordRequest{CurrentPassword: "old password", NewPassword: "old password"}
+	w := serve(s.ChangePassword, http.MethodPost, body, bearer)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("ChangePassword() error:\n   expected: %v\n   got:      %v %s", http.StatusBadRequest, w.Code, w.Body)
+	}
+}
@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// profileFields maps the JSON names of the profile attributes users may edit to their stored field names, a new
+// attribute only has to be added here and to models.User and models.Profile
+var profileFields = map[string]string{
+	"firstName": "firstName",
+	"lastName":  "lastName",
+}
+
+// maxProfileValue is the longest value a profile attribute can be set to
+const maxProfileValue = 256
+
+// GetMyProfile returns the stored profile of the user of the bearer token, its version is also sent as the ETag for
+// PATCH /users/me
+func (s *LoginService) GetMyProfile(w http.ResponseWriter, r *http.Request) {
+	log.Infof("GetMyProfile invoked with URL: %v", r.URL)
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	user, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	respondWithProfile(w, user)
+}
+
+// UpdateMyProfile applies a JSON Merge Patch (RFC 7396) to the profile of the user of the bearer token. The If-Match
+// header has to hold the ETag of the profile the patch is based on, so an edit made in the meantime is not overwritten
+func (s *LoginService) UpdateMyProfile(w http.ResponseWriter, r *http.Request) {
+	log.Infof("UpdateMyProfile invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+		api.RespondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/merge-patch+json")
+		return
+	}
+
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		api.RespondWithError(w, http.StatusPreconditionRequired, "If-Match header with the profile's ETag is required")
+		return
+	}
+
+	var patch map[string]json.RawMessage
+	err = json.NewDecoder(r.Body).Decode(&patch)
+	if err != nil || patch == nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	changes, err := profileChanges(patch)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	current, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	version := current.Version
+	if ifMatch != "*" {
+		var ok bool
+		version, ok = parseETag(ifMatch)
+		if !ok || version != current.Version {
+			respondWithVersionConflict(w)
+			return
+		}
+	}
+
+	if len(changes) == 0 {
+		respondWithProfile(w, current)
+		return
+	}
+
+	updated, err := s.Database.UpdateProfile(current.Username, version, changes)
+	if errors.Is(err, models.ErrVersionConflict) {
+		respondWithVersionConflict(w)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	respondWithProfile(w, updated)
+}
+
+// profileChanges turns a merge patch into the stored fields to change, null removes an attribute. Anything that is not
+// an editable profile attribute, such as the username, roles or password, is refused rather than ignored
+func profileChanges(patch map[string]json.RawMessage) (map[string]*string, error) {
+	changes := make(map[string]*string, len(patch))
+	for name, raw := range patch {
+		field, ok := profileFields[name]
+		if !ok {
+			return nil, errors.New(name + " can not be changed with PATCH /users/me")
+		}
+
+		if string(raw) == "null" {
+			changes[field] = nil
+			continue
+		}
+
+		var value string
+		err := json.Unmarshal(raw, &value)
+		if err != nil {
+			return nil, errors.New(name + " must be a string or null")
+		}
+		value = strings.TrimSpace(value)
+		if len(value) > maxProfileValue {
+			return nil, errors.New(name + " must be at most " + strconv.Itoa(maxProfileValue) + " bytes")
+		}
+		changes[field] = &value
+	}
+
+	return changes, nil
+}
+
+// parseETag reads the profile version from an If-Match value, an ETag from respondWithProfile. Weak ETags never match
+// for If-Match and are refused
+func parseETag(value string) (int64, bool) {
+	if strings.HasPrefix(value, "W/") {
+		return 0, false
+	}
+
+	version, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+	if err != nil || version < 0 {
+		return 0, false
+	}
+
+	return version, true
+}
+
+func respondWithProfile(w http.ResponseWriter, user *models.User) {
+	w.Header().Set("ETag", `"`+strconv.FormatInt(user.Version, 10)+`"`)
+	w.Header().Set("Cache-Control", "no-store")
+	api.RespondWithJSON(w, http.StatusOK, user.Profile())
+}
+
+func respondWithVersionConflict(w http.ResponseWriter) {
+	api.RespondWithError(w, http.StatusPreconditionFailed, "Profile was changed since it was fetched, fetch it again and retry")
+}
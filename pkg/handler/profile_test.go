@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/geeksheik9/login-service/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseETag(t *testing.T) {
+	tests := []struct {
+		value    string
+		version  int64
+		expected bool
+	}{
+		{`"3"`, 3, true},
+		{`"0"`, 0, true},
+		{`3`, 3, true},
+		{`W/"3"`, 0, false},
+		{`"abc"`, 0, false},
+		{`"-1"`, 0, false},
+		{``, 0, false},
+	}
+	for _, test := range tests {
+		version, ok := parseETag(test.value)
+		if version != test.version || ok != test.expected {
+			t.Errorf("parseETag(%s) error:\n   expected: %v %v\n   got:      %v %v", test.value, test.version, test.expected, version, ok)
+		}
+	}
+}
+
+func TestProfileChanges(t *testing.T) {
+	first := "Ada"
+	changes, err := profileChanges(map[string]json.RawMessage{"firstName": json.RawMessage(`" Ada "`), "lastName": json.RawMessage(`null`)})
+	if err != nil || len(changes) != 2 || *changes["firstName"] != first || changes["lastName"] != nil {
+		t.Errorf("profileChanges() error:\n   expected: firstName Ada and lastName removed\n   got:      %v %v", changes, err)
+	}
+
+	tests := []string{
+		`{"username":"admin"}`,
+		`{"roles":[{"name":"admin"}]}`,
+		`{"password":"secret"}`,
+		`{"firstName":3}`,
+		`{"firstName":"` + strings.Repeat("a", maxProfileValue+1) + `"}`,
+	}
+	for _, test := range tests {
+		var patch map[string]json.RawMessage
+		_ = json.Unmarshal([]byte(test), &patch)
+		_, err := profileChanges(patch)
+		if err == nil {
+			t.Errorf("profileChanges(%.40s) error:\n   expected: <error>\n   got:      %v", test, err)
+		}
+	}
+}
+
+func TestUpdateMyProfile(t *testing.T) {
+	user := &models.User{ID: primitive.NewObjectID(), Username: "alice", FirstName: "Alice", LastName: "Smith", Version: 2}
+	database := newFakeDatabase(user)
+	s := newTestService(t, database)
+	bearer, _, _ := s.Tokens.Issue(user, "")
+
+	patch := func(contentType string, ifMatch string, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPatch, "/users/me", strings.NewReader(body))
+		r.Header.Set("Authorization", "Bearer "+bearer)
+		r.Header.Set("Content-Type", contentType)
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		w := httptest.NewRecorder()
+		s.UpdateMyProfile(w, r)
+		return w
+	}
+
+	tests := []struct {
+		name        string
+		contentType string
+		ifMatch     string
+		body        string
+		expected    int
+	}{
+		{"wrong content type", "text/plain", `"2"`, `{"firstName":"Ada"}`, http.StatusUnsupportedMediaType},
+		{"no If-Match", "application/merge-patch+json", "", `{"firstName":"Ada"}`, http.StatusPreconditionRequired},
+		{"stale version", "application/merge-patch+json", `"1"`, `{"firstName":"Ada"}`, http.StatusPreconditionFailed},
+		{"forbidden field", "application/merge-patch+json", `"2"`, `{"roles":[{"name":"admin"}]}`, http.StatusBadRequest},
+		{"current version", "application/merge-patch+json", `"2"`, `{"firstName":"Ada","lastName":null}`, http.StatusOK},
+		{"any version", "application/merge-patch+json", "*", `{"lastName":"Lovelace"}`, http.StatusOK},
+	}
+	for _, test := range tests {
+		w := patch(test.contentType, test.ifMatch, test.body)
+		if w.Code != test.expected {
+			t.Errorf("UpdateMyProfile() %s error:\n   expected: %v\n   got:      %v %s", test.name, test.expected, w.Code, w.Body)
+		}
+	}
+
+	stored := database.users["alice"]
+	if stored.FirstName != "Ada" || stored.LastName != "Lovelace" || len(stored.Roles) != 0 || stored.Version != 4 {
+		t.Errorf("UpdateMyProfile() error:\n   expected: Ada Lovelace at version 4 without roles\n   got:      %+v", stored)
+	}
+
+	w := patch("application/merge-patch+json", `"4"`, `{}`)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"4"` {
+		t.Errorf("UpdateMyProfile() ETag error:\n   expected: %v \"4\"\n   got:      %v %v", http.StatusOK, w.Code, w.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+)
+
+// maxUsernamePeek is how much of a request body is read to find the username for rate limiting
+const maxUsernamePeek = 64 << 10
+
+// usernameFromBody finds the username field of a JSON or form body, the body is put back for the handler
+var usernameFromBody = bodyField("username")
+
+// emailFromBody finds the email field instead, for routes that are limited per address
+var emailFromBody = bodyField("email")
+
+// bodyField returns a function that finds a field of a JSON or form body, the body is put back for the handler
+func bodyField(name string) func(r *http.Request) string {
+	return func(r *http.Request) string {
+		return peekBodyField(r, name)
+	}
+}
+
+func peekBodyField(r *http.Request, name string) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxUsernamePeek))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return ""
+		}
+		return values.Get(name)
+	}
+
+	var request map[string]interface{}
+	if json.Unmarshal(body, &request) != nil {
+		return ""
+	}
+	value, _ := request[name].(string)
+
+	return value
+}
+
+// usernameFromToken is the username of the bearer token, for routes that are only called once signed in
+func (s *LoginService) usernameFromToken(r *http.Request) string {
+	claims, err := s.authenticate(r)
+	if err != nil {
+		return ""
+	}
+
+	return claims.Username
+}
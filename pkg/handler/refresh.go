@@ -0,0 +1,185 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrInvalidRefreshToken is returned for refresh tokens that are unknown, expired or revoked
+var ErrInvalidRefreshToken = errors.New("refresh token is invalid")
+
+// RefreshRequest is the body of a refresh token request
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// issueRefreshToken stores a new refresh token in the family and returns the value for the client, clientID is empty
+// for first party logins
+func (s *LoginService) issueRefreshToken(user *models.User, clientID string, scope string, family string) (string, error) {
+	value, hash, err := token.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	refreshToken := &models.RefreshToken{
+		Hash:      hash,
+		Family:    family,
+		UserID:    user.ID,
+		Username:  user.Username,
+		ClientID:  clientID,
+		Scope:     scope,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.RefreshLifetime),
+	}
+
+	err = s.Database.CreateRefreshToken(refreshToken)
+	if err != nil {
+		return "", err
+	}
+
+	return value, nil
+}
+
+// newTokenResponse pairs an access token with a refresh token from a new family
+func (s *LoginService) newTokenResponse(user *models.User, scope string, accessToken string) (*models.TokenResponse, error) {
+	return s.newClientTokenResponse(user, "", scope, accessToken)
+}
+
+// newClientTokenResponse is newTokenResponse for tokens issued to a client through the token endpoint, the refresh
+// token can only be redeemed by the same client
+func (s *LoginService) newClientTokenResponse(user *models.User, clientID string, scope string, accessToken string) (*models.TokenResponse, error) {
+	family, err := token.NewID()
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := s.issueRefreshToken(user, clientID, scope, family)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.Tokens.Lifetime.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        scope,
+	}, nil
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a new refresh token, presenting a refresh token
+// that was already exchanged revokes every token of its family
+func (s *LoginService) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	log.Infof("RefreshToken invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	var request RefreshRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.RefreshToken == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	response, err := s.exchangeRefreshToken(request.RefreshToken, "")
+	if errors.Is(err, ErrInvalidRefreshToken) || errors.Is(err, token.ErrRefreshTokenReused) {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// exchangeRefreshToken rotates the refresh token and issues a new access token for its user, the refresh token has to
+// have been issued to the client, an empty clientID for first party logins
+func (s *LoginService) exchangeRefreshToken(value string, clientID string) (*models.TokenResponse, error) {
+	hash := token.HashOpaqueToken(value)
+	stored, err := s.Database.GetRefreshToken(hash)
+	if err != nil {
+		if api.CheckError(err) == http.StatusNotFound {
+			return nil, ErrInvalidRefreshToken
+		}
+		return nil, err
+	}
+
+	if stored.UsedAt != nil {
+		s.revokeRefreshTokenFamily(stored)
+		return nil, token.ErrRefreshTokenReused
+	}
+	if stored.Revoked || time.Now().After(stored.ExpiresAt) || stored.ClientID != clientID {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	next, nextHash, err := token.NewOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	err = s.Database.RotateRefreshToken(hash, &models.RefreshToken{
+		Hash:      nextHash,
+		Family:    stored.Family,
+		UserID:    stored.UserID,
+		Username:  stored.Username,
+		ClientID:  stored.ClientID,
+		Scope:     stored.Scope,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.RefreshLifetime),
+	})
+	if errors.Is(err, token.ErrRefreshTokenReused) {
+		s.revokeRefreshTokenFamily(stored)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.refreshTokenUser(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, _, err := s.Tokens.IssueForClient(user, stored.ClientID, stored.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.Tokens.Lifetime.Seconds()),
+		RefreshToken: next,
+		Scope:        stored.Scope,
+	}, nil
+}
+
+func (s *LoginService) revokeRefreshTokenFamily(stored *models.RefreshToken) {
+	log.Warnf("Refresh token reuse detected for %v, revoking token family %v", stored.Username, stored.Family)
+
+	err := s.Database.RevokeRefreshTokenFamily(stored.Family)
+	if err != nil {
+		log.Errorf("Unable to revoke refresh token family %v: %v", stored.Family, err)
+	}
+}
+
+// refreshTokenUser finds the user a refresh token was issued to by their ID, refresh tokens from before they carried
+// the user's ID are looked up by username
+func (s *LoginService) refreshTokenUser(stored *models.RefreshToken) (*models.User, error) {
+	if stored.UserID.IsZero() {
+		return s.Database.GetUser(stored.Username)
+	}
+
+	return s.Database.GetUserByID(stored.UserID.Hex())
+}
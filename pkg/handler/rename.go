@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/audit"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ChangeUsernameRequest is the body of POST /users/me/username
+type ChangeUsernameRequest struct {
+	NewUsername string `json:"newUsername"`
+	Password    string `json:"password"`
+}
+
+// ChangeUsername renames the user of the bearer token after checking their password. Every token issued under the old
+// name is revoked and the caller carries on with the new tokens in the response
+func (s *LoginService) ChangeUsername(w http.ResponseWriter, r *http.Request) {
+	log.Infof("ChangeUsername invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request ChangeUsernameRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Password == "" || request.NewUsername == "" ||
+		strings.TrimSpace(request.NewUsername) != request.NewUsername || len(request.NewUsername) > maxProfileValue {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	current, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+	if request.NewUsername == current.Username {
+		api.RespondWithError(w, http.StatusBadRequest, "New username is the current username")
+		return
+	}
+
+	_, err = s.Database.AuthenticateUser(&models.User{Username: current.Username, Password: request.Password})
+	if err != nil {
+		api.RespondWithError(w, http.StatusForbidden, "Password is incorrect")
+		return
+	}
+
+	err = s.Database.RenameUser(current.Username, request.NewUsername)
+	if errors.Is(err, models.ErrUsernameTaken) || errors.Is(err, models.ErrUsernameReserved) {
+		api.RespondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	s.recordAudit(audit.UserRenamed, current.Username, request.NewUsername, map[string]string{"from": current.Username})
+
+	// Tokens carry the old username, none of them can be used any more. The user from before the rename still has the
+	// old name, so refresh tokens that only have the username are revoked as well
+	err = s.revokeSessions(current)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	user, err := s.Database.GetUserByID(current.ID.Hex())
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	accessToken, _, err := s.Tokens.Issue(user, "")
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	response, err := s.newTokenResponse(user, "", accessToken)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	respondWithTokens(w, response)
+}
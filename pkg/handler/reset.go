@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/mail"
+	"github.com/geeksheik9/login-service/pkg/password"
+	"github.com/geeksheik9/login-service/pkg/token"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// forgotPasswordResponse is sent whether or not the account exists, so the endpoint can not be used to find accounts
+const forgotPasswordResponse = "If the account exists a password reset email has been sent"
+
+// ForgotPasswordRequest is the body of POST /password/forgot
+type ForgotPasswordRequest struct {
+	Username string `json:"username"`
+}
+
+// ResetPasswordRequest is the body of POST /password/reset
+type ResetPasswordRequest struct {
+	Token       string `json:"token"`
+	NewPassword string `json:"newPassword"`
+}
+
+// ForgotPassword emails a single use password reset token to the user
+func (s *LoginService) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	log.Infof("ForgotPassword invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	var request ForgotPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Username == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	// The lookup and the mail are done after responding so the response time does not give away whether the
+	// account exists either
+	go s.sendPasswordReset(request.Username)
+
+	api.RespondWithJSON(w, http.StatusAccepted, forgotPasswordResponse)
+}
+
+// sendPasswordReset creates a reset token for the user and mails it, failures can only be logged
+func (s *LoginService) sendPasswordReset(username string) {
+	user, err := s.Database.GetUser(username)
+	if err != nil {
+		log.Debugf("No password reset sent for %s: %v", username, err)
+		return
+	}
+
+	to := recipient(user)
+	if to == "" || s.Mailer == nil {
+		log.Warnf("No password reset sent for %s: no email address or mailer", username)
+		return
+	}
+
+	value, hash, err := token.NewOpaqueToken()
+	if err != nil {
+		log.Errorf("Error creating password reset for %s: %v", username, err)
+		return
+	}
+
+	now := time.Now().UTC()
+	err = s.Database.CreatePasswordReset(&models.PasswordReset{
+		Hash:      hash,
+		Username:  user.Username,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.PasswordResetLifetime),
+	})
+	if err != nil {
+		log.Errorf("Error creating password reset for %s: %v", username, err)
+		return
+	}
+
+	body := "Someone asked to reset the password of your account " + user.Username + ".\n\n" +
+		"Use this code to choose a new password, it works once and expires in " + s.PasswordResetLifetime.String() + ":\n\n" +
+		value + "\n"
+	if s.PasswordResetURL != "" {
+		body += "\nOr open " + s.PasswordResetURL + "?" + url.Values{"token": {value}}.Encode() + "\n"
+	}
+	body += "\nIf you did not ask for this you can ignore this email, your password has not been changed.\n"
+
+	err = s.Mailer.Send(&mail.Message{To: to, Subject: "Reset your password", Body: body})
+	if err != nil {
+		log.Errorf("Error sending password reset for %s: %v", username, err)
+	}
+}
+
+// recipient is the address mail for a user goes to, usernames that are email addresses are used as one for users
+// from before there was an email field
+func recipient(user *models.User) string {
+	if user.Email != "" {
+		return user.Email
+	}
+	if mail.IsAddress(strings.TrimSpace(user.Username)) {
+		return strings.TrimSpace(user.Username)
+	}
+
+	return ""
+}
+
+// ResetPassword sets a new password with a reset token, every session of the user is revoked
+func (s *LoginService) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	log.Infof("ResetPassword invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	var request ResetPasswordRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Token == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	hash := token.HashOpaqueToken(request.Token)
+	reset, err := s.Database.GetPasswordReset(hash)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid or expired reset token")
+		return
+	}
+
+	user, err := s.Database.GetUser(reset.Username)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid or expired reset token")
+		return
+	}
+
+	// The policy is checked before the token is used up so the user can try another password
+	if !s.checkPasswordPolicy(w, request.NewPassword, user) || !s.checkPasswordHistory(w, user.Username, request.NewPassword) {
+		return
+	}
+
+	err = s.Database.ConsumePasswordReset(hash)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid or expired reset token")
+		return
+	}
+
+	err = s.Database.ChangePassword(user.Username, request.NewPassword)
+	if errors.Is(err, password.ErrPasswordReused) {
+		respondWithPasswordReused(w)
+		return
+	}
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	err = s.revokeSessions(user)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, "Password Reset")
+}
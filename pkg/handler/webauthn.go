@@ -0,0 +1,538 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"github.com/geeksheik9/login-service/pkg/api"
+	"github.com/geeksheik9/login-service/pkg/token"
+	"github.com/geeksheik9/login-service/pkg/webauthn"
+
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrInvalidPasskey is returned for an assertion that does not belong to a live ceremony or a registered passkey,
+// the reason is only logged
+var ErrInvalidPasskey = errors.New("passkey could not be verified")
+
+// BeginWebAuthnRegistrationRequest is the body of POST /webauthn/register/begin, the code is needed once an
+// authenticator app is enrolled and the assertion, from /webauthn/login/begin, once only passkeys are
+type BeginWebAuthnRegistrationRequest struct {
+	Password  string                        `json:"password"`
+	Code      string                        `json:"code"`
+	Assertion *webauthn.AssertionCredential `json:"assertion,omitempty"`
+}
+
+// WebAuthnRegistrationRequest is the body of POST /webauthn/register/finish
+type WebAuthnRegistrationRequest struct {
+	Name       string                          `json:"name"`
+	Credential webauthn.RegistrationCredential `json:"credential"`
+}
+
+// WebAuthnLoginRequest is the body of POST /webauthn/login/begin, without a username any discoverable passkey can
+// answer
+type WebAuthnLoginRequest struct {
+	Username string `json:"username"`
+}
+
+// WebAuthnAssertionRequest is the body of the endpoints that finish a login with a passkey, the MFA token is only used
+// by /login/mfa/webauthn
+type WebAuthnAssertionRequest struct {
+	MFAToken   string                       `json:"mfaToken,omitempty"`
+	Credential webauthn.AssertionCredential `json:"credential"`
+}
+
+// DeleteWebAuthnCredentialRequest is the body of DELETE /webauthn/credentials/{id}
+type DeleteWebAuthnCredentialRequest struct {
+	Password string `json:"password"`
+}
+
+// credentialDescriptors lists the user's passkeys for allowCredentials and excludeCredentials
+func credentialDescriptors(user *models.User) []webauthn.CredentialDescriptor {
+	descriptors := []webauthn.CredentialDescriptor{}
+	for _, credential := range user.WebAuthnCredentials {
+		id, err := base64.RawURLEncoding.DecodeString(credential.ID)
+		if err != nil {
+			continue
+		}
+		descriptors = append(descriptors, webauthn.CredentialDescriptor{Type: "public-key", ID: id, Transports: credential.Transports})
+	}
+
+	return descriptors
+}
+
+// newWebAuthnSession stores a new challenge for a ceremony and returns it
+func (s *LoginService) newWebAuthnSession(session *models.WebAuthnSession) (string, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return "", err
+	}
+
+	session.Challenge = challenge
+	session.ExpiresAt = time.Now().UTC().Add(s.WebAuthnTimeout)
+	err = s.Database.CreateWebAuthnSession(session)
+	if err != nil {
+		return "", err
+	}
+
+	return challenge, nil
+}
+
+// requestOptions are the options for navigator.credentials.get()
+func (s *LoginService) requestOptions(challenge string, allow []webauthn.CredentialDescriptor, userVerification string) webauthn.RequestOptions {
+	if allow == nil {
+		allow = []webauthn.CredentialDescriptor{}
+	}
+
+	return webauthn.RequestOptions{
+		Challenge:        challenge,
+		RelyingPartyID:   s.WebAuthn.ID,
+		Timeout:          s.WebAuthnTimeout.Milliseconds(),
+		AllowCredentials: allow,
+		UserVerification: userVerification,
+	}
+}
+
+// verifyPasskey checks an assertion against the ceremony its challenge was issued for and the passkey it was made
+// with, and stores the new signature counter
+func (s *LoginService) verifyPasskey(credential *webauthn.AssertionCredential, ceremony string, userVerification bool) (*models.User, *models.WebAuthnSession, error) {
+	challenge, err := webauthn.Challenge(credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session, err := s.Database.ConsumeWebAuthnSession(challenge)
+	if err != nil {
+		return nil, nil, ErrInvalidPasskey
+	}
+	if session.Ceremony != ceremony {
+		return nil, nil, ErrInvalidPasskey
+	}
+
+	id := base64.RawURLEncoding.EncodeToString(credential.RawID)
+	user, err := s.Database.GetUserByWebAuthnCredential(id)
+	if err != nil {
+		return nil, nil, ErrInvalidPasskey
+	}
+	if session.Username != "" && session.Username != user.Username {
+		return nil, nil, ErrInvalidPasskey
+	}
+	if len(credential.Response.UserHandle) > 0 && base64.RawURLEncoding.EncodeToString(credential.Response.UserHandle) != user.WebAuthnID {
+		return nil, nil, ErrInvalidPasskey
+	}
+
+	stored := user.WebAuthnCredential(id)
+	signCount, err := s.WebAuthn.VerifyAssertion(challenge, &webauthn.Credential{
+		ID:        credential.RawID,
+		PublicKey: stored.PublicKey,
+		Algorithm: stored.Algorithm,
+		SignCount: stored.SignCount,
+	}, &credential.Response, userVerification)
+	if err != nil {
+		log.Warnf("Passkey %s of %s failed verification: %v", id, user.Username, err)
+		return nil, nil, ErrInvalidPasskey
+	}
+
+	err = s.Database.UpdateWebAuthnSignCount(user.Username, id, stored.SignCount, signCount)
+	if err != nil {
+		return nil, nil, ErrInvalidPasskey
+	}
+
+	return user, session, nil
+}
+
+// checkRegistrationSecondFactor checks the second factor the user already has before another passkey is added, a code
+// for users with an authenticator app and an assertion from one of their passkeys for users with only passkeys
+func (s *LoginService) checkRegistrationSecondFactor(user *models.User, request *BeginWebAuthnRegistrationRequest) error {
+	if user.TOTPEnabled() {
+		return s.verifySecondFactor(user, request.Code)
+	}
+	if !user.MFAEnabled() {
+		return nil
+	}
+	if request.Assertion == nil {
+		return ErrInvalidPasskey
+	}
+
+	owner, _, err := s.verifyPasskey(request.Assertion, models.WebAuthnLogin, false)
+	if err != nil {
+		return err
+	}
+	if owner.Username != user.Username {
+		return ErrInvalidPasskey
+	}
+
+	return nil
+}
+
+// BeginWebAuthnRegistration returns the options to create a passkey for the user of the bearer token, it takes the
+// password and the second factor the user already has so a stolen access token is not enough to add a passkey
+func (s *LoginService) BeginWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	log.Infof("BeginWebAuthnRegistration invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request BeginWebAuthnRegistrationRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Password == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	current, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	user, err := s.Database.AuthenticateUser(&models.User{Username: current.Username, Password: request.Password})
+	if err != nil {
+		api.RespondWithError(w, http.StatusForbidden, "Password is incorrect")
+		return
+	}
+
+	err = s.checkRegistrationSecondFactor(user, &request)
+	if err != nil {
+		api.RespondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	// The user handle is random so it says nothing about the user and survives a change of username
+	handle := make([]byte, 32)
+	_, err = rand.Read(handle)
+	if err != nil {
+		api.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	user.WebAuthnID, err = s.Database.EnsureWebAuthnID(user.Username, base64.RawURLEncoding.EncodeToString(handle))
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+	userID, err := base64.RawURLEncoding.DecodeString(user.WebAuthnID)
+	if err != nil {
+		api.RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	challenge, err := s.newWebAuthnSession(&models.WebAuthnSession{Ceremony: models.WebAuthnRegistration, Username: user.Username})
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	displayName := token.FullName(user)
+	if displayName == "" {
+		displayName = user.Username
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	api.RespondWithJSON(w, http.StatusOK, webauthn.CreationOptions{
+		Challenge:          challenge,
+		RelyingParty:       webauthn.RelyingPartyEntity{ID: s.WebAuthn.ID, Name: s.WebAuthn.Name},
+		User:               webauthn.UserEntity{ID: userID, Name: user.Username, DisplayName: displayName},
+		Parameters:         webauthn.Parameters(),
+		Timeout:            s.WebAuthnTimeout.Milliseconds(),
+		ExcludeCredentials: credentialDescriptors(user),
+		AuthenticatorSelection: webauthn.AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: "preferred",
+		},
+		Attestation: "none",
+	})
+}
+
+// FinishWebAuthnRegistration verifies the new passkey and stores it for the user of the bearer token, the challenge
+// has to come from a registration begun by the same user, which is where the password and second factor are checked
+func (s *LoginService) FinishWebAuthnRegistration(w http.ResponseWriter, r *http.Request) {
+	log.Infof("FinishWebAuthnRegistration invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request WebAuthnRegistrationRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Credential.Type != "public-key" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	user, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	challenge, err := webauthn.Challenge(request.Credential.Response.ClientDataJSON)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	session, err := s.Database.ConsumeWebAuthnSession(challenge)
+	if err != nil || session.Ceremony != models.WebAuthnRegistration || session.Username != user.Username {
+		api.RespondWithError(w, http.StatusBadRequest, "Registration challenge is invalid or expired")
+		return
+	}
+
+	credential, err := s.WebAuthn.VerifyRegistration(challenge, &request.Credential.Response, false)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	name := request.Name
+	if name == "" {
+		name = "Passkey"
+	}
+	stored := &models.WebAuthnCredential{
+		ID:         base64.RawURLEncoding.EncodeToString(credential.ID),
+		Name:       name,
+		PublicKey:  credential.PublicKey,
+		Algorithm:  credential.Algorithm,
+		SignCount:  credential.SignCount,
+		AAGUID:     credential.AAGUID,
+		Transports: request.Credential.Response.Transports,
+		CreatedAt:  time.Now().UTC(),
+	}
+	err = s.Database.AddWebAuthnCredential(user.Username, stored)
+	if err != nil {
+		api.RespondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusCreated, stored)
+}
+
+// BeginWebAuthnLogin returns the options to log in with a passkey instead of a password
+func (s *LoginService) BeginWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	log.Infof("BeginWebAuthnLogin invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	var request WebAuthnLoginRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	// Unknown users get options without credentials, the same as a user who only has discoverable passkeys
+	var allow []webauthn.CredentialDescriptor
+	if request.Username != "" {
+		user, err := s.Database.GetUser(request.Username)
+		if err == nil {
+			allow = credentialDescriptors(user)
+		}
+	}
+
+	challenge, err := s.newWebAuthnSession(&models.WebAuthnSession{Ceremony: models.WebAuthnLogin, Username: request.Username})
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	api.RespondWithJSON(w, http.StatusOK, s.requestOptions(challenge, allow, "required"))
+}
+
+// FinishWebAuthnLogin verifies a passkey assertion and issues the tokens /login would have, the passkey has to have
+// verified the user so it stands in for both the password and the second factor
+func (s *LoginService) FinishWebAuthnLogin(w http.ResponseWriter, r *http.Request) {
+	log.Infof("FinishWebAuthnLogin invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	var request WebAuthnAssertionRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Credential.Type != "public-key" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	user, _, err := s.verifyPasskey(&request.Credential, models.WebAuthnLogin, true)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		api.RespondWithError(w, http.StatusUnauthorized, ErrInvalidPasskey.Error())
+		return
+	}
+	if s.checkEmailVerified(user) != nil {
+		api.RespondWithError(w, http.StatusForbidden, "Verify your email address before logging in")
+		return
+	}
+
+	accessToken, _, err := s.Tokens.Issue(user, "")
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	response, err := s.newTokenResponse(user, "", accessToken)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	respondWithTokens(w, response)
+}
+
+// BeginWebAuthnMFA returns the options to finish a login that needed a second factor with a passkey, each call uses
+// up one of the interim token's attempts
+func (s *LoginService) BeginWebAuthnMFA(w http.ResponseWriter, r *http.Request) {
+	log.Infof("BeginWebAuthnMFA invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	var request LoginMFARequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.MFAToken == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	hash := token.HashOpaqueToken(request.MFAToken)
+	challenge, err := s.Database.AttemptMFAChallenge(hash, mfaChallengeAttempts)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, "MFA token is invalid or expired, log in again")
+		return
+	}
+
+	user, err := s.Database.GetUser(challenge.Username)
+	if err != nil || len(user.WebAuthnCredentials) == 0 {
+		api.RespondWithError(w, http.StatusUnauthorized, "No passkey is registered for this account")
+		return
+	}
+
+	webauthnChallenge, err := s.newWebAuthnSession(&models.WebAuthnSession{
+		Ceremony:     models.WebAuthnMFA,
+		Username:     user.Username,
+		MFAChallenge: hash,
+	})
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	api.RespondWithJSON(w, http.StatusOK, s.requestOptions(webauthnChallenge, credentialDescriptors(user), "discouraged"))
+}
+
+// FinishWebAuthnMFA verifies a passkey given as the second factor and issues the tokens /login would have
+func (s *LoginService) FinishWebAuthnMFA(w http.ResponseWriter, r *http.Request) {
+	log.Infof("FinishWebAuthnMFA invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	var request WebAuthnAssertionRequest
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.MFAToken == "" || request.Credential.Type != "public-key" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	user, session, err := s.verifyPasskey(&request.Credential, models.WebAuthnMFA, false)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	hash := token.HashOpaqueToken(request.MFAToken)
+	if session.MFAChallenge != hash {
+		api.RespondWithError(w, http.StatusUnauthorized, ErrInvalidPasskey.Error())
+		return
+	}
+	err = s.Database.ConsumeMFAChallenge(hash)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, "MFA token is invalid or expired, log in again")
+		return
+	}
+
+	accessToken, _, err := s.Tokens.Issue(user, "")
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	response, err := s.newTokenResponse(user, "", accessToken)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	respondWithTokens(w, response)
+}
+
+// GetWebAuthnCredentials lists the passkeys of the user of the bearer token, public keys are never returned
+func (s *LoginService) GetWebAuthnCredentials(w http.ResponseWriter, r *http.Request) {
+	log.Infof("GetWebAuthnCredentials invoked with URL: %v", r.URL)
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	user, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	credentials := user.WebAuthnCredentials
+	if credentials == nil {
+		credentials = []models.WebAuthnCredential{}
+	}
+	api.RespondWithJSON(w, http.StatusOK, credentials)
+}
+
+// DeleteWebAuthnCredential removes a passkey of the user of the bearer token after checking their password
+func (s *LoginService) DeleteWebAuthnCredential(w http.ResponseWriter, r *http.Request) {
+	log.Infof("DeleteWebAuthnCredential invoked with URL: %v", r.URL)
+	defer r.Body.Close()
+
+	claims, err := s.authenticateUser(r)
+	if err != nil {
+		api.RespondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+
+	var request DeleteWebAuthnCredentialRequest
+	err = json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Password == "" {
+		api.RespondWithError(w, http.StatusBadRequest, "Invalid Request Payload")
+		return
+	}
+
+	user, err := s.currentUser(claims)
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	_, err = s.Database.AuthenticateUser(&models.User{Username: user.Username, Password: request.Password})
+	if err != nil {
+		api.RespondWithError(w, http.StatusForbidden, "Password is incorrect")
+		return
+	}
+
+	err = s.Database.DeleteWebAuthnCredential(user.Username, mux.Vars(r)["id"])
+	if err != nil {
+		api.RespondWithError(w, api.CheckError(err), err.Error())
+		return
+	}
+
+	api.RespondWithJSON(w, http.StatusOK, "Passkey Deleted")
+}
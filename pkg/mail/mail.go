@@ -0,0 +1,67 @@
+// Package mail sends the emails the service needs, such as password reset links, through a pluggable Mailer.
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"mime"
+	"net/mail"
+	"strings"
+	"time"
+)
+
+// ErrInvalidAddress is returned for a recipient that is not a single email address
+var ErrInvalidAddress = errors.New("invalid email address")
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages
+type Mailer interface {
+	Send(message *Message) error
+}
+
+// IsAddress reports whether the value is a single bare email address such as user@example.com
+func IsAddress(value string) bool {
+	address, err := mail.ParseAddress(value)
+	return err == nil && address.Address == value
+}
+
+// NormalizeAddress trims and lower cases an email address so the same mailbox is always stored the same way, the
+// local part is lower cased too as practically no mail server treats it as case sensitive
+func NormalizeAddress(value string) (string, error) {
+	address := strings.ToLower(strings.TrimSpace(value))
+	if !IsAddress(address) {
+		return "", ErrInvalidAddress
+	}
+
+	return address, nil
+}
+
+// Bytes returns the message in RFC 5322 form, header values are checked so a recipient or subject can not add
+// headers of its own
+func (m *Message) Bytes(from string, date time.Time) ([]byte, error) {
+	if !IsAddress(m.To) || !IsAddress(from) {
+		return nil, ErrInvalidAddress
+	}
+	if strings.ContainsAny(m.Subject, "\r\n") {
+		return nil, errors.New("subject must be a single line")
+	}
+
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "From: %s\r\n", from)
+	fmt.Fprintf(&buffer, "To: %s\r\n", m.To)
+	fmt.Fprintf(&buffer, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", m.Subject))
+	fmt.Fprintf(&buffer, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buffer.WriteString("MIME-Version: 1.0\r\n")
+	buffer.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buffer.WriteString("\r\n")
+	buffer.WriteString(strings.ReplaceAll(strings.ReplaceAll(m.Body, "\r\n", "\n"), "\n", "\r\n"))
+
+	return buffer.Bytes(), nil
+}
@@ -0,0 +1,107 @@
+package mail
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMessageBytes(t *testing.T) {
+	message := &Message{To: "user@example.com", Subject: "Reset your password", Body: "line one\nline two"}
+
+	body, err := message.Bytes("login@example.com", time.Date(2022, 9, 1, 12, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Bytes() returned error: %v", err)
+	}
+
+	expected := "From: login@example.com\r\nTo: user@example.com\r\nSubject: Reset your password\r\n" +
+		"Date: Thu, 01 Sep 2022 12:00:00 +0000\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n" +
+		"line one\r\nline two"
+	if string(body) != expected {
+		t.Errorf("Bytes() error:\n   expected: %q\n   got:      %q", expected, body)
+	}
+}
+
+func TestMessageBytes_HeaderInjection(t *testing.T) {
+	messages := []*Message{
+		{To: "user@example.com\r\nBcc: other@example.com", Subject: "Hello"},
+		{To: "user@example.com", Subject: "Hello\r\nBcc: other@example.com"},
+		{To: "User <user@example.com>", Subject: "Hello"},
+	}
+
+	for _, message := range messages {
+		_, err := message.Bytes("login@example.com", time.Now())
+		if err == nil {
+			t.Errorf("Bytes(%q, %q) error:\n   expected: <error>\n   got:      %v", message.To, message.Subject, err)
+		}
+	}
+}
+
+func TestIsAddress(t *testing.T) {
+	for value, expected := range map[string]bool{
+		"user@example.com":        true,
+		"user":                    false,
+		"":                        false,
+		"User <user@example.com>": false,
+	} {
+		if got := IsAddress(value); got != expected {
+			t.Errorf("IsAddress(%q) error:\n   expected: %v\n   got:      %v", value, expected, got)
+		}
+	}
+}
+
+func TestNormalizeAddress(t *testing.T) {
+	for value, expected := range map[string]string{
+		"user@example.com":         "user@example.com",
+		"  User@Example.COM\n":     "user@example.com",
+		"user":                     "",
+		"":                         "",
+		"User <user@example.com>":  "",
+		"user@example.com, o@e.io": "",
+	} {
+		got, err := NormalizeAddress(value)
+		if got != expected || (err != nil) != (expected == "") {
+			t.Errorf("NormalizeAddress(%q) error:\n   expected: %q\n   got:      %q, %v", value, expected, got, err)
+		}
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	mailer := &MemoryMailer{}
+
+	err := mailer.Send(&Message{To: "user@example.com", Subject: "Hello", Body: "Hi"})
+	if err != nil {
+		t.Fatalf("Send() returned error: %v", err)
+	}
+	err = mailer.Send(&Message{To: "user", Subject: "Hello"})
+	if err == nil {
+		t.Errorf("Send() error:\n   expected: <error>\n   got:      %v", err)
+	}
+
+	messages := mailer.Messages()
+	if len(messages) != 1 || messages[0].To != "user@example.com" {
+		t.Errorf("Messages() error:\n   expected: 1 message to user@example.com\n   got:      %v", messages)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	mailer := &FileMailer{Dir: t.TempDir(), From: "login@example.com"}
+
+	for i := 0; i < 2; i++ {
+		err := mailer.Send(&Message{To: "user@example.com", Subject: "Hello", Body: "Hi"})
+		if err != nil {
+			t.Fatalf("Send() returned error: %v", err)
+		}
+	}
+
+	files, err := mailer.Files()
+	if err != nil || len(files) != 2 {
+		t.Fatalf("Files() error:\n   expected: 2 files\n   got:      %v, %v", files, err)
+	}
+
+	contents, _ := os.ReadFile(files[0])
+	if !strings.Contains(string(contents), "To: user@example.com\r\n") {
+		t.Errorf("Send() error:\n   expected: To header\n   got:      %q", contents)
+	}
+}
@@ -0,0 +1,71 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// MemoryMailer keeps sent messages in memory for tests to read back, it never delivers anything and the service does
+// not offer it as a MAILER
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// Send records the message
+func (m *MemoryMailer) Send(message *Message) error {
+	if !IsAddress(message.To) {
+		return ErrInvalidAddress
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, *message)
+
+	return nil
+}
+
+// Messages returns the messages sent so far, the oldest first
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message(nil), m.messages...)
+}
+
+// FileMailer writes each message to its own .eml file in a directory, for tests and local development
+type FileMailer struct {
+	Dir  string
+	From string
+}
+
+// Send writes the message to the directory
+func (m *FileMailer) Send(message *Message) error {
+	now := time.Now()
+	body, err := message.Bytes(m.From, now)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(m.Dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(m.Dir, fmt.Sprintf("%d-*.eml", now.UnixNano()))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.Write(body)
+	return err
+}
+
+// Files returns the paths of the messages written so far
+func (m *FileMailer) Files() ([]string, error) {
+	return filepath.Glob(filepath.Join(m.Dir, "*.eml"))
+}
@@ -0,0 +1,127 @@
+package mfa
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcKey is the SHA-1 key of the test vectors in RFC 4226 and RFC 6238
+const rfcKey = "12345678901234567890"
+
+func TestHOTP(t *testing.T) {
+	expected := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for counter, code := range expected {
+		if got := hotp([]byte(rfcKey), uint64(counter), 6); got != code {
+			t.Errorf("hotp() error for counter %d:\n   expected: %v\n   got:      %v", counter, code, got)
+		}
+	}
+}
+
+func TestTOTPVectors(t *testing.T) {
+	cases := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for unix, code := range cases {
+		if got := hotp([]byte(rfcKey), uint64(Counter(time.Unix(unix, 0))), 8); got != code {
+			t.Errorf("hotp() error for time %d:\n   expected: %v\n   got:      %v", unix, code, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	secret := encoding.EncodeToString([]byte(rfcKey))
+	now := time.Unix(1111111111, 0)
+
+	code, err := Code(secret, now)
+	if err != nil {
+		t.Fatalf("Code() returned error: %v", err)
+	}
+	if code != "050471" {
+		t.Errorf("Code() error:\n   expected: %v\n   got:      %v", "050471", code)
+	}
+
+	cases := []struct {
+		name    string
+		at      time.Time
+		matched bool
+		counter int64
+	}{
+		{name: "same step", at: now, matched: true, counter: Counter(now)},
+		{name: "previous step", at: now.Add(Period), matched: true, counter: Counter(now)},
+		{name: "next step", at: now.Add(-Period), matched: true, counter: Counter(now)},
+		{name: "too late", at: now.Add(2 * Period), matched: false},
+	}
+	for _, c := range cases {
+		counter, ok := Validate(secret, code, c.at)
+		if ok != c.matched || counter != c.counter {
+			t.Errorf("Validate() error for %s:\n   expected: %v %v\n   got:      %v %v", c.name, c.counter, c.matched, counter, ok)
+		}
+	}
+
+	if _, ok := Validate(secret, "000000", now); ok && code != "000000" {
+		t.Errorf("Validate() error:\n   expected: wrong code refused\n   got:      accepted")
+	}
+	if _, ok := Validate("not base32!", code, now); ok {
+		t.Errorf("Validate() error:\n   expected: invalid secret refused\n   got:      accepted")
+	}
+}
+
+func TestNewSecret(t *testing.T) {
+	secret, err := NewSecret()
+	if err != nil {
+		t.Fatalf("NewSecret() returned error: %v", err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("NewSecret() error:\n   expected: %v characters\n   got:      %v", 32, len(secret))
+	}
+	if _, err := Code(secret, time.Now()); err != nil {
+		t.Errorf("Code() error:\n   expected: <nil>\n   got:      %v", err)
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("Login Service", "jane doe", "JBSWY3DPEHPK3PXP")
+	expected := "otpauth://totp/Login%20Service:jane%20doe?algorithm=SHA1&digits=6&issuer=Login+Service&period=30&secret=JBSWY3DPEHPK3PXP"
+	if got != expected {
+		t.Errorf("URI() error:\n   expected: %v\n   got:      %v", expected, got)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, hashes, err := NewRecoveryCodes(10)
+	if err != nil {
+		t.Fatalf("NewRecoveryCodes() returned error: %v", err)
+	}
+	if len(codes) != 10 || len(hashes) != 10 {
+		t.Fatalf("NewRecoveryCodes() error:\n   expected: %v codes\n   got:      %v codes and %v hashes", 10, len(codes), len(hashes))
+	}
+
+	seen := map[string]bool{}
+	for i, code := range codes {
+		if len(code) != 19 || strings.Count(code, "-") != 3 {
+			t.Errorf("NewRecoveryCodes() error:\n   expected: XXXX-XXXX-XXXX-XXXX\n   got:      %v", code)
+		}
+		if seen[hashes[i]] {
+			t.Errorf("NewRecoveryCodes() error:\n   expected: unique codes\n   got:      %v twice", code)
+		}
+		seen[hashes[i]] = true
+
+		relaxed := strings.ToLower(strings.ReplaceAll(code, "-", " "))
+		if got := HashRecoveryCode(relaxed); got != hashes[i] {
+			t.Errorf("HashRecoveryCode() error:\n   expected: %v\n   got:      %v", hashes[i], got)
+		}
+		if IsTOTPCode(code) {
+			t.Errorf("IsTOTPCode() error:\n   expected: false for %v\n   got:      true", code)
+		}
+	}
+
+	if !IsTOTPCode("123 456") {
+		t.Errorf("IsTOTPCode() error:\n   expected: true for %v\n   got:      false", "123 456")
+	}
+}
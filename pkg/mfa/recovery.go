@@ -0,0 +1,53 @@
+package mfa
+
+import (
+	"crypto/rand"
+	"strings"
+
+	"github.com/geeksheik9/login-service/pkg/token"
+)
+
+// recoveryCodeSize is the number of random bytes in a recovery code, 80 bits make for 16 base32 characters
+const recoveryCodeSize = 10
+
+// NewRecoveryCodes returns n one-time recovery codes for the user and the hashes that are stored in their place. The
+// codes are random so they are hashed like other opaque tokens
+func NewRecoveryCodes(n int) ([]string, []string, error) {
+	codes := make([]string, 0, n)
+	hashes := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		b := make([]byte, recoveryCodeSize)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		value := encoding.EncodeToString(b)
+		code := value[0:4] + "-" + value[4:8] + "-" + value[8:12] + "-" + value[12:16]
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// HashRecoveryCode hashes a recovery code for storage and lookup, dashes, spaces and case do not matter
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return token.HashOpaqueToken(normalized)
+}
+
+// IsTOTPCode tells a code from an authenticator app apart from a recovery code
+func IsTOTPCode(code string) bool {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != Digits {
+		return false
+	}
+	for _, r := range code {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	return true
+}
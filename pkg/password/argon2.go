@@ -0,0 +1,126 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	saltLength = 16
+	keyLength  = 32
+)
+
+// Argon2idHasher hashes passwords with Argon2id, memory is in KiB
+type Argon2idHasher struct {
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+}
+
+// argon2idParams are the parameters decoded from an Argon2id PHC string
+type argon2idParams struct {
+	version int
+	Argon2idHasher
+	salt []byte
+	hash []byte
+}
+
+// NewArgon2idHasher returns an Argon2id hasher
+func NewArgon2idHasher(time uint32, memory uint32, threads uint8) (*Argon2idHasher, error) {
+	if time < 1 || threads < 1 || memory < 8*uint32(threads) {
+		return nil, errors.New("argon2id needs a time and threads of at least 1 and at least 8 KiB of memory per thread")
+	}
+
+	return &Argon2idHasher{Time: time, Memory: memory, Threads: threads}, nil
+}
+
+// Hash returns the Argon2id PHC string of the password
+func (h *Argon2idHasher) Hash(password string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+
+	hash := argon2.IDKey([]byte(password), salt, h.Time, h.Memory, h.Threads, keyLength)
+
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", Argon2id, argon2.Version, h.Memory, h.Time, h.Threads,
+		encode(salt), encode(hash)), nil
+}
+
+// NeedsRehash is true for hashes of other algorithms and Argon2id hashes with different parameters
+func (h *Argon2idHasher) NeedsRehash(encoded string) bool {
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return true
+	}
+
+	return params.version != argon2.Version || params.Argon2idHasher != *h || len(params.hash) != keyLength
+}
+
+func verifyArgon2id(password string, encoded string) error {
+	params, err := decodeArgon2id(encoded)
+	if err != nil {
+		return err
+	}
+
+	hash := argon2.IDKey([]byte(password), params.salt, params.Time, params.Memory, params.Threads, uint32(len(params.hash)))
+	if subtle.ConstantTimeCompare(hash, params.hash) != 1 {
+		return ErrMismatchedPassword
+	}
+
+	return nil
+}
+
+// decodeArgon2id reads $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>
+func decodeArgon2id(encoded string) (*argon2idParams, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != Argon2id {
+		return nil, ErrInvalidHash
+	}
+
+	var params argon2idParams
+	_, err := fmt.Sscanf(parts[2], "v=%d", &params.version)
+	if err != nil {
+		return nil, ErrInvalidHash
+	}
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Time, &params.Threads)
+	if err != nil || params.Time < 1 || params.Threads < 1 {
+		return nil, ErrInvalidHash
+	}
+
+	params.salt, err = decode(parts[4])
+	if err != nil {
+		return nil, ErrInvalidHash
+	}
+	params.hash, err = decode(parts[5])
+	if err != nil || len(params.hash) == 0 {
+		return nil, ErrInvalidHash
+	}
+
+	return &params, nil
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, saltLength)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return salt, nil
+}
+
+// encode and decode use the unpadded standard base64 alphabet of the PHC string format
+func encode(value []byte) string {
+	return base64.RawStdEncoding.EncodeToString(value)
+}
+
+func decode(value string) ([]byte, error) {
+	return base64.RawStdEncoding.DecodeString(value)
+}
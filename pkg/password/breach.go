@@ -0,0 +1,149 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// sha1Prefix is the length of the hash prefixes Have I Been Pwned splits its range files by
+const sha1Prefix = 5
+
+// BreachList holds the SHA-1 hashes of passwords seen in breaches. Only the first 8 bytes of each hash are kept in a
+// sorted array, the chance of a password being refused because it shares those bytes with a breached one is about
+// one in 2^64 divided by the size of the list
+type BreachList struct {
+	hashes []uint64
+}
+
+// LoadBreachList reads passwords seen at least threshold times from a Have I Been Pwned download. The path is either
+// a file of HASH:COUNT lines or a directory of range files named after their five character prefix holding
+// SUFFIX:COUNT lines
+func LoadBreachList(path string, threshold int) (*BreachList, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &BreachList{}
+	if !info.IsDir() {
+		err = list.readFile(path, "", threshold)
+		if err != nil {
+			return nil, err
+		}
+		list.sort()
+		return list, nil
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		prefix := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if entry.IsDir() || len(prefix) != sha1Prefix {
+			continue
+		}
+
+		err = list.readFile(filepath.Join(path, entry.Name()), prefix, threshold)
+		if err != nil {
+			return nil, err
+		}
+	}
+	list.sort()
+
+	return list, nil
+}
+
+// NewBreachList reads HASH:COUNT lines, or SUFFIX:COUNT lines when a prefix is given
+func NewBreachList(r io.Reader, prefix string, threshold int) (*BreachList, error) {
+	list := &BreachList{}
+	err := list.read(r, prefix, threshold)
+	if err != nil {
+		return nil, err
+	}
+	list.sort()
+
+	return list, nil
+}
+
+// Contains reports whether the password has been seen in a breach at least the threshold number of times
+func (b *BreachList) Contains(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	value := binary.BigEndian.Uint64(sum[:8])
+
+	i := sort.Search(len(b.hashes), func(i int) bool { return b.hashes[i] >= value })
+	return i < len(b.hashes) && b.hashes[i] == value
+}
+
+// Len is the number of hashes in the list
+func (b *BreachList) Len() int {
+	return len(b.hashes)
+}
+
+func (b *BreachList) readFile(path string, prefix string, threshold int) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = b.read(file, prefix, threshold)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	return nil
+}
+
+func (b *BreachList) read(r io.Reader, prefix string, threshold int) error {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		parts := strings.SplitN(text, ":", 2)
+		count := 1
+		if len(parts) == 2 {
+			var err error
+			count, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+			if err != nil {
+				return fmt.Errorf("line %d: invalid count %q", line, parts[1])
+			}
+		}
+		if count < threshold {
+			continue
+		}
+
+		sum, err := hex.DecodeString(prefix + parts[0])
+		if err != nil || len(sum) != sha1.Size {
+			return fmt.Errorf("line %d: invalid SHA-1 hash %q", line, prefix+parts[0])
+		}
+		b.hashes = append(b.hashes, binary.BigEndian.Uint64(sum[:8]))
+	}
+
+	return scanner.Err()
+}
+
+// sort orders the hashes for binary search and drops duplicates
+func (b *BreachList) sort() {
+	sort.Slice(b.hashes, func(i, j int) bool { return b.hashes[i] < b.hashes[j] })
+
+	unique := b.hashes[:0]
+	for i, value := range b.hashes {
+		if i == 0 || value != b.hashes[i-1] {
+			unique = append(unique, value)
+		}
+	}
+	b.hashes = unique
+}
@@ -0,0 +1,82 @@
+package password
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// SHA-1 hashes of password, 123456 and letmein with made up counts
+const breachFile = `5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824
+7C4A8D09CA3762AF61E59520943DC26494F8941B:37359195
+
+b7a875fc1ea228b9061041b7cec4bd3c52ab3ce3:2
+`
+
+func TestNewBreachList(t *testing.T) {
+	list, err := NewBreachList(strings.NewReader(breachFile), "", 1)
+	if err != nil {
+		t.Fatalf("NewBreachList() returned error: %v", err)
+	}
+
+	for _, value := range []string{"password", "123456", "letmein"} {
+		if !list.Contains(value) {
+			t.Errorf("Contains(%s) error:\n   expected: true\n   got:      false", value)
+		}
+	}
+	if list.Contains("bluehouse7 on a hill") {
+		t.Errorf("Contains() error:\n   expected: false\n   got:      true")
+	}
+}
+
+func TestNewBreachList_Threshold(t *testing.T) {
+	list, err := NewBreachList(strings.NewReader(breachFile), "", 10)
+	if err != nil {
+		t.Fatalf("NewBreachList() returned error: %v", err)
+	}
+
+	if list.Len() != 2 || list.Contains("letmein") {
+		t.Errorf("NewBreachList() error:\n   expected: letmein left out\n   got:      %v hashes", list.Len())
+	}
+}
+
+func TestNewBreachList_Invalid(t *testing.T) {
+	for _, value := range []string{"not-a-hash:1", "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:many"} {
+		_, err := NewBreachList(strings.NewReader(value), "", 1)
+		if err == nil {
+			t.Errorf("NewBreachList(%s) error:\n   expected: <error>\n   got:      %v", value, err)
+		}
+	}
+}
+
+func TestLoadBreachList_RangeDirectory(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte("1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n"), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile() returned error: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "README"), []byte("ignored"), 0600)
+	if err != nil {
+		t.Fatalf("WriteFile() returned error: %v", err)
+	}
+
+	list, err := LoadBreachList(dir, 1)
+	if err != nil {
+		t.Fatalf("LoadBreachList() returned error: %v", err)
+	}
+
+	if !list.Contains("password") || list.Len() != 1 {
+		t.Errorf("LoadBreachList() error:\n   expected: password\n   got:      %v hashes", list.Len())
+	}
+}
+
+func TestPolicyCheck_Breached(t *testing.T) {
+	list, _ := NewBreachList(strings.NewReader(breachFile), "", 1)
+	policy := &Policy{Breaches: list}
+
+	found := rules(policy.Check("letmein"))
+	if !found[RuleBreached] {
+		t.Errorf("Check() error:\n   expected: %v\n   got:      %v", RuleBreached, found)
+	}
+}
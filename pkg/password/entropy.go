@@ -0,0 +1,168 @@
+package password
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// entropyLimit is how many runes Entropy looks at, the cost of splitting a password grows much faster than its length
+const entropyLimit = 100
+
+// commonPasswords are ranked by how often they turn up in leaked password lists, the most common first
+var commonPasswords = strings.Fields(`
+password qwerty letmein welcome monkey dragon football baseball iloveyou admin login master sunshine princess
+abc123 shadow superman batman trustno1 michael jennifer jordan hunter ranger buster soccer harley hockey killer
+george charlie andrew thomas robert daniel starwars computer pepper ginger summer winter spring autumn freedom
+whatever secret access flower hello love test guest root changeme default passw0rd pass word user qazwsx
+mustang cheese biteme matrix maggie cookie orange purple yellow silver golden diamond chelsea arsenal liverpool
+london tigger banana chocolate butterfly blink angel angels lovely family friends forever internet
+google samsung apple android pokemon minecraft fortnite gamer games player ninja zombie pirate wizard magic
+phoenix falcon eagle tiger lion wolf bear shark snake horse puppy kitten monster legend warrior knight
+`)
+
+// keyboardRows are walked forwards or backwards by people picking easy to type passwords
+var keyboardRows = []string{"qwertyuiop", "asdfghjkl", "zxcvbnm", "1234567890", "qazwsxedc"}
+
+// leetSubstitutions undo the usual character swaps so p4ssw0rd is still found as password
+var leetSubstitutions = strings.NewReplacer("4", "a", "@", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t", "+", "t")
+
+// Entropy estimates the bits of guessing work a password takes in the style of zxcvbn. The password is split into
+// the cheapest sequence of dictionary words, personal details, repeats, sequences, keyboard walks, years and
+// single characters, and the cost of each part is added up. Only the first entropyLimit runes are looked at
+func Entropy(password string, personal ...string) float64 {
+	original := []rune(password)
+	if len(original) > entropyLimit {
+		original = original[:entropyLimit]
+	}
+	lower := []rune(strings.ToLower(string(original)))
+	n := len(lower)
+
+	dictionary := map[string]int{}
+	for rank, word := range commonPasswords {
+		if _, ok := dictionary[word]; !ok {
+			dictionary[word] = rank + 1
+		}
+	}
+	for _, value := range personal {
+		for _, word := range strings.Fields(strings.ToLower(value)) {
+			dictionary[word] = 1
+		}
+	}
+
+	best := make([]float64, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = best[i-1] + bruteForceBits(original[i-1])
+		for j := 0; j <= i-3; j++ {
+			if bits, ok := patternBits(original[j:i], lower[j:i], dictionary); ok && best[j]+bits < best[i] {
+				best[i] = best[j] + bits
+			}
+		}
+	}
+
+	return best[n]
+}
+
+// patternBits returns the cost of a segment when it matches a pattern
+func patternBits(original []rune, lower []rune, dictionary map[string]int) (float64, bool) {
+	segment := string(lower)
+	length := float64(len(lower))
+	bits := math.Inf(1)
+
+	if rank, ok := dictionary[segment]; ok {
+		bits = math.Min(bits, math.Log2(float64(rank))+caseBits(original))
+	}
+	if unleet := leetSubstitutions.Replace(segment); unleet != segment {
+		if rank, ok := dictionary[unleet]; ok {
+			bits = math.Min(bits, math.Log2(float64(rank))+caseBits(original)+1)
+		}
+	}
+	if strings.Count(segment, string(lower[0])) == len(lower) {
+		bits = math.Min(bits, bruteForceBits(lower[0])+math.Log2(length))
+	}
+	if step, ok := sequenceStep(lower); ok {
+		descending := 0.0
+		if step < 0 {
+			descending = 1
+		}
+		bits = math.Min(bits, bruteForceBits(lower[0])+math.Log2(length)+descending)
+	}
+	for _, row := range keyboardRows {
+		if strings.Contains(row, segment) {
+			bits = math.Min(bits, math.Log2(float64(len(row)))+math.Log2(length))
+		}
+		if strings.Contains(row, reverse(segment)) {
+			bits = math.Min(bits, math.Log2(float64(len(row)))+math.Log2(length)+1)
+		}
+	}
+	if len(lower) == 4 && (strings.HasPrefix(segment, "19") || strings.HasPrefix(segment, "20")) && isDigits(segment) {
+		bits = math.Min(bits, math.Log2(200))
+	}
+
+	return bits, !math.IsInf(bits, 1)
+}
+
+// sequenceStep reports whether the runes go up or down one at a time, such as abcd or 9876
+func sequenceStep(runes []rune) (rune, bool) {
+	step := runes[1] - runes[0]
+	if step != 1 && step != -1 {
+		return 0, false
+	}
+	for i := 2; i < len(runes); i++ {
+		if runes[i]-runes[i-1] != step {
+			return 0, false
+		}
+	}
+
+	return step, unicode.IsLetter(runes[0]) || unicode.IsDigit(runes[0])
+}
+
+// caseBits is the extra work for capitals, a capital first letter or all capitals barely count
+func caseBits(runes []rune) float64 {
+	upper := 0
+	for _, r := range runes {
+		if unicode.IsUpper(r) {
+			upper++
+		}
+	}
+
+	switch {
+	case upper == 0:
+		return 0
+	case upper == len(runes) || (upper == 1 && unicode.IsUpper(runes[0])):
+		return 1
+	}
+
+	return float64(upper)
+}
+
+// bruteForceBits is the cost of a character that is not part of any pattern
+func bruteForceBits(r rune) float64 {
+	switch {
+	case unicode.IsDigit(r):
+		return math.Log2(10)
+	case unicode.IsLower(r), unicode.IsUpper(r):
+		return math.Log2(26)
+	}
+
+	return math.Log2(33)
+}
+
+func reverse(value string) string {
+	runes := []rune(value)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+
+	return string(runes)
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,104 @@
+// Package password hashes user passwords and checks them against stored hashes.
+//
+// Hashes are stored as PHC strings such as $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>, bcrypt keeps its own
+// $2a$<cost>$ form which the PHC format adopts as is. Every hash carries its algorithm and parameters, so a
+// stored hash can always be verified after the configured algorithm or parameters change.
+package password
+
+import (
+	"errors"
+	"strings"
+)
+
+// Algorithm names as used in configuration and in PHC strings
+const (
+	Bcrypt   = "bcrypt"
+	Argon2id = "argon2id"
+	Scrypt   = "scrypt"
+)
+
+var (
+	// ErrMismatchedPassword is returned when the password does not match the hash
+	ErrMismatchedPassword = errors.New("password does not match")
+	// ErrUnknownAlgorithm is returned for a hash or configuration naming an algorithm that is not supported
+	ErrUnknownAlgorithm = errors.New("unknown password hashing algorithm")
+	// ErrInvalidHash is returned when a stored hash can not be decoded
+	ErrInvalidHash = errors.New("invalid password hash")
+	// ErrInvalidCredentials is returned for an unknown user, a wrong password and a locked account alike, so a
+	// caller can not tell them apart
+	ErrInvalidCredentials = errors.New("incorrect username or password")
+	// ErrPasswordReused is returned when a new password matches the current one or one in the user's history
+	ErrPasswordReused = errors.New("password was used recently")
+)
+
+// Hasher hashes new passwords with one algorithm and set of parameters
+type Hasher interface {
+	// Hash returns the encoded hash of the password with a new random salt
+	Hash(password string) (string, error)
+	// NeedsRehash reports whether the encoded hash was made with another algorithm or other parameters
+	NeedsRehash(encoded string) bool
+}
+
+// Options are the parameters for each algorithm, only the ones for the chosen algorithm are used
+type Options struct {
+	BcryptCost    int
+	Argon2Time    uint32
+	Argon2Memory  uint32
+	Argon2Threads uint8
+	ScryptN       int
+	ScryptR       int
+	ScryptP       int
+}
+
+// NewHasher returns the hasher for the named algorithm
+func NewHasher(algorithm string, options Options) (Hasher, error) {
+	switch strings.ToLower(algorithm) {
+	case Bcrypt:
+		return NewBcryptHasher(options.BcryptCost)
+	case Argon2id:
+		return NewArgon2idHasher(options.Argon2Time, options.Argon2Memory, options.Argon2Threads)
+	case Scrypt:
+		return NewScryptHasher(options.ScryptN, options.ScryptR, options.ScryptP)
+	}
+
+	return nil, ErrUnknownAlgorithm
+}
+
+// Verify checks the password against an encoded hash made by any of the supported algorithms
+func Verify(password string, encoded string) error {
+	switch Identify(encoded) {
+	case Bcrypt:
+		return verifyBcrypt(password, encoded)
+	case Argon2id:
+		return verifyArgon2id(password, encoded)
+	case Scrypt:
+		return verifyScrypt(password, encoded)
+	}
+
+	return ErrUnknownAlgorithm
+}
+
+// Reused reports whether the password matches any of the hashes
+func Reused(password string, hashes ...string) bool {
+	for _, hash := range hashes {
+		if Verify(password, hash) == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Identify returns the algorithm an encoded hash was made with, or an empty string when it is not recognised
+func Identify(encoded string) string {
+	switch {
+	case strings.HasPrefix(encoded, "$2a$"), strings.HasPrefix(encoded, "$2b$"), strings.HasPrefix(encoded, "$2y$"):
+		return Bcrypt
+	case strings.HasPrefix(encoded, "$"+Argon2id+"$"):
+		return Argon2id
+	case strings.HasPrefix(encoded, "$"+Scrypt+"$"):
+		return Scrypt
+	}
+
+	return ""
+}
@@ -0,0 +1,174 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func testHashers(t *testing.T) map[string]Hasher {
+	bcryptHasher, err := NewBcryptHasher(4)
+	if err != nil {
+		t.Fatalf("NewBcryptHasher() returned error: %v", err)
+	}
+	argon2idHasher, err := NewArgon2idHasher(1, 64, 1)
+	if err != nil {
+		t.Fatalf("NewArgon2idHasher() returned error: %v", err)
+	}
+	scryptHasher, err := NewScryptHasher(16, 8, 1)
+	if err != nil {
+		t.Fatalf("NewScryptHasher() returned error: %v", err)
+	}
+
+	return map[string]Hasher{Bcrypt: bcryptHasher, Argon2id: argon2idHasher, Scrypt: scryptHasher}
+}
+
+func TestHashAndVerify(t *testing.T) {
+	for algorithm, hasher := range testHashers(t) {
+		encoded, err := hasher.Hash("correct horse")
+		if err != nil {
+			t.Fatalf("Hash() returned error for %s: %v", algorithm, err)
+		}
+
+		if got := Identify(encoded); got != algorithm {
+			t.Errorf("Identify() error:\n   expected: %v\n   got:      %v", algorithm, got)
+		}
+		if err := Verify("correct horse", encoded); err != nil {
+			t.Errorf("Verify() error for %s:\n   expected: <nil>\n   got:      %v", algorithm, err)
+		}
+		if err := Verify("wrong horse", encoded); !errors.Is(err, ErrMismatchedPassword) {
+			t.Errorf("Verify() error for %s:\n   expected: %v\n   got:      %v", algorithm, ErrMismatchedPassword, err)
+		}
+		if hasher.NeedsRehash(encoded) {
+			t.Errorf("NeedsRehash() error for %s:\n   expected: false\n   got:      true", algorithm)
+		}
+	}
+}
+
+func TestHash_Salted(t *testing.T) {
+	for algorithm, hasher := range testHashers(t) {
+		first, _ := hasher.Hash("correct horse")
+		second, _ := hasher.Hash("correct horse")
+		if first == second {
+			t.Errorf("Hash() error for %s:\n   expected: different hashes\n   got:      %v", algorithm, first)
+		}
+	}
+}
+
+func TestArgon2idHasher_Format(t *testing.T) {
+	hasher, _ := NewArgon2idHasher(2, 19456, 1)
+	encoded, _ := hasher.Hash("correct horse")
+
+	if !strings.HasPrefix(encoded, "$argon2id$v=19$m=19456,t=2,p=1$") {
+		t.Errorf("Hash() error:\n   expected: $argon2id$v=19$m=19456,t=2,p=1$...\n   got:      %v", encoded)
+	}
+}
+
+func TestScryptHasher_Format(t *testing.T) {
+	hasher, _ := NewScryptHasher(1024, 8, 1)
+	encoded, _ := hasher.Hash("correct horse")
+
+	if !strings.HasPrefix(encoded, "$scrypt$ln=10,r=8,p=1$") {
+		t.Errorf("Hash() error:\n   expected: $scrypt$ln=10,r=8,p=1$...\n   got:      %v", encoded)
+	}
+}
+
+func TestNeedsRehash_OtherAlgorithm(t *testing.T) {
+	hashers := testHashers(t)
+	encoded, _ := hashers[Bcrypt].Hash("correct horse")
+
+	if !hashers[Argon2id].NeedsRehash(encoded) {
+		t.Errorf("NeedsRehash() error:\n   expected: true\n   got:      false")
+	}
+	if !hashers[Scrypt].NeedsRehash(encoded) {
+		t.Errorf("NeedsRehash() error:\n   expected: true\n   got:      false")
+	}
+}
+
+func TestNeedsRehash_OtherParameters(t *testing.T) {
+	weak, _ := NewBcryptHasher(4)
+	strong, _ := NewBcryptHasher(5)
+	encoded, _ := weak.Hash("correct horse")
+	if !strong.NeedsRehash(encoded) {
+		t.Errorf("NeedsRehash() error for bcrypt:\n   expected: true\n   got:      false")
+	}
+
+	weakArgon2id, _ := NewArgon2idHasher(1, 64, 1)
+	strongArgon2id, _ := NewArgon2idHasher(2, 64, 1)
+	encoded, _ = weakArgon2id.Hash("correct horse")
+	if !strongArgon2id.NeedsRehash(encoded) {
+		t.Errorf("NeedsRehash() error for argon2id:\n   expected: true\n   got:      false")
+	}
+	if err := Verify("correct horse", encoded); err != nil {
+		t.Errorf("Verify() error for argon2id:\n   expected: <nil>\n   got:      %v", err)
+	}
+
+	weakScrypt, _ := NewScryptHasher(16, 8, 1)
+	strongScrypt, _ := NewScryptHasher(32, 8, 1)
+	encoded, _ = weakScrypt.Hash("correct horse")
+	if !strongScrypt.NeedsRehash(encoded) {
+		t.Errorf("NeedsRehash() error for scrypt:\n   expected: true\n   got:      false")
+	}
+}
+
+func TestVerify_KnownHash(t *testing.T) {
+	// scrypt hash of "password" with N=16 made by Python's hashlib.scrypt
+	encoded := "$scrypt$ln=4,r=8,p=1$c29tZXNhbHRzb21lc2FsdA$rjCGpPW8r+9XVz9RqXtAszWzNTGPgzIyDDbKAQjn6LU"
+
+	if err := Verify("password", encoded); err != nil {
+		t.Errorf("Verify() error:\n   expected: <nil>\n   got:      %v", err)
+	}
+}
+
+func TestVerify_InvalidHash(t *testing.T) {
+	for _, encoded := range []string{
+		"$argon2id$v=19$m=64,t=1,p=1$c29tZXNhbHQ",
+		"$argon2id$v=19$m=64,t=0,p=1$c29tZXNhbHQ$9Ax1ThpNe4FeBjyJ8ZX5EGFu",
+		"$scrypt$ln=4,r=8,p=1$c29tZXNhbHQ$",
+		"$2a$10$tooshort",
+	} {
+		if err := Verify("password", encoded); !errors.Is(err, ErrInvalidHash) {
+			t.Errorf("Verify(%s) error:\n   expected: %v\n   got:      %v", encoded, ErrInvalidHash, err)
+		}
+	}
+}
+
+func TestVerify_UnknownAlgorithm(t *testing.T) {
+	err := Verify("password", "$md5$abc")
+	if !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("Verify() error:\n   expected: %v\n   got:      %v", ErrUnknownAlgorithm, err)
+	}
+}
+
+func TestNewHasher(t *testing.T) {
+	options := Options{BcryptCost: 10, Argon2Time: 2, Argon2Memory: 19456, Argon2Threads: 1, ScryptN: 32768, ScryptR: 8, ScryptP: 1}
+
+	for _, algorithm := range []string{Bcrypt, Argon2id, Scrypt, "Argon2id"} {
+		if _, err := NewHasher(algorithm, options); err != nil {
+			t.Errorf("NewHasher(%s) error:\n   expected: <nil>\n   got:      %v", algorithm, err)
+		}
+	}
+
+	if _, err := NewHasher("md5", options); !errors.Is(err, ErrUnknownAlgorithm) {
+		t.Errorf("NewHasher() error:\n   expected: %v\n   got:      %v", ErrUnknownAlgorithm, err)
+	}
+	if _, err := NewHasher(Bcrypt, Options{BcryptCost: 40}); err == nil {
+		t.Errorf("NewHasher() error:\n   expected: <error>\n   got:      %v", err)
+	}
+	if _, err := NewHasher(Scrypt, Options{ScryptN: 1000, ScryptR: 8, ScryptP: 1}); err == nil {
+		t.Errorf("NewHasher() error:\n   expected: <error>\n   got:      %v", err)
+	}
+}
+
+func TestReused(t *testing.T) {
+	hasher, _ := NewBcryptHasher(4)
+	first, _ := hasher.Hash("first password")
+	second, _ := hasher.Hash("second password")
+
+	if !Reused("second password", first, second) {
+		t.Errorf("Reused() error:\n   expected: true\n   got:      false")
+	}
+	if Reused("third password", first, second) {
+		t.Errorf("Reused() error:\n   expected: false\n   got:      true")
+	}
+}
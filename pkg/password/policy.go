@@ -0,0 +1,105 @@
+package password
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Policy rule names, these are returned to clients so they can point at the rule that failed
+const (
+	RuleMinLength    = "min_length"
+	RuleMaxLength    = "max_length"
+	RuleUppercase    = "uppercase"
+	RuleLowercase    = "lowercase"
+	RuleDigit        = "digit"
+	RuleSymbol       = "symbol"
+	RulePersonalInfo = "personal_info"
+	RuleEntropy      = "entropy"
+	RuleBreached     = "breached"
+	RuleHistory      = "history"
+)
+
+// Violation is a policy rule a password failed
+type Violation struct {
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Policy is the set of rules a new password has to pass, a zero MaxLength or MinEntropy or a nil Breaches list turns
+// that rule off
+type Policy struct {
+	MinLength        int
+	MaxLength        int
+	RequireUppercase bool
+	RequireLowercase bool
+	RequireDigit     bool
+	RequireSymbol    bool
+	MinEntropy       float64
+	Breaches         *BreachList
+}
+
+// Check returns every rule the password fails, personal is the user's username and names which the password may not
+// be and which make a password weaker when it contains them
+func (p *Policy) Check(password string, personal ...string) []Violation {
+	violations := []Violation{}
+	fail := func(rule string, format string, args ...interface{}) {
+		violations = append(violations, Violation{Rule: rule, Message: fmt.Sprintf(format, args...)})
+	}
+
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		fail(RuleMinLength, "must be at least %d characters", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		// Nothing else is checked, the entropy estimate and breach lookup get slow on very long input
+		fail(RuleMaxLength, "must be at most %d characters", p.MaxLength)
+		return violations
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if p.RequireUppercase && !upper {
+		fail(RuleUppercase, "must contain an uppercase letter")
+	}
+	if p.RequireLowercase && !lower {
+		fail(RuleLowercase, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		fail(RuleDigit, "must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		fail(RuleSymbol, "must contain a symbol")
+	}
+
+	for _, value := range personal {
+		if value != "" && strings.EqualFold(password, value) {
+			fail(RulePersonalInfo, "must not be the same as the username or name")
+			break
+		}
+	}
+
+	if p.MinEntropy > 0 {
+		if entropy := Entropy(password, personal...); entropy < p.MinEntropy {
+			fail(RuleEntropy, "is too easy to guess, add more words or characters that do not follow a pattern")
+		}
+	}
+
+	if p.Breaches != nil && p.Breaches.Contains(password) {
+		fail(RuleBreached, "has appeared in a data breach and must not be used")
+	}
+
+	return violations
+}
@@ -0,0 +1,115 @@
+package password
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func rules(violations []Violation) map[string]bool {
+	found := map[string]bool{}
+	for _, violation := range violations {
+		found[violation.Rule] = true
+	}
+
+	return found
+}
+
+func TestPolicyCheck_ReportsEveryRule(t *testing.T) {
+	policy := &Policy{
+		MinLength:        12,
+		MaxLength:        64,
+		RequireUppercase: true,
+		RequireLowercase: true,
+		RequireDigit:     true,
+		RequireSymbol:    true,
+		MinEntropy:       30,
+	}
+
+	found := rules(policy.Check("gamer", "gamer", "Jane", "Doe"))
+	for _, rule := range []string{RuleMinLength, RuleUppercase, RuleDigit, RuleSymbol, RulePersonalInfo, RuleEntropy} {
+		if !found[rule] {
+			t.Errorf("Check() error:\n   expected: %v\n   got:      %v", rule, found)
+		}
+	}
+	if found[RuleLowercase] || found[RuleMaxLength] {
+		t.Errorf("Check() error:\n   expected: no lowercase or max_length violation\n   got:      %v", found)
+	}
+}
+
+func TestPolicyCheck_Passes(t *testing.T) {
+	policy := &Policy{MinLength: 8, MaxLength: 64, RequireDigit: true, MinEntropy: 30}
+
+	violations := policy.Check("bluehouse7 on a hill", "gamer", "Jane", "Doe")
+	if len(violations) != 0 {
+		t.Errorf("Check() error:\n   expected: []\n   got:      %v", violations)
+	}
+}
+
+func TestPolicyCheck_MaxLength(t *testing.T) {
+	policy := &Policy{MinLength: 1, MaxLength: 10}
+
+	found := rules(policy.Check("a-password-that-is-long"))
+	if !found[RuleMaxLength] {
+		t.Errorf("Check() error:\n   expected: %v\n   got:      %v", RuleMaxLength, found)
+	}
+}
+
+func TestPolicyCheck_MaxLengthSkipsEntropy(t *testing.T) {
+	policy := &Policy{MinLength: 1, MaxLength: 64, MinEntropy: 30}
+
+	start := time.Now()
+	violations := policy.Check(strings.Repeat("correct horse battery staple ", 100))
+	elapsed := time.Since(start)
+
+	if len(violations) != 1 || violations[0].Rule != RuleMaxLength {
+		t.Errorf("Check() error:\n   expected: only %v\n   got:      %v", RuleMaxLength, violations)
+	}
+	if elapsed > 100*time.Millisecond {
+		t.Errorf("Check() error:\n   expected: under 100ms\n   got:      %v", elapsed)
+	}
+}
+
+func TestEntropy_LongPassword(t *testing.T) {
+	start := time.Now()
+	bits := Entropy(strings.Repeat("x7#Lq", 400))
+	elapsed := time.Since(start)
+
+	if bits <= 0 || elapsed > time.Second {
+		t.Errorf("Entropy() error:\n   expected: a positive estimate in under a second\n   got:      %v in %v", bits, elapsed)
+	}
+}
+
+func TestPolicyCheck_PersonalInfoIgnoresCase(t *testing.T) {
+	policy := &Policy{}
+
+	found := rules(policy.Check("GamerTag", "gamertag"))
+	if !found[RulePersonalInfo] {
+		t.Errorf("Check() error:\n   expected: %v\n   got:      %v", RulePersonalInfo, found)
+	}
+}
+
+func TestEntropy_Patterns(t *testing.T) {
+	weak := []string{"password", "P@ssw0rd1!", "summer2024", "aaaaaaaaaaaa", "qwertyuiop", "abcdefgh", "987654321"}
+	for _, value := range weak {
+		if entropy := Entropy(value); entropy >= 20 {
+			t.Errorf("Entropy(%s) error:\n   expected: < 20\n   got:      %.1f", value, entropy)
+		}
+	}
+
+	strong := []string{"x7#kQ9!mZ", "bluehouse7 on a hill", "Tr0ub4dor&3"}
+	for _, value := range strong {
+		if entropy := Entropy(value); entropy < 30 {
+			t.Errorf("Entropy(%s) error:\n   expected: >= 30\n   got:      %.1f", value, entropy)
+		}
+	}
+}
+
+func TestEntropy_PersonalInfo(t *testing.T) {
+	without := Entropy("janedoe!")
+	with := Entropy("janedoe!", "janedoe", "Jane", "Doe")
+
+	if with >= without || with > 10 {
+		t.Errorf("Entropy() error:\n   expected: personal details to lower the estimate\n   got:      %.1f and %.1f", without, with)
+	}
+}
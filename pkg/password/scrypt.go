@@ -0,0 +1,100 @@
+package password
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// ScryptHasher hashes passwords with scrypt, N is the CPU and memory cost and must be a power of two
+type ScryptHasher struct {
+	N int
+	R int
+	P int
+}
+
+// NewScryptHasher returns a scrypt hasher
+func NewScryptHasher(n int, r int, p int) (*ScryptHasher, error) {
+	if n <= 1 || n&(n-1) != 0 {
+		return nil, errors.New("scrypt N must be a power of two greater than 1")
+	}
+	if r < 1 || p < 1 {
+		return nil, errors.New("scrypt r and p must be at least 1")
+	}
+
+	return &ScryptHasher{N: n, R: r, P: p}, nil
+}
+
+// Hash returns the scrypt PHC string of the password, N is written as its base two logarithm ln
+func (h *ScryptHasher) Hash(password string) (string, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return "", err
+	}
+
+	hash, err := scrypt.Key([]byte(password), salt, h.N, h.R, h.P, keyLength)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("$%s$ln=%d,r=%d,p=%d$%s$%s", Scrypt, bits.TrailingZeros(uint(h.N)), h.R, h.P,
+		encode(salt), encode(hash)), nil
+}
+
+// NeedsRehash is true for hashes of other algorithms and scrypt hashes with different parameters
+func (h *ScryptHasher) NeedsRehash(encoded string) bool {
+	params, salt, hash, err := decodeScrypt(encoded)
+	if err != nil {
+		return true
+	}
+
+	return *params != *h || len(salt) != saltLength || len(hash) != keyLength
+}
+
+func verifyScrypt(password string, encoded string) error {
+	params, salt, expected, err := decodeScrypt(encoded)
+	if err != nil {
+		return err
+	}
+
+	hash, err := scrypt.Key([]byte(password), salt, params.N, params.R, params.P, len(expected))
+	if err != nil {
+		return ErrInvalidHash
+	}
+	if subtle.ConstantTimeCompare(hash, expected) != 1 {
+		return ErrMismatchedPassword
+	}
+
+	return nil
+}
+
+// decodeScrypt reads $scrypt$ln=<log2 N>,r=<r>,p=<p>$<salt>$<hash>
+func decodeScrypt(encoded string) (*ScryptHasher, []byte, []byte, error) {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 || parts[1] != Scrypt {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	var ln uint
+	var params ScryptHasher
+	_, err := fmt.Sscanf(parts[2], "ln=%d,r=%d,p=%d", &ln, &params.R, &params.P)
+	if err != nil || ln < 1 || ln > 62 || params.R < 1 || params.P < 1 {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	params.N = 1 << ln
+
+	salt, err := decode(parts[3])
+	if err != nil {
+		return nil, nil, nil, ErrInvalidHash
+	}
+	hash, err := decode(parts[4])
+	if err != nil || len(hash) == 0 {
+		return nil, nil, nil, ErrInvalidHash
+	}
+
+	return &params, salt, hash, nil
+}
@@ -0,0 +1,165 @@
+// Package ratelimit throttles requests with token buckets kept in a pluggable store.
+//
+// Every key, such as a client IP on a route, has a bucket holding up to Burst tokens which refills at Rate tokens a
+// second. A request takes one token and is refused when the bucket is empty.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geeksheik9/login-service/pkg/api"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Limit is a token bucket, Burst requests are allowed at once and Rate more every second after that
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// RouteLimit holds the limits of one route, either may be nil
+type RouteLimit struct {
+	IP       *Limit
+	Username *Limit
+}
+
+// Store keeps the buckets
+type Store interface {
+	// Take removes a token from the key's bucket, when it is empty it returns how long until the next token
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error)
+}
+
+// Limiter is the middleware that applies the route limits
+type Limiter struct {
+	Store          Store
+	Limits         map[string]RouteLimit
+	TrustedProxies []*net.IPNet
+}
+
+// Limit wraps a handler with the limits configured for the route. username finds the username a request is for and
+// may be nil when the route is only limited by IP
+func (l *Limiter) Limit(route string, username func(r *http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+	limits, ok := l.Limits[route]
+	if !ok {
+		return next
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		now := time.Now()
+
+		if limits.IP != nil {
+			key := "ip:" + route + ":" + ClientIP(r, l.TrustedProxies)
+			if !l.take(w, r, key, *limits.IP, now) {
+				return
+			}
+		}
+
+		if limits.Username != nil && username != nil {
+			if name := strings.ToLower(strings.TrimSpace(username(r))); name != "" {
+				if !l.take(w, r, "username:"+route+":"+name, *limits.Username, now) {
+					return
+				}
+			}
+		}
+
+		next(w, r)
+	}
+}
+
+// take answers 429 when the bucket is empty, a store that is down lets requests through rather than locking
+// everyone out
+func (l *Limiter) take(w http.ResponseWriter, r *http.Request, key string, limit Limit, now time.Time) bool {
+	allowed, retryAfter, err := l.Store.Take(r.Context(), key, limit, now)
+	if err != nil {
+		log.Errorf("Error checking rate limit %s: %v", key, err)
+		return true
+	}
+	if allowed {
+		return true
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	api.RespondWithError(w, http.StatusTooManyRequests, "Too Many Requests")
+	return false
+}
+
+// RetryAfter is how long an empty bucket holding tokens takes to get back to one token
+func RetryAfter(tokens float64, limit Limit) time.Duration {
+	if tokens >= 1 {
+		return 0
+	}
+
+	return time.Duration((1 - tokens) / limit.Rate * float64(time.Second))
+}
+
+// ParseLimits reads comma separated route:key=count/period entries, key is ip or username and period is s, m, h or
+// a duration such as 15m, for example login:ip=20/m,login:username=10/m
+func ParseLimits(value string) (map[string]RouteLimit, error) {
+	limits := map[string]RouteLimit{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		route, rest, ok := strings.Cut(entry, ":")
+		key, rate, ok2 := strings.Cut(rest, "=")
+		count, period, ok3 := strings.Cut(rate, "/")
+		route, key, count, period = strings.TrimSpace(route), strings.TrimSpace(key), strings.TrimSpace(count), strings.TrimSpace(period)
+		if !ok || !ok2 || !ok3 || route == "" {
+			return nil, fmt.Errorf("invalid rate limit %q, expected route:key=count/period", entry)
+		}
+
+		burst, err := strconv.Atoi(count)
+		if err != nil || burst < 1 {
+			return nil, fmt.Errorf("invalid rate limit %q, count must be a positive number", entry)
+		}
+		duration, err := parsePeriod(period)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rate limit %q, %v", entry, err)
+		}
+		limit := &Limit{Rate: float64(burst) / duration.Seconds(), Burst: burst}
+
+		routeLimit := limits[route]
+		switch key {
+		case "ip":
+			routeLimit.IP = limit
+		case "username":
+			routeLimit.Username = limit
+		default:
+			return nil, fmt.Errorf("invalid rate limit %q, key must be ip or username", entry)
+		}
+		limits[route] = routeLimit
+	}
+
+	return limits, nil
+}
+
+func parsePeriod(value string) (time.Duration, error) {
+	switch value {
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("period %q must be s, m, h or a positive duration", value)
+	}
+
+	return duration, nil
+}
@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_Take(t *testing.T) {
+	store := NewMemoryStore()
+	limit := Limit{Rate: 1, Burst: 2}
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		allowed, _, _ := store.Take(context.Background(), "key", limit, now)
+		if !allowed {
+			t.Fatalf("Take() error:\n   expected: true\n   got:      false")
+		}
+	}
+
+	allowed, retryAfter, _ := store.Take(context.Background(), "key", limit, now)
+	if allowed || retryAfter != time.Second {
+		t.Errorf("Take() error:\n   expected: false, 1s\n   got:      %v, %v", allowed, retryAfter)
+	}
+
+	allowed, _, _ = store.Take(context.Background(), "other", limit, now)
+	if !allowed {
+		t.Errorf("Take() error for another key:\n   expected: true\n   got:      false")
+	}
+
+	allowed, _, _ = store.Take(context.Background(), "key", limit, now.Add(time.Second))
+	if !allowed {
+		t.Errorf("Take() error after refill:\n   expected: true\n   got:      false")
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("login:ip=20/m, login:username=10/15m,register:ip=5/h")
+	if err != nil {
+		t.Fatalf("ParseLimits() returned error: %v", err)
+	}
+
+	login := limits["login"]
+	if login.IP == nil || login.IP.Burst != 20 || login.IP.Rate != 20.0/60 {
+		t.Errorf("ParseLimits() login ip error: got %v", login.IP)
+	}
+	if login.Username == nil || login.Username.Burst != 10 || login.Username.Rate != 10.0/900 {
+		t.Errorf("ParseLimits() login username error: got %v", login.Username)
+	}
+	if limits["register"].IP == nil || limits["register"].Username != nil {
+		t.Errorf("ParseLimits() register error: got %v", limits["register"])
+	}
+}
+
+func TestParseLimits_Invalid(t *testing.T) {
+	for _, value := range []string{"login", "login:ip=20", "login:ip=0/m", "login:cookie=1/m", "login:ip=1/fortnight", ":ip=1/m"} {
+		_, err := ParseLimits(value)
+		if err == nil {
+			t.Errorf("ParseLimits(%q) error:\n   expected: <error>\n   got:      %v", value, err)
+		}
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseNetworks("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatalf("ParseNetworks() returned error: %v", err)
+	}
+
+	tests := []struct {
+		remote    string
+		forwarded string
+		expected  string
+	}{
+		{"203.0.113.7:1234", "", "203.0.113.7"},
+		{"203.0.113.7:1234", "198.51.100.1", "203.0.113.7"},
+		{"10.0.0.5:1234", "198.51.100.1", "198.51.100.1"},
+		{"10.0.0.5:1234", "6.6.6.6, 198.51.100.1, 192.168.1.1", "198.51.100.1"},
+		{"10.0.0.5:1234", "10.0.0.9", "10.0.0.9"},
+		{"10.0.0.5:1234", "", "10.0.0.5"},
+		{"10.0.0.5:1234", "not-an-ip", "10.0.0.5"},
+	}
+
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/login", nil)
+		r.RemoteAddr = test.remote
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+
+		if got := ClientIP(r, trusted); got != test.expected {
+			t.Errorf("ClientIP(%s, %s) error:\n   expected: %v\n   got:      %v", test.remote, test.forwarded, test.expected, got)
+		}
+	}
+}
+
+func TestLimiter_Limit(t *testing.T) {
+	limiter := &Limiter{
+		Store: NewMemoryStore(),
+		Limits: map[string]RouteLimit{
+			"login": {IP: &Limit{Rate: 1, Burst: 3}, Username: &Limit{Rate: 1, Burst: 1}},
+		},
+	}
+	username := func(r *http.Request) string { return r.URL.Query().Get("username") }
+	handler := limiter.Limit("login", username, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	codes := []int{}
+	for _, name := range []string{"a", "A", "b", "c"} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/login?username="+name, nil)
+		handler(w, r)
+		codes = append(codes, w.Code)
+
+		if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "1" {
+			t.Errorf("Limit() Retry-After error:\n   expected: 1\n   got:      %v", w.Header().Get("Retry-After"))
+		}
+	}
+
+	// The second request is refused by the username limit and the fourth by the IP limit
+	expected := []int{http.StatusOK, http.StatusTooManyRequests, http.StatusOK, http.StatusTooManyRequests}
+	for i := range expected {
+		if codes[i] != expected[i] {
+			t.Errorf("Limit() error:\n   expected: %v\n   got:      %v", expected, codes)
+			break
+		}
+	}
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	var limiter *Limiter
+	called := false
+	limiter.Limit("login", nil, func(w http.ResponseWriter, r *http.Request) { called = true })(
+		httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/login", nil))
+
+	if !called {
+		t.Errorf("Limit() error:\n   expected: handler called\n   got:      not called")
+	}
+}
@@ -0,0 +1,179 @@
+package token
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/geeksheik9/login-service/models"
+)
+
+// Validation errors, each rejection reason is distinct so callers can tell them apart
+var (
+	ErrInvalidToken     = errors.New("token is invalid")
+	ErrTokenExpired     = errors.New("token is expired")
+	ErrTokenNotYetValid = errors.New("token is not valid yet")
+	ErrInvalidIssuer    = errors.New("token issuer is invalid")
+	ErrInvalidAudience  = errors.New("token audience is invalid")
+)
+
+// Claims are the claims carried by access tokens, the subject is the user's immutable ID and stays the same when the
+// username changes
+type Claims struct {
+	jwt.StandardClaims
+	Username  string        `json:"username"`
+	FirstName string        `json:"firstname"`
+	LastName  string        `json:"lastname"`
+	Roles     []models.Role `json:"roles"`
+	Scope     string        `json:"scope,omitempty"`
+	ClientID  string        `json:"client_id,omitempty"`
+
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified"`
+}
+
+// HasRole checks whether the claims carry the role name
+func (c *Claims) HasRole(name string) bool {
+	for _, role := range c.Roles {
+		if role.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Issuer issues and validates access tokens
+type Issuer struct {
+	Keys        KeyProvider
+	Issuer      string
+	Audience    string
+	Lifetime    time.Duration
+	Revocations *RevocationList
+}
+
+// Issue signs an access token for the user limited to the scope, an empty scope is a first party login
+func (i *Issuer) Issue(user *models.User, scope string) (string, *Claims, error) {
+	return i.IssueForClient(user, "", scope)
+}
+
+// IssueForClient signs an access token for the user that a client got through the token endpoint, the client ID is
+// carried in the client_id claim so the token is not mistaken for a first party login
+func (i *Issuer) IssueForClient(user *models.User, clientID string, scope string) (string, *Claims, error) {
+	jti, err := NewID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := jwt.TimeFunc()
+	claims := &Claims{
+		StandardClaims: jwt.StandardClaims{
+			Audience:  i.Audience,
+			ExpiresAt: now.Add(i.Lifetime).Unix(),
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			Issuer:    i.Issuer,
+			NotBefore: now.Unix(),
+			Subject:   user.ID.Hex(),
+		},
+		Username:  user.Username,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Roles:     user.Roles,
+		Scope:     scope,
+		ClientID:  clientID,
+
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+	}
+
+	tokenString, err := Sign(i.Keys, claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tokenString, claims, nil
+}
+
+// IssueClientToken signs a service token for a client acting on its own behalf, its subject is the client ID
+func (i *Issuer) IssueClientToken(clientID string, scope string) (string, *Claims, error) {
+	jti, err := NewID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := jwt.TimeFunc()
+	claims := &Claims{
+		StandardClaims: jwt.StandardClaims{
+			Audience:  i.Audience,
+			ExpiresAt: now.Add(i.Lifetime).Unix(),
+			Id:        jti,
+			IssuedAt:  now.Unix(),
+			Issuer:    i.Issuer,
+			NotBefore: now.Unix(),
+			Subject:   clientID,
+		},
+		Scope:    scope,
+		ClientID: clientID,
+	}
+
+	tokenString, err := Sign(i.Keys, claims)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return tokenString, claims, nil
+}
+
+// Validate verifies the token's signature, lifetime, issuer and audience and that it has not been revoked
+func (i *Issuer) Validate(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	parsed, err := Parse(i.Keys, tokenString, claims)
+	if err != nil {
+		var validationErr *jwt.ValidationError
+		if errors.As(err, &validationErr) {
+			switch {
+			case validationErr.Errors&jwt.ValidationErrorExpired != 0:
+				return nil, ErrTokenExpired
+			case validationErr.Errors&(jwt.ValidationErrorNotValidYet|jwt.ValidationErrorIssuedAt) != 0:
+				return nil, ErrTokenNotYetValid
+			}
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if !parsed.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.ExpiresAt == 0 {
+		return nil, fmt.Errorf("%w: token has no expiry", ErrInvalidToken)
+	}
+	if !claims.VerifyIssuer(i.Issuer, true) {
+		return nil, ErrInvalidIssuer
+	}
+	if !claims.VerifyAudience(i.Audience, true) {
+		return nil, ErrInvalidAudience
+	}
+
+	if i.Revocations != nil {
+		err = i.Revocations.Check(claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return claims, nil
+}
+
+// NewID returns a random identifier suitable for a jti
+func NewID() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return encode(b), nil
+}
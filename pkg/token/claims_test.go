@@ -0,0 +1,121 @@
+package token
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/geeksheik9/login-service/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func newTestIssuer(t *testing.T) *Issuer {
+	keys, err := NewHMACKeyProvider([]byte("a-much-better-secret"))
+	if err != nil {
+		t.Fatalf("NewHMACKeyProvider() returned error: %v", err)
+	}
+
+	return &Issuer{
+		Keys:     keys,
+		Issuer:   "login-service",
+		Audience: "games",
+		Lifetime: time.Hour,
+	}
+}
+
+func TestIssuer_IssueAndValidate(t *testing.T) {
+	issuer := newTestIssuer(t)
+	user := &models.User{ID: primitive.NewObjectID(), Username: "user", FirstName: "first", LastName: "last",
+		Roles: []models.Role{{Name: "admin"}}, Email: "user@example.com", EmailVerified: true}
+
+	tokenString, issued, err := issuer.Issue(user, "")
+	if err != nil {
+		t.Fatalf("Issue() returned error: %v", err)
+	}
+	if issued.Id == "" || issued.Subject != user.ID.Hex() || issued.ExpiresAt <= issued.IssuedAt {
+		t.Errorf("Issue() registered claims error: got %+v", issued.StandardClaims)
+	}
+
+	claims, err := issuer.Validate(tokenString)
+	if err != nil {
+		t.Fatalf("Validate() returned error: %v", err)
+	}
+	if claims.Username != "user" || !claims.HasRole("admin") || claims.Email != "user@example.com" || !claims.EmailVerified {
+		t.Errorf("Validate() claims error: got %+v", claims)
+	}
+}
+
+func TestIssuer_ValidateErrors(t *testing.T) {
+	issuer := newTestIssuer(t)
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		claims   jwt.StandardClaims
+		expected error
+	}{
+		{
+			name:     "expired",
+			claims:   jwt.StandardClaims{Issuer: "login-service", Audience: "games", ExpiresAt: now.Add(-time.Minute).Unix()},
+			expected: ErrTokenExpired,
+		},
+		{
+			name:     "not yet valid",
+			claims:   jwt.StandardClaims{Issuer: "login-service", Audience: "games", ExpiresAt: now.Add(time.Hour).Unix(), NotBefore: now.Add(time.Minute).Unix()},
+			expected: ErrTokenNotYetValid,
+		},
+		{
+			name:     "wrong issuer",
+			claims:   jwt.StandardClaims{Issuer: "someone-else", Audience: "games", ExpiresAt: now.Add(time.Hour).Unix()},
+			expected: ErrInvalidIssuer,
+		},
+		{
+			name:     "wrong audience",
+			claims:   jwt.StandardClaims{Issuer: "login-service", Audience: "other", ExpiresAt: now.Add(time.Hour).Unix()},
+			expected: ErrInvalidAudience,
+		},
+		{
+			name:     "no expiry",
+			claims:   jwt.StandardClaims{Issuer: "login-service", Audience: "games"},
+			expected: ErrInvalidToken,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokenString, _ := Sign(issuer.Keys, &Claims{StandardClaims: test.claims})
+
+			_, err := issuer.Validate(tokenString)
+			if !errors.Is(err, test.expected) {
+				t.Errorf("Validate() error:\n   expected: %v\n   got:      %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestIssuer_ValidateTampered(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	_, err := issuer.Validate("not.a.token")
+	if !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Validate() error:\n   expected: %v\n   got:      %v", ErrInvalidToken, err)
+	}
+}
+
+func TestIssuer_IssueClientToken(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	tokenString, _, err := issuer.IssueClientToken("nightly-job", "games:read")
+	if err != nil {
+		t.Fatalf("IssueClientToken() returned error: %v", err)
+	}
+
+	claims, err := issuer.Validate(tokenString)
+	if err != nil {
+		t.Fatalf("Validate() returned error: %v", err)
+	}
+	if claims.Subject != "nightly-job" || claims.ClientID != "nightly-job" || claims.Scope != "games:read" || claims.Username != "" {
+		t.Errorf("IssueClientToken() claims error: got %+v", claims)
+	}
+}
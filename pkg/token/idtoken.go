@@ -0,0 +1,61 @@
+package token
+
+import (
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/geeksheik9/login-service/models"
+)
+
+// IDTokenClaims are the claims of an OpenID Connect ID token
+type IDTokenClaims struct {
+	jwt.StandardClaims
+	Nonce             string        `json:"nonce,omitempty"`
+	AuthTime          int64         `json:"auth_time,omitempty"`
+	Name              string        `json:"name,omitempty"`
+	GivenName         string        `json:"given_name,omitempty"`
+	FamilyName        string        `json:"family_name,omitempty"`
+	PreferredUsername string        `json:"preferred_username,omitempty"`
+	Roles             []models.Role `json:"roles,omitempty"`
+	Email             string        `json:"email,omitempty"`
+	EmailVerified     *bool         `json:"email_verified,omitempty"`
+}
+
+// IssueIDToken signs an ID token for the client, the audience of an ID token is always the client it was issued to
+func (i *Issuer) IssueIDToken(user *models.User, clientID string, nonce string, authTime time.Time) (string, error) {
+	now := jwt.TimeFunc()
+	claims := &IDTokenClaims{
+		StandardClaims: jwt.StandardClaims{
+			Audience:  clientID,
+			ExpiresAt: now.Add(i.Lifetime).Unix(),
+			IssuedAt:  now.Unix(),
+			Issuer:    i.Issuer,
+			Subject:   user.ID.Hex(),
+		},
+		Nonce:             nonce,
+		AuthTime:          authTime.Unix(),
+		Name:              FullName(user),
+		GivenName:         user.FirstName,
+		FamilyName:        user.LastName,
+		PreferredUsername: user.Username,
+		Roles:             user.Roles,
+	}
+	if user.Email != "" {
+		claims.Email = user.Email
+		claims.EmailVerified = &user.EmailVerified
+	}
+
+	return Sign(i.Keys, claims)
+}
+
+// FullName joins the user's first and last name for the name claim
+func FullName(user *models.User) string {
+	switch {
+	case user.FirstName == "":
+		return user.LastName
+	case user.LastName == "":
+		return user.FirstName
+	default:
+		return user.FirstName + " " + user.LastName
+	}
+}
@@ -0,0 +1,48 @@
+package token
+
+import (
+	"testing"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestIssuer_IssueIDToken(t *testing.T) {
+	issuer := newTestIssuer(t)
+	user := &models.User{ID: primitive.NewObjectID(), Username: "user", FirstName: "First", LastName: "Last", Roles: []models.Role{{Name: "player"}}}
+
+	tokenString, err := issuer.IssueIDToken(user, "web", "n-0S6_WzA2Mj", time.Now())
+	if err != nil {
+		t.Fatalf("IssueIDToken() returned error: %v", err)
+	}
+
+	claims := &IDTokenClaims{}
+	_, err = Parse(issuer.Keys, tokenString, claims)
+	if err != nil {
+		t.Fatalf("Parse() returned error: %v", err)
+	}
+
+	if claims.Audience != "web" || claims.Nonce != "n-0S6_WzA2Mj" || claims.Subject != user.ID.Hex() {
+		t.Errorf("IssueIDToken() registered claims error: got %+v", claims)
+	}
+	if claims.Name != "First Last" || claims.GivenName != "First" || claims.FamilyName != "Last" || len(claims.Roles) != 1 {
+		t.Errorf("IssueIDToken() profile claims error: got %+v", claims)
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	// Example from RFC 7636 appendix B
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if !VerifyPKCE(challenge, verifier) {
+		t.Errorf("VerifyPKCE() error:\n   expected: true\n   got:      false")
+	}
+	if VerifyPKCE(challenge, verifier[1:]+"A") {
+		t.Errorf("VerifyPKCE() error:\n   expected: false for the wrong verifier\n   got:      true")
+	}
+	if VerifyPKCE(challenge, "short") {
+		t.Errorf("VerifyPKCE() error:\n   expected: false for a short verifier\n   got:      true")
+	}
+}
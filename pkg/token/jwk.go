@@ -0,0 +1,112 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+)
+
+// ErrSymmetricKey is returned when a key without public material is turned into a JWK
+var ErrSymmetricKey = errors.New("symmetric keys can not be published")
+
+// JSONWebKey is the public half of a signing key as described in RFC 7517
+type JSONWebKey struct {
+	KeyType string `json:"kty"`
+	Use     string `json:"use"`
+	KeyID   string `json:"kid"`
+	Alg     string `json:"alg"`
+	N       string `json:"n,omitempty"`
+	E       string `json:"e,omitempty"`
+	Curve   string `json:"crv,omitempty"`
+	X       string `json:"x,omitempty"`
+	Y       string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is the document served from the JWKS endpoint
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// NewJSONWebKeySet builds a JWKS from the public keys, symmetric keys are left out
+func NewJSONWebKeySet(keys []*Key) JSONWebKeySet {
+	set := JSONWebKeySet{Keys: []JSONWebKey{}}
+	for _, key := range keys {
+		jwk, err := NewJSONWebKey(key)
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+// NewJSONWebKey converts the public part of a key into a JWK
+func NewJSONWebKey(key *Key) (JSONWebKey, error) {
+	jwk := JSONWebKey{
+		Use:   "sig",
+		KeyID: key.ID,
+		Alg:   key.Method.Alg(),
+	}
+
+	switch k := key.Public.(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = encode(k.N.Bytes())
+		jwk.E = encode(big.NewInt(int64(k.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		jwk.KeyType = "EC"
+		jwk.Curve = k.Curve.Params().Name
+		jwk.X = encode(k.X.FillBytes(make([]byte, size)))
+		jwk.Y = encode(k.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = encode(k)
+	default:
+		return jwk, ErrSymmetricKey
+	}
+
+	return jwk, nil
+}
+
+// Thumbprint returns the RFC 7638 thumbprint of the key's public half
+func Thumbprint(key *Key) (string, error) {
+	jwk, err := NewJSONWebKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	// The members are required to be in lexicographic order, which the map marshalling guarantees
+	members := map[string]string{"kty": jwk.KeyType}
+	switch jwk.KeyType {
+	case "RSA":
+		members["n"] = jwk.N
+		members["e"] = jwk.E
+	case "EC":
+		members["crv"] = jwk.Curve
+		members["x"] = jwk.X
+		members["y"] = jwk.Y
+	case "OKP":
+		members["crv"] = jwk.Curve
+		members["x"] = jwk.X
+	}
+
+	canonical, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(canonical)
+	return encode(sum[:]), nil
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,61 @@
+package token
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+)
+
+// ErrNoKeyEncryption is returned when a key would have to be stored or loaded without a key encryption key
+var ErrNoKeyEncryption = errors.New("no signing key encryption key is configured")
+
+// newKeyCipher derives the AES-256-GCM cipher stored private keys are encrypted with from the configured secret
+func newKeyCipher(secret []byte) (cipher.AEAD, error) {
+	if len(secret) == 0 {
+		return nil, nil
+	}
+
+	sum := sha256.Sum256(append([]byte("login-service signing keys\x00"), secret...))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// sealPrivateKey encrypts a PEM encoded private key, the kid is authenticated with it so an encrypted key can not be
+// moved to another record
+func sealPrivateKey(aead cipher.AEAD, kid string, contents []byte) (string, error) {
+	if aead == nil {
+		return "", ErrNoKeyEncryption
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, contents, []byte(kid))), nil
+}
+
+// openPrivateKey decrypts a private key sealed by sealPrivateKey
+func openPrivateKey(aead cipher.AEAD, kid string, sealed string) ([]byte, error) {
+	if aead == nil {
+		return nil, ErrNoKeyEncryption
+	}
+
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, errors.New("encrypted signing key is too short")
+	}
+
+	return aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(kid))
+}
@@ -0,0 +1,87 @@
+package token
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ErrUnexpectedSigningMethod is returned when a token was signed with an algorithm the key does not allow
+var ErrUnexpectedSigningMethod = errors.New("unexpected signing method")
+
+// ErrUnknownKey is returned when a token names a kid the provider does not hold
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// Key is a single key used to sign and verify tokens
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private interface{}
+	Public  interface{}
+}
+
+// KeyProvider is the interface setup for anything that supplies token keys
+type KeyProvider interface {
+	SigningKey() (*Key, error)
+	VerificationKey(token *jwt.Token) (interface{}, error)
+	VerificationKeys() []*Key
+}
+
+// StaticKeyProvider is a key provider that signs and verifies with a single key
+type StaticKeyProvider struct {
+	key *Key
+}
+
+// NewStaticKeyProvider returns a key provider that signs and verifies with the key passed
+func NewStaticKeyProvider(key *Key) *StaticKeyProvider {
+	return &StaticKeyProvider{key: key}
+}
+
+// NewHMACKeyProvider returns a key provider that signs and verifies with an HS256 secret
+func NewHMACKeyProvider(secret []byte) (*StaticKeyProvider, error) {
+	key, err := NewHMACKey(secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStaticKeyProvider(key), nil
+}
+
+// NewHMACKey wraps an HS256 secret, its kid is derived from a digest so the secret itself is never exposed
+func NewHMACKey(secret []byte) (*Key, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("HMAC secret must not be empty")
+	}
+
+	sum := sha256.Sum256(secret)
+	return &Key{
+		ID:      "hs-" + encode(sum[:8]),
+		Method:  jwt.SigningMethodHS256,
+		Private: secret,
+		Public:  secret,
+	}, nil
+}
+
+// SigningKey returns the key used to sign new tokens
+func (p *StaticKeyProvider) SigningKey() (*Key, error) {
+	return p.key, nil
+}
+
+// VerificationKey returns the key used to verify the token, it satisfies jwt.Keyfunc
+func (p *StaticKeyProvider) VerificationKey(token *jwt.Token) (interface{}, error) {
+	if kid, ok := token.Header["kid"]; ok && kid != p.key.ID {
+		return nil, ErrUnknownKey
+	}
+
+	if token.Method.Alg() != p.key.Method.Alg() {
+		return nil, ErrUnexpectedSigningMethod
+	}
+
+	return p.key.Public, nil
+}
+
+// VerificationKeys returns every key a token may currently be verified with
+func (p *StaticKeyProvider) VerificationKeys() []*Key {
+	return []*Key{p.key}
+}
@@ -0,0 +1,354 @@
+package token
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/geeksheik9/login-service/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// Key statuses, only the active key signs while active and verify keys are both accepted when verifying
+const (
+	KeyStatusActive  = "active"
+	KeyStatusVerify  = "verify"
+	KeyStatusRetired = "retired"
+)
+
+// ErrRetiredKey is returned when a token was signed with a key that has been retired
+var ErrRetiredKey = errors.New("signing key has been retired")
+
+// KeyStore is the interface setup for persisting signing keys so every replica shares them
+type KeyStore interface {
+	GetSigningKeys() ([]models.SigningKey, error)
+	SaveSigningKey(key *models.SigningKey) error
+}
+
+type entry struct {
+	key    *Key
+	record models.SigningKey
+}
+
+// retired reports whether the key may no longer verify tokens at the given time
+func (e *entry) retired(now time.Time) bool {
+	if e.record.Status == KeyStatusRetired {
+		return true
+	}
+
+	return e.record.Status == KeyStatusVerify && !e.record.RetireAt.IsZero() && now.After(e.record.RetireAt)
+}
+
+// KeySet is a key provider holding one active signing key and any number of verify-only keys selected by kid
+type KeySet struct {
+	store       KeyStore
+	bootstrap   *Key
+	gracePeriod time.Duration
+	cipher      cipher.AEAD
+
+	mu     sync.RWMutex
+	keys   map[string]*entry
+	active string
+}
+
+// NewKeySet loads the stored keys, the configured bootstrap key signs until a stored key is promoted over it.
+// Rotated keys are stored encrypted with a key derived from encryptionKey, without one keys can not be rotated
+func NewKeySet(store KeyStore, bootstrap *Key, gracePeriod time.Duration, encryptionKey []byte) (*KeySet, error) {
+	aead, err := newKeyCipher(encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	set := &KeySet{
+		store:       store,
+		bootstrap:   bootstrap,
+		gracePeriod: gracePeriod,
+		cipher:      aead,
+	}
+
+	err = set.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return set, nil
+}
+
+// Reload reads the keys from the store again, so rotations made by other replicas are picked up
+func (k *KeySet) Reload() error {
+	records, err := k.store.GetSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]*entry{
+		k.bootstrap.ID: {
+			key: k.bootstrap,
+			record: models.SigningKey{
+				KeyID:     k.bootstrap.ID,
+				Algorithm: k.bootstrap.Method.Alg(),
+				Status:    KeyStatusActive,
+			},
+		},
+	}
+	active := k.bootstrap.ID
+	var activeCreated time.Time
+
+	for _, record := range records {
+		if record.KeyID == k.bootstrap.ID {
+			// Records for the bootstrap key only carry its status, the key itself stays in config
+			keys[record.KeyID].record = record
+			if record.Status != KeyStatusActive && active == k.bootstrap.ID {
+				active = ""
+			}
+			continue
+		}
+
+		contents := []byte(record.PrivateKey)
+		if record.Encrypted {
+			contents, err = openPrivateKey(k.cipher, record.KeyID, record.PrivateKey)
+			if err != nil {
+				log.Errorf("Unable to decrypt signing key %v: %v", record.KeyID, err)
+				continue
+			}
+		} else {
+			// Keys rotated before keys were encrypted still load, rotating again replaces them
+			log.Debugf("Signing key %v is stored unencrypted", record.KeyID)
+		}
+
+		key, err := ParsePrivateKeyPEM(contents)
+		if err != nil {
+			log.Errorf("Unable to load signing key %v: %v", record.KeyID, err)
+			continue
+		}
+		key.ID = record.KeyID
+		keys[record.KeyID] = &entry{key: key, record: record}
+
+		if record.Status == KeyStatusActive && (active == "" || active == k.bootstrap.ID || record.CreatedAt.After(activeCreated)) {
+			active = record.KeyID
+			activeCreated = record.CreatedAt
+		}
+	}
+
+	if active == "" {
+		return errors.New("no active signing key")
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.keys = keys
+	k.active = active
+
+	return nil
+}
+
+// Watch reloads the key set on the interval until the context is done
+func (k *KeySet) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := k.Reload()
+			if err != nil {
+				log.Errorf("Unable to reload signing keys: %v", err)
+			}
+		}
+	}
+}
+
+// SigningKey returns the active key
+func (k *KeySet) SigningKey() (*Key, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	return k.keys[k.active].key, nil
+}
+
+// VerificationKey returns the key named by the token's kid as long as it has not been retired
+func (k *KeySet) VerificationKey(token *jwt.Token) (interface{}, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		// Tokens issued before kids were added can only have come from the bootstrap key
+		kid = k.bootstrap.ID
+	}
+
+	e, ok := k.keys[kid]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	if e.retired(time.Now()) {
+		return nil, ErrRetiredKey
+	}
+	if token.Method.Alg() != e.key.Method.Alg() {
+		return nil, ErrUnexpectedSigningMethod
+	}
+
+	return e.key.Public, nil
+}
+
+// VerificationKeys returns every key that has not been retired
+func (k *KeySet) VerificationKeys() []*Key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := time.Now()
+	keys := []*Key{}
+	for _, e := range k.keys {
+		if !e.retired(now) {
+			keys = append(keys, e.key)
+		}
+	}
+
+	return keys
+}
+
+// Records returns the stored state of every key in the set
+func (k *KeySet) Records() []models.SigningKey {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	now := time.Now()
+	records := []models.SigningKey{}
+	for _, e := range k.keys {
+		record := e.record
+		record.PrivateKey = ""
+		if e.retired(now) {
+			record.Status = KeyStatusRetired
+		}
+		records = append(records, record)
+	}
+
+	return records
+}
+
+// Rotate generates a key of the same type as the active one and promotes it, the previous active key stays
+// verify-only for the grace period and keys past their grace period are retired
+func (k *KeySet) Rotate() (*models.SigningKey, error) {
+	current, err := k.SigningKey()
+	if err != nil {
+		return nil, err
+	}
+
+	key, contents, err := GenerateKey(current.Method)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed, err := sealPrivateKey(k.cipher, key.ID, contents)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	promoted := &models.SigningKey{
+		KeyID:      key.ID,
+		Algorithm:  key.Method.Alg(),
+		PrivateKey: sealed,
+		Encrypted:  true,
+		Status:     KeyStatusActive,
+		CreatedAt:  now,
+	}
+	err = k.store.SaveSigningKey(promoted)
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.RLock()
+	var demoted []models.SigningKey
+	for _, e := range k.keys {
+		record := e.record
+		switch {
+		case record.KeyID == k.active:
+			record.Status = KeyStatusVerify
+			record.RetireAt = now.Add(k.gracePeriod)
+		case record.Status == KeyStatusVerify && e.retired(now):
+			record.Status = KeyStatusRetired
+		default:
+			continue
+		}
+		demoted = append(demoted, record)
+	}
+	k.mu.RUnlock()
+
+	for i := range demoted {
+		err = k.store.SaveSigningKey(&demoted[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = k.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	promoted.PrivateKey = ""
+	return promoted, nil
+}
+
+// hmacPEMType is the PEM block used to store generated HMAC secrets
+const hmacPEMType = "HMAC SECRET"
+
+// GenerateKey creates a new key for the signing method and returns it with its PEM encoding
+func GenerateKey(method jwt.SigningMethod) (*Key, []byte, error) {
+	var privateKey interface{}
+	var err error
+
+	switch method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		secret := make([]byte, 64)
+		_, err = rand.Read(secret)
+		if err != nil {
+			return nil, nil, err
+		}
+		key, err := NewHMACKey(secret)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key, pem.EncodeToMemory(&pem.Block{Type: hmacPEMType, Bytes: secret}), nil
+	case jwt.SigningMethodRS256.Alg():
+		privateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodES256.Alg():
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case jwt.SigningMethodES384.Alg():
+		privateKey, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case jwt.SigningMethodES512.Alg():
+		privateKey, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case SigningMethodEd25519.Alg():
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, nil, errors.New("unable to generate keys for " + method.Alg())
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := NewKey(privateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
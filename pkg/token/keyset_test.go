@@ -0,0 +1,189 @@
+package token
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/geeksheik9/login-service/models"
+)
+
+type memoryKeyStore struct {
+	keys map[string]models.SigningKey
+}
+
+func (m *memoryKeyStore) GetSigningKeys() ([]models.SigningKey, error) {
+	keys := []models.SigningKey{}
+	for _, key := range m.keys {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (m *memoryKeyStore) SaveSigningKey(key *models.SigningKey) error {
+	m.keys[key.KeyID] = *key
+	return nil
+}
+
+func newTestKeySet(t *testing.T, gracePeriod time.Duration) (*KeySet, *memoryKeyStore) {
+	bootstrap, _, err := GenerateKey(SigningMethodEd25519)
+	if err != nil {
+		t.Fatalf("GenerateKey() returned error: %v", err)
+	}
+
+	store := &memoryKeyStore{keys: map[string]models.SigningKey{}}
+	set, err := NewKeySet(store, bootstrap, gracePeriod, []byte("key-encryption-secret"))
+	if err != nil {
+		t.Fatalf("NewKeySet() returned error: %v", err)
+	}
+
+	return set, store
+}
+
+func TestKeySet_RotateKeepsOldTokensValid(t *testing.T) {
+	set, _ := newTestKeySet(t, time.Hour)
+
+	oldToken, _ := Sign(set, jwt.MapClaims{"username": "user"})
+	oldKey, _ := set.SigningKey()
+
+	promoted, err := set.Rotate()
+	if err != nil {
+		t.Fatalf("Rotate() returned error: %v", err)
+	}
+
+	newKey, _ := set.SigningKey()
+	if newKey.ID != promoted.KeyID || newKey.ID == oldKey.ID {
+		t.Errorf("Rotate() error:\n   expected active: %v\n   got:             %v", promoted.KeyID, newKey.ID)
+	}
+	if newKey.Method.Alg() != oldKey.Method.Alg() {
+		t.Errorf("Rotate() alg error:\n   expected: %v\n   got:      %v", oldKey.Method.Alg(), newKey.Method.Alg())
+	}
+
+	_, err = Parse(set, oldToken, jwt.MapClaims{})
+	if err != nil {
+		t.Errorf("Parse() old token error:\n   expected: <nil>\n   got:      %v", err)
+	}
+
+	newToken, _ := Sign(set, jwt.MapClaims{"username": "user"})
+	_, err = Parse(set, newToken, jwt.MapClaims{})
+	if err != nil {
+		t.Errorf("Parse() new token error:\n   expected: <nil>\n   got:      %v", err)
+	}
+
+	if len(set.VerificationKeys()) != 2 {
+		t.Errorf("VerificationKeys() error:\n   expected: 2\n   got:      %v", len(set.VerificationKeys()))
+	}
+}
+
+func TestKeySet_RetiresAfterGracePeriod(t *testing.T) {
+	set, _ := newTestKeySet(t, -time.Second)
+
+	oldToken, _ := Sign(set, jwt.MapClaims{"username": "user"})
+
+	_, err := set.Rotate()
+	if err != nil {
+		t.Fatalf("Rotate() returned error: %v", err)
+	}
+
+	_, err = Parse(set, oldToken, jwt.MapClaims{})
+	if err == nil {
+		t.Errorf("Parse() error:\n   expected: %v\n   got:      %v", ErrRetiredKey, err)
+	}
+
+	if len(set.VerificationKeys()) != 1 {
+		t.Errorf("VerificationKeys() error:\n   expected: 1\n   got:      %v", len(set.VerificationKeys()))
+	}
+}
+
+func TestKeySet_ReloadSharesRotation(t *testing.T) {
+	set, store := newTestKeySet(t, time.Hour)
+
+	replica, err := NewKeySet(store, set.bootstrap, time.Hour, []byte("key-encryption-secret"))
+	if err != nil {
+		t.Fatalf("NewKeySet() returned error: %v", err)
+	}
+
+	promoted, _ := set.Rotate()
+	err = replica.Reload()
+	if err != nil {
+		t.Fatalf("Reload() returned error: %v", err)
+	}
+
+	key, _ := replica.SigningKey()
+	if key.ID != promoted.KeyID {
+		t.Errorf("Reload() error:\n   expected active: %v\n   got:             %v", promoted.KeyID, key.ID)
+	}
+}
+
+func TestKeySet_RotateEncryptsStoredKeys(t *testing.T) {
+	set, store := newTestKeySet(t, time.Hour)
+
+	promoted, err := set.Rotate()
+	if err != nil {
+		t.Fatalf("Rotate() returned error: %v", err)
+	}
+
+	stored := store.keys[promoted.KeyID]
+	if !stored.Encrypted || strings.Contains(stored.PrivateKey, "PRIVATE KEY") {
+		t.Errorf("Rotate() stored key error:\n   expected: an encrypted key\n   got:      %q", stored.PrivateKey)
+	}
+
+	_, err = NewKeySet(store, set.bootstrap, time.Hour, []byte("another-secret"))
+	if err == nil {
+		t.Errorf("NewKeySet() error:\n   expected: the key not to load with the wrong encryption key\n   got:      %v", err)
+	}
+}
+
+func TestKeySet_RotateRequiresEncryptionKey(t *testing.T) {
+	bootstrap, _, err := GenerateKey(SigningMethodEd25519)
+	if err != nil {
+		t.Fatalf("GenerateKey() returned error: %v", err)
+	}
+	store := &memoryKeyStore{keys: map[string]models.SigningKey{}}
+	set, err := NewKeySet(store, bootstrap, time.Hour, nil)
+	if err != nil {
+		t.Fatalf("NewKeySet() returned error: %v", err)
+	}
+
+	_, err = set.Rotate()
+	if !errors.Is(err, ErrNoKeyEncryption) || len(store.keys) != 0 {
+		t.Errorf("Rotate() error:\n   expected: %v and nothing stored\n   got:      %v, %d keys", ErrNoKeyEncryption, err, len(store.keys))
+	}
+}
+
+func TestKeySet_LoadsUnencryptedKeys(t *testing.T) {
+	set, store := newTestKeySet(t, time.Hour)
+
+	key, contents, err := GenerateKey(SigningMethodEd25519)
+	if err != nil {
+		t.Fatalf("GenerateKey() returned error: %v", err)
+	}
+	store.keys[key.ID] = models.SigningKey{KeyID: key.ID, Algorithm: key.Method.Alg(), PrivateKey: string(contents),
+		Status: KeyStatusActive, CreatedAt: time.Now()}
+
+	err = set.Reload()
+	if err != nil {
+		t.Fatalf("Reload() returned error: %v", err)
+	}
+	active, _ := set.SigningKey()
+	if active.ID != key.ID {
+		t.Errorf("Reload() error:\n   expected active: %v\n   got:             %v", key.ID, active.ID)
+	}
+}
+
+func TestGenerateKey_HMAC(t *testing.T) {
+	key, contents, err := GenerateKey(jwt.SigningMethodHS256)
+	if err != nil {
+		t.Fatalf("GenerateKey() returned error: %v", err)
+	}
+
+	parsed, err := ParsePrivateKeyPEM(contents)
+	if err != nil {
+		t.Fatalf("ParsePrivateKeyPEM() returned error: %v", err)
+	}
+	if parsed.ID != key.ID {
+		t.Errorf("ParsePrivateKeyPEM() error:\n   expected: %v\n   got:      %v", key.ID, parsed.ID)
+	}
+}
@@ -0,0 +1,29 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// NewOpaqueToken returns a random token for the client and the hash that is stored in its place
+func NewOpaqueToken() (string, string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+
+	value := encode(b)
+	return value, HashOpaqueToken(value), nil
+}
+
+// HashOpaqueToken hashes a token for storage and lookup, the tokens are random so a fast hash is enough
+func HashOpaqueToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// ErrRefreshTokenReused is returned when a refresh token that was already rotated out is presented again
+var ErrRefreshTokenReused = errors.New("refresh token has already been used")
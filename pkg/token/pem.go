@@ -0,0 +1,89 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// LoadPrivateKeyFile reads a PEM encoded RSA, ECDSA or Ed25519 private key from a file
+func LoadPrivateKeyFile(path string) (*Key, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParsePrivateKeyPEM(contents)
+}
+
+// ParsePrivateKeyPEM parses a PKCS1, SEC1 or PKCS8 private key or a generated HMAC secret, the signing method is picked from the key type
+func ParsePrivateKeyPEM(contents []byte) (*Key, error) {
+	block, _ := pem.Decode(contents)
+	if block == nil {
+		return nil, jwt.ErrKeyMustBePEMEncoded
+	}
+
+	var privateKey interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case hmacPEMType:
+		return NewHMACKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKey(privateKey)
+}
+
+// NewKey wraps an RSA, ECDSA or Ed25519 private key, its kid is the JWK thumbprint of the public key
+func NewKey(privateKey interface{}) (*Key, error) {
+	key := &Key{Private: privateKey}
+
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		key.Method = jwt.SigningMethodRS256
+		key.Public = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			key.Method = jwt.SigningMethodES256
+		case elliptic.P384():
+			key.Method = jwt.SigningMethodES384
+		case elliptic.P521():
+			key.Method = jwt.SigningMethodES512
+		default:
+			return nil, errors.New("unsupported elliptic curve")
+		}
+		key.Public = &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Method = SigningMethodEd25519
+		key.Public = k.Public()
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+
+	kid, err := Thumbprint(key)
+	if err != nil {
+		return nil, err
+	}
+	key.ID = kid
+
+	return key, nil
+}
@@ -0,0 +1,20 @@
+package token
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+)
+
+// PKCEMethodS256 is the only PKCE code challenge method accepted, plain offers no protection
+const PKCEMethodS256 = "S256"
+
+// VerifyPKCE checks the code verifier against the S256 code challenge from the authorization request, RFC 7636
+func VerifyPKCE(challenge string, verifier string) bool {
+	// RFC 7636 section 4.1 requires verifiers between 43 and 128 characters
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	return subtle.ConstantTimeCompare([]byte(encode(sum[:])), []byte(challenge)) == 1
+}
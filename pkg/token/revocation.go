@@ -0,0 +1,181 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrTokenRevoked is returned for tokens that were revoked by logout or by revoking a user's sessions
+var ErrTokenRevoked = errors.New("token has been revoked")
+
+// RevocationStore is the interface setup for persisting revocations so every replica shares them
+type RevocationStore interface {
+	GetRevocations() ([]models.Revocation, error)
+	SaveRevocation(revocation *models.Revocation) error
+}
+
+// RevocationList keeps every live revocation in memory so checking a token never touches the database,
+// revocations only need to be kept until the tokens they cover would have expired anyway
+type RevocationList struct {
+	store    RevocationStore
+	lifetime time.Duration
+
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+	users  map[string]time.Time
+}
+
+// NewRevocationList loads the stored revocations, lifetime is the access token lifetime
+func NewRevocationList(store RevocationStore, lifetime time.Duration) (*RevocationList, error) {
+	list := &RevocationList{
+		store:    store,
+		lifetime: lifetime,
+		tokens:   map[string]time.Time{},
+		users:    map[string]time.Time{},
+	}
+
+	err := list.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// Reload reads the revocations from the store again, so revocations made by other replicas are picked up
+func (l *RevocationList) Reload() error {
+	revocations, err := l.store.GetRevocations()
+	if err != nil {
+		return err
+	}
+
+	tokens := map[string]time.Time{}
+	users := map[string]time.Time{}
+	for _, revocation := range revocations {
+		if revocation.JTI != "" {
+			tokens[revocation.JTI] = revocation.ExpiresAt
+			continue
+		}
+		// Revocations from before tokens carried the user's ID have the username, which was the subject then
+		subject := revocation.Subject
+		if subject == "" {
+			subject = revocation.Username
+		}
+		if revocation.RevokedAt.After(users[subject]) {
+			users[subject] = revocation.RevokedAt
+		}
+	}
+
+	// The loaded revocations are merged in, a revocation made here while the store was being read is not lost, and
+	// only expired revocations are dropped
+	now := time.Now().UTC()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for jti, expiresAt := range tokens {
+		if expiresAt.After(l.tokens[jti]) {
+			l.tokens[jti] = expiresAt
+		}
+	}
+	for subject, revokedAt := range users {
+		if revokedAt.After(l.users[subject]) {
+			l.users[subject] = revokedAt
+		}
+	}
+	for jti, expiresAt := range l.tokens {
+		if expiresAt.Before(now) {
+			delete(l.tokens, jti)
+		}
+	}
+	for subject, revokedAt := range l.users {
+		if revokedAt.Add(l.lifetime).Before(now) {
+			delete(l.users, subject)
+		}
+	}
+
+	return nil
+}
+
+// Watch reloads the revocation list on the interval until the context is done
+func (l *RevocationList) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := l.Reload()
+			if err != nil {
+				log.Errorf("Unable to reload revocations: %v", err)
+			}
+		}
+	}
+}
+
+// Check returns ErrTokenRevoked when the token's jti was revoked or its user's sessions were revoked after it was issued
+func (l *RevocationList) Check(claims *Claims) error {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if _, ok := l.tokens[claims.Id]; ok && claims.Id != "" {
+		return ErrTokenRevoked
+	}
+
+	if revokedAt, ok := l.users[claims.Subject]; ok && claims.IssuedAt < revokedAt.Unix() {
+		return ErrTokenRevoked
+	}
+
+	return nil
+}
+
+// RevokeToken revokes a single token until it expires
+func (l *RevocationList) RevokeToken(claims *Claims) error {
+	revocation := &models.Revocation{
+		JTI:       claims.Id,
+		Username:  claims.Username,
+		RevokedAt: time.Now().UTC(),
+		ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC(),
+	}
+
+	err := l.store.SaveRevocation(revocation)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens[revocation.JTI] = revocation.ExpiresAt
+
+	return nil
+}
+
+// RevokeSessions revokes every token issued to the subject up to now, the subject is the user's ID. Tokens only carry
+// whole seconds, so the revocation covers the rest of the current second and RevokeSessions waits it out, tokens
+// issued after it returns stay valid
+func (l *RevocationList) RevokeSessions(subject string) error {
+	revokedAt := time.Now().UTC().Truncate(time.Second).Add(time.Second)
+	revocation := &models.Revocation{
+		Subject:   subject,
+		RevokedAt: revokedAt,
+		ExpiresAt: revokedAt.Add(l.lifetime),
+	}
+
+	err := l.store.SaveRevocation(revocation)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	l.users[subject] = revokedAt
+	l.mu.Unlock()
+
+	time.Sleep(time.Until(revokedAt))
+
+	return nil
+}
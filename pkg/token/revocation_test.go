@@ -0,0 +1,176 @@
+package token
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/geeksheik9/login-service/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryRevocationStore struct {
+	revocations []models.Revocation
+}
+
+func (m *memoryRevocationStore) GetRevocations() ([]models.Revocation, error) {
+	return m.revocations, nil
+}
+
+func (m *memoryRevocationStore) SaveRevocation(revocation *models.Revocation) error {
+	m.revocations = append(m.revocations, *revocation)
+	return nil
+}
+
+func newRevokingIssuer(t *testing.T) (*Issuer, *memoryRevocationStore) {
+	store := &memoryRevocationStore{}
+	revocations, err := NewRevocationList(store, time.Hour)
+	if err != nil {
+		t.Fatalf("NewRevocationList() returned error: %v", err)
+	}
+
+	issuer := newTestIssuer(t)
+	issuer.Revocations = revocations
+
+	return issuer, store
+}
+
+func TestRevocationList_RevokeToken(t *testing.T) {
+	issuer, _ := newRevokingIssuer(t)
+	user := &models.User{Username: "user"}
+
+	revoked, claims, _ := issuer.Issue(user, "")
+	other, _, _ := issuer.Issue(user, "")
+
+	err := issuer.Revocations.RevokeToken(claims)
+	if err != nil {
+		t.Fatalf("RevokeToken() returned error: %v", err)
+	}
+
+	_, err = issuer.Validate(revoked)
+	if !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Validate() error:\n   expected: %v\n   got:      %v", ErrTokenRevoked, err)
+	}
+
+	_, err = issuer.Validate(other)
+	if err != nil {
+		t.Errorf("Validate() error:\n   expected: <nil>\n   got:      %v", err)
+	}
+}
+
+func TestRevocationList_RevokeSessions(t *testing.T) {
+	issuer, store := newRevokingIssuer(t)
+	user := &models.User{ID: primitive.NewObjectID(), Username: "user"}
+
+	past := jwt.TimeFunc().Add(-time.Minute)
+	jwt.TimeFunc = func() time.Time { return past }
+	old, _, _ := issuer.Issue(user, "")
+	jwt.TimeFunc = time.Now
+
+	err := issuer.Revocations.RevokeSessions(user.ID.Hex())
+	if err != nil {
+		t.Fatalf("RevokeSessions() returned error: %v", err)
+	}
+
+	_, err = issuer.Validate(old)
+	if !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Validate() old token error:\n   expected: %v\n   got:      %v", ErrTokenRevoked, err)
+	}
+
+	replica, _ := NewRevocationList(store, time.Hour)
+	issuer.Revocations = replica
+	_, err = issuer.Validate(old)
+	if !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Validate() replica error:\n   expected: %v\n   got:      %v", ErrTokenRevoked, err)
+	}
+
+	fresh, _, _ := issuer.Issue(user, "")
+	_, err = issuer.Validate(fresh)
+	if err != nil {
+		t.Errorf("Validate() new token error:\n   expected: <nil>\n   got:      %v", err)
+	}
+}
+
+func TestRevocationList_RevokeSessionsSameSecond(t *testing.T) {
+	issuer, _ := newRevokingIssuer(t)
+	user := &models.User{ID: primitive.NewObjectID(), Username: "user"}
+
+	current, _, _ := issuer.Issue(user, "")
+	err := issuer.Revocations.RevokeSessions(user.ID.Hex())
+	if err != nil {
+		t.Fatalf("RevokeSessions() returned error: %v", err)
+	}
+
+	_, err = issuer.Validate(current)
+	if !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Validate() same second error:\n   expected: %v\n   got:      %v", ErrTokenRevoked, err)
+	}
+
+	replacement, _, _ := issuer.Issue(user, "")
+	_, err = issuer.Validate(replacement)
+	if err != nil {
+		t.Errorf("Validate() replacement error:\n   expected: <nil>\n   got:      %v", err)
+	}
+}
+
+func TestRevocationList_RevokeSessionsBySubject(t *testing.T) {
+	issuer, store := newRevokingIssuer(t)
+	renamed := &models.User{ID: primitive.NewObjectID(), Username: "user"}
+	other := &models.User{ID: primitive.NewObjectID(), Username: "user"}
+
+	err := issuer.Revocations.RevokeSessions(renamed.ID.Hex())
+	if err != nil {
+		t.Fatalf("RevokeSessions() returned error: %v", err)
+	}
+
+	// Someone else with the same username is not affected
+	token, _, _ := issuer.Issue(other, "")
+	_, err = issuer.Validate(token)
+	if err != nil {
+		t.Errorf("Validate() other user error:\n   expected: <nil>\n   got:      %v", err)
+	}
+
+	// Revocations from before the subject was stored still apply to the username
+	store.revocations = append(store.revocations, models.Revocation{Username: "legacy", RevokedAt: time.Now().Add(time.Minute)})
+	err = issuer.Revocations.Reload()
+	if err != nil {
+		t.Fatalf("Reload() returned error: %v", err)
+	}
+	claims := &Claims{StandardClaims: jwt.StandardClaims{Subject: "legacy", IssuedAt: time.Now().Unix()}}
+	if !errors.Is(issuer.Revocations.Check(claims), ErrTokenRevoked) {
+		t.Errorf("Check() legacy error:\n   expected: %v\n   got:      <nil>", ErrTokenRevoked)
+	}
+}
+
+func TestRevocationList_ReloadKeepsRevocations(t *testing.T) {
+	issuer, store := newRevokingIssuer(t)
+	user := &models.User{ID: primitive.NewObjectID(), Username: "user"}
+
+	revoked, claims, _ := issuer.Issue(user, "")
+	err := issuer.Revocations.RevokeToken(claims)
+	if err != nil {
+		t.Fatalf("RevokeToken() returned error: %v", err)
+	}
+
+	// A reload that read the store before the revocation was saved does not bring the token back
+	store.revocations = nil
+	err = issuer.Revocations.Reload()
+	if err != nil {
+		t.Fatalf("Reload() returned error: %v", err)
+	}
+	_, err = issuer.Validate(revoked)
+	if !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Validate() error:\n   expected: %v\n   got:      %v", ErrTokenRevoked, err)
+	}
+
+	// Expired revocations are dropped
+	store.revocations = []models.Revocation{{JTI: "expired", ExpiresAt: time.Now().Add(-time.Minute)}}
+	err = issuer.Revocations.Reload()
+	if err != nil {
+		t.Fatalf("Reload() returned error: %v", err)
+	}
+	if _, ok := issuer.Revocations.tokens["expired"]; ok {
+		t.Errorf("Reload() error:\n   expected: the expired revocation dropped\n   got:      %v", issuer.Revocations.tokens)
+	}
+}
@@ -0,0 +1,25 @@
+package token
+
+import (
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Sign creates a token for the claims signed with the provider's signing key
+func Sign(keys KeyProvider, claims jwt.Claims) (string, error) {
+	key, err := keys.SigningKey()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+
+	return token.SignedString(key.Private)
+}
+
+// Parse verifies the token string with the provider's keys and decodes it into claims
+func Parse(keys KeyProvider, tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, keys.VerificationKey)
+}
@@ -0,0 +1,210 @@
+package token
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestNewHMACKeyProvider_Empty(t *testing.T) {
+	_, err := NewHMACKeyProvider(nil)
+	if err == nil {
+		t.Errorf("NewHMACKeyProvider() error:\n   expected: <error>\n   got:      %v", err)
+	}
+}
+
+func TestSignAndParse(t *testing.T) {
+	keys, err := NewHMACKeyProvider([]byte("a-much-better-secret"))
+	if err != nil {
+		t.Fatalf("NewHMACKeyProvider() returned error: %v", err)
+	}
+
+	tokenString, err := Sign(keys, jwt.MapClaims{"username": "user"})
+	if err != nil {
+		t.Fatalf("Sign() returned error: %v", err)
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := Parse(keys, tokenString, claims)
+	if err != nil || !token.Valid {
+		t.Fatalf("Parse() error:\n   expected: <nil>\n   got:      %v", err)
+	}
+	if claims["username"] != "user" {
+		t.Errorf("Parse() claims error:\n   expected: user\n   got:      %v", claims["username"])
+	}
+}
+
+func TestParse_WrongSecret(t *testing.T) {
+	signer, _ := NewHMACKeyProvider([]byte("first-secret"))
+	verifier, _ := NewHMACKeyProvider([]byte("second-secret"))
+
+	tokenString, _ := Sign(signer, jwt.MapClaims{"username": "user"})
+
+	_, err := Parse(verifier, tokenString, jwt.MapClaims{})
+	if err == nil {
+		t.Errorf("Parse() error:\n   expected: <error>\n   got:      %v", err)
+	}
+}
+
+func TestParse_UnexpectedSigningMethod(t *testing.T) {
+	keys, _ := NewHMACKeyProvider([]byte("a-much-better-secret"))
+
+	tokenString, _ := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.MapClaims{}).SignedString([]byte("a-much-better-secret"))
+
+	_, err := Parse(keys, tokenString, jwt.MapClaims{})
+	if err == nil {
+		t.Errorf("Parse() error:\n   expected: %v\n   got:      %v", ErrUnexpectedSigningMethod, err)
+	}
+}
+
+func TestSignAndParse_AsymmetricKeys(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	_, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name       string
+		privateKey interface{}
+		alg        string
+	}{
+		{name: "rsa", privateKey: rsaKey, alg: "RS256"},
+		{name: "ecdsa", privateKey: ecKey, alg: "ES256"},
+		{name: "ed25519", privateKey: edKey, alg: "EdDSA"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			key, err := NewKey(test.privateKey)
+			if err != nil {
+				t.Fatalf("NewKey() returned error: %v", err)
+			}
+			if key.Method.Alg() != test.alg {
+				t.Errorf("NewKey() alg error:\n   expected: %v\n   got:      %v", test.alg, key.Method.Alg())
+			}
+
+			keys := NewStaticKeyProvider(key)
+			tokenString, err := Sign(keys, jwt.MapClaims{"username": "user"})
+			if err != nil {
+				t.Fatalf("Sign() returned error: %v", err)
+			}
+
+			token, err := Parse(keys, tokenString, jwt.MapClaims{})
+			if err != nil {
+				t.Fatalf("Parse() returned error: %v", err)
+			}
+			if token.Header["kid"] != key.ID {
+				t.Errorf("Sign() kid error:\n   expected: %v\n   got:      %v", key.ID, token.Header["kid"])
+			}
+
+			jwk, err := NewJSONWebKey(key)
+			if err != nil {
+				t.Errorf("NewJSONWebKey() returned error: %v", err)
+			}
+			if jwk.KeyID != key.ID || jwk.Alg != test.alg {
+				t.Errorf("NewJSONWebKey() error:\n   expected: %v %v\n   got:      %v %v", key.ID, test.alg, jwk.KeyID, jwk.Alg)
+			}
+		})
+	}
+}
+
+func TestParsePrivateKeyPEM(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	der, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+	contents := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	key, err := ParsePrivateKeyPEM(contents)
+	if err != nil {
+		t.Fatalf("ParsePrivateKeyPEM() returned error: %v", err)
+	}
+	if key.Method.Alg() != "ES256" {
+		t.Errorf("ParsePrivateKeyPEM() alg error:\n   expected: ES256\n   got:      %v", key.Method.Alg())
+	}
+
+	_, err = ParsePrivateKeyPEM([]byte("not a key"))
+	if err == nil {
+		t.Errorf("ParsePrivateKeyPEM() error:\n   expected: <error>\n   got:      %v", err)
+	}
+}
+
+func TestThumbprint_RFC7638(t *testing.T) {
+	// Example key from RFC 7638 section 3.1
+	n, _ := base64.RawURLEncoding.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+	key := &Key{
+		Method: jwt.SigningMethodRS256,
+		Public: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537},
+	}
+
+	thumbprint, err := Thumbprint(key)
+	if err != nil {
+		t.Fatalf("Thumbprint() returned error: %v", err)
+	}
+	if thumbprint != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("Thumbprint() error:\n   expected: NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs\n   got:      %v", thumbprint)
+	}
+}
+
+func TestNewJSONWebKeySet_SkipsHMAC(t *testing.T) {
+	hmacKey, _ := NewHMACKey([]byte("a-much-better-secret"))
+
+	set := NewJSONWebKeySet([]*Key{hmacKey})
+	if len(set.Keys) != 0 {
+		t.Errorf("NewJSONWebKeySet() error:\n   expected: 0 keys\n   got:      %v", len(set.Keys))
+	}
+}
+
+func TestNewOpaqueToken(t *testing.T) {
+	value, hash, err := NewOpaqueToken()
+	if err != nil {
+		t.Fatalf("NewOpaqueToken() returned error: %v", err)
+	}
+
+	if value == hash || HashOpaqueToken(value) != hash {
+		t.Errorf("NewOpaqueToken() error:\n   expected hash: %v\n   got:           %v", HashOpaqueToken(value), hash)
+	}
+
+	other, _, _ := NewOpaqueToken()
+	if other == value {
+		t.Errorf("NewOpaqueToken() error: returned the same token twice")
+	}
+}
+
+func TestNewUserCode(t *testing.T) {
+	code, err := NewUserCode()
+	if err != nil {
+		t.Fatalf("NewUserCode() returned error: %v", err)
+	}
+
+	if len(code) != 9 || code[4] != '-' {
+		t.Errorf("NewUserCode() error:\n   expected: XXXX-XXXX\n   got:      %v", code)
+	}
+	if NormalizeUserCode(code) != code {
+		t.Errorf("NormalizeUserCode() error:\n   expected: %v\n   got:      %v", code, NormalizeUserCode(code))
+	}
+}
+
+func TestNormalizeUserCode(t *testing.T) {
+	if code := NormalizeUserCode(" wdjb mjht "); code != "WDJB-MJHT" {
+		t.Errorf("NormalizeUserCode() error:\n   expected: WDJB-MJHT\n   got:      %v", code)
+	}
+}
+
+func TestNewNumericCode(t *testing.T) {
+	code, err := NewNumericCode(6)
+	if err != nil {
+		t.Fatalf("NewNumericCode() returned error: %v", err)
+	}
+
+	if len(code) != 6 || strings.Trim(code, "0123456789") != "" {
+		t.Errorf("NewNumericCode() error:\n   expected: 6 digits\n   got:      %v", code)
+	}
+}
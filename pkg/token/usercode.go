@@ -0,0 +1,62 @@
+package token
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+// userCodeAlphabet leaves out vowels and look-alike characters so user codes are easy to type and never spell words,
+// as suggested by RFC 8628 section 6.1
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// NewUserCode returns a random eight character user code formatted as XXXX-XXXX
+func NewUserCode() (string, error) {
+	var code strings.Builder
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	for i := 0; i < 8; i++ {
+		if i == 4 {
+			code.WriteByte('-')
+		}
+
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code.WriteByte(userCodeAlphabet[n.Int64()])
+	}
+
+	return code.String(), nil
+}
+
+// NormalizeUserCode puts a user code typed by a person into the form it was issued in
+func NormalizeUserCode(value string) string {
+	var code strings.Builder
+	for _, r := range strings.ToUpper(value) {
+		if strings.ContainsRune(userCodeAlphabet, r) {
+			code.WriteRune(r)
+		}
+	}
+
+	normalized := code.String()
+	if len(normalized) != 8 {
+		return normalized
+	}
+
+	return normalized[:4] + "-" + normalized[4:]
+}
+
+// NewNumericCode returns a random code of the given number of digits, for codes people copy from an email
+func NewNumericCode(digits int) (string, error) {
+	var code strings.Builder
+	ten := big.NewInt(10)
+	for i := 0; i < digits; i++ {
+		n, err := rand.Int(rand.Reader, ten)
+		if err != nil {
+			return "", err
+		}
+		code.WriteByte(byte('0' + n.Int64()))
+	}
+
+	return code.String(), nil
+}
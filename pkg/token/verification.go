@@ -0,0 +1,57 @@
+package token
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/geeksheik9/login-service/models"
+)
+
+// EmailVerificationAudience is the audience of email verification tokens, it keeps them from being accepted as access
+// tokens and access tokens from being accepted as them
+const EmailVerificationAudience = "email-verification"
+
+// EmailVerificationClaims are the claims of the signed token in an email verification link, the address is part of
+// the token so a link stops working once the user's address changes
+type EmailVerificationClaims struct {
+	jwt.StandardClaims
+	Email string `json:"email"`
+}
+
+// IssueEmailVerification signs a token proving the user received mail at their address
+func (i *Issuer) IssueEmailVerification(user *models.User, lifetime time.Duration) (string, error) {
+	now := jwt.TimeFunc()
+	claims := &EmailVerificationClaims{
+		StandardClaims: jwt.StandardClaims{
+			Audience:  EmailVerificationAudience,
+			ExpiresAt: now.Add(lifetime).Unix(),
+			IssuedAt:  now.Unix(),
+			Issuer:    i.Issuer,
+			Subject:   user.ID.Hex(),
+		},
+		Email: user.Email,
+	}
+
+	return Sign(i.Keys, claims)
+}
+
+// ValidateEmailVerification verifies the signature, lifetime, issuer and audience of an email verification token
+func (i *Issuer) ValidateEmailVerification(tokenString string) (*EmailVerificationClaims, error) {
+	claims := &EmailVerificationClaims{}
+	parsed, err := Parse(i.Keys, tokenString, claims)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	if !parsed.Valid || claims.ExpiresAt == 0 || claims.Subject == "" || claims.Email == "" {
+		return nil, ErrInvalidToken
+	}
+	if !claims.VerifyIssuer(i.Issuer, true) {
+		return nil, ErrInvalidIssuer
+	}
+	if !claims.VerifyAudience(EmailVerificationAudience, true) {
+		return nil, ErrInvalidAudience
+	}
+
+	return claims, nil
+}
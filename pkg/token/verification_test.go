@@ -0,0 +1,58 @@
+package token
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/geeksheik9/login-service/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestIssuer_EmailVerification(t *testing.T) {
+	issuer := newTestIssuer(t)
+	user := &models.User{ID: primitive.NewObjectID(), Username: "user", Email: "user@example.com"}
+
+	tokenString, err := issuer.IssueEmailVerification(user, time.Hour)
+	if err != nil {
+		t.Fatalf("IssueEmailVerification() returned error: %v", err)
+	}
+
+	claims, err := issuer.ValidateEmailVerification(tokenString)
+	if err != nil {
+		t.Fatalf("ValidateEmailVerification() returned error: %v", err)
+	}
+	if claims.Subject != user.ID.Hex() || claims.Email != "user@example.com" {
+		t.Errorf("ValidateEmailVerification() claims error: got %+v", claims)
+	}
+
+	_, err = issuer.Validate(tokenString)
+	if !errors.Is(err, ErrInvalidAudience) {
+		t.Errorf("Validate() error:\n   expected: %v\n   got:      %v", ErrInvalidAudience, err)
+	}
+}
+
+func TestIssuer_ValidateEmailVerificationErrors(t *testing.T) {
+	issuer := newTestIssuer(t)
+	user := &models.User{Username: "user", Email: "user@example.com"}
+
+	accessToken, _, err := issuer.Issue(user, "")
+	if err != nil {
+		t.Fatalf("Issue() returned error: %v", err)
+	}
+	expired, err := issuer.IssueEmailVerification(user, -time.Minute)
+	if err != nil {
+		t.Fatalf("IssueEmailVerification() returned error: %v", err)
+	}
+
+	for name, tokenString := range map[string]string{
+		"access token": accessToken,
+		"expired":      expired,
+		"garbage":      "not.a.token",
+	} {
+		_, err := issuer.ValidateEmailVerification(tokenString)
+		if err == nil {
+			t.Errorf("ValidateEmailVerification(%s) error:\n   expected: <error>\n   got:      %v", name, err)
+		}
+	}
+}
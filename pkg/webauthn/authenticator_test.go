@@ -0,0 +1,207 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"sort"
+	"testing"
+)
+
+// softAuthenticator is a software authenticator holding one credential, it stands in for a security key or passkey
+// provider in tests
+type softAuthenticator struct {
+	t         *testing.T
+	rpID      string
+	origin    string
+	algorithm int64
+	key       crypto.Signer
+	credID    []byte
+	signCount uint32
+	flags     byte
+}
+
+func newSoftAuthenticator(t *testing.T, rpID string, origin string, algorithm int64) *softAuthenticator {
+	var key crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgES256:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case AlgEdDSA:
+		_, key, err = ed25519.GenerateKey(rand.Reader)
+	case AlgRS256:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		t.Fatalf("generating key returned error: %v", err)
+	}
+
+	credID := make([]byte, 16)
+	_, _ = rand.Read(credID)
+
+	return &softAuthenticator{
+		t:         t,
+		rpID:      rpID,
+		origin:    origin,
+		algorithm: algorithm,
+		key:       key,
+		credID:    credID,
+		flags:     flagUserPresent | flagUserVerified,
+	}
+}
+
+// create answers navigator.credentials.create() with "none" attestation
+func (a *softAuthenticator) create(challenge string) *AttestationResponse {
+	authData := a.authenticatorData(flagAttested)
+	authData = append(authData, make([]byte, 16)...)
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credID)))
+	authData = append(authData, a.credID...)
+	authData = append(authData, a.coseKey()...)
+
+	attestation := encodeCBOR(map[interface{}]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[interface{}]interface{}{},
+		"authData": authData,
+	})
+
+	return &AttestationResponse{
+		ClientDataJSON:    a.clientData(typeCreate, challenge),
+		AttestationObject: attestation,
+	}
+}
+
+// get answers navigator.credentials.get(), every assertion moves the counter forward
+func (a *softAuthenticator) get(challenge string) *AssertionResponse {
+	a.signCount++
+	authData := a.authenticatorData(0)
+	clientDataJSON := a.clientData(typeGet, challenge)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	message := append(append([]byte{}, authData...), clientDataHash[:]...)
+
+	var signature []byte
+	var err error
+	switch a.algorithm {
+	case AlgEdDSA:
+		signature, err = a.key.Sign(rand.Reader, message, crypto.Hash(0))
+	default:
+		digest := sha256.Sum256(message)
+		signature, err = a.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	}
+	if err != nil {
+		a.t.Fatalf("signing returned error: %v", err)
+	}
+
+	return &AssertionResponse{
+		ClientDataJSON:    clientDataJSON,
+		AuthenticatorData: authData,
+		Signature:         signature,
+	}
+}
+
+func (a *softAuthenticator) authenticatorData(flags byte) []byte {
+	rpIDHash := sha256.Sum256([]byte(a.rpID))
+	data := append([]byte{}, rpIDHash[:]...)
+	data = append(data, a.flags|flags)
+	return binary.BigEndian.AppendUint32(data, a.signCount)
+}
+
+func (a *softAuthenticator) clientData(ceremony string, challenge string) []byte {
+	data, err := json.Marshal(map[string]interface{}{"type": ceremony, "challenge": challenge, "origin": a.origin})
+	if err != nil {
+		a.t.Fatalf("json.Marshal() returned error: %v", err)
+	}
+
+	return data
+}
+
+func (a *softAuthenticator) coseKey() []byte {
+	switch key := a.key.Public().(type) {
+	case *ecdsa.PublicKey:
+		return encodeCBOR(map[interface{}]interface{}{
+			int64(1): int64(coseKeyTypeEC2), int64(3): AlgES256, int64(-1): int64(coseCurveP256),
+			int64(-2): key.X.FillBytes(make([]byte, 32)), int64(-3): key.Y.FillBytes(make([]byte, 32)),
+		})
+	case ed25519.PublicKey:
+		return encodeCBOR(map[interface{}]interface{}{
+			int64(1): int64(coseKeyTypeOKP), int64(3): AlgEdDSA, int64(-1): int64(coseCurveEd25519), int64(-2): []byte(key),
+		})
+	case *rsa.PublicKey:
+		return encodeCBOR(map[interface{}]interface{}{
+			int64(1): int64(coseKeyTypeRSA), int64(3): AlgRS256, int64(-1): key.N.Bytes(), int64(-2): big.NewInt(int64(key.E)).Bytes(),
+		})
+	}
+
+	a.t.Fatalf("unsupported key")
+	return nil
+}
+
+// encodeCBOR encodes the types decodeCBOR returns, map keys are sorted so the output is stable
+func encodeCBOR(item interface{}) []byte {
+	switch value := item.(type) {
+	case int64:
+		if value < 0 {
+			return cborHead(1, uint64(-1-value))
+		}
+		return cborHead(0, uint64(value))
+	case []byte:
+		return append(cborHead(2, uint64(len(value))), value...)
+	case string:
+		return append(cborHead(3, uint64(len(value))), value...)
+	case bool:
+		if value {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	case nil:
+		return []byte{0xf6}
+	case []interface{}:
+		out := cborHead(4, uint64(len(value)))
+		for _, element := range value {
+			out = append(out, encodeCBOR(element)...)
+		}
+		return out
+	case map[interface{}]interface{}:
+		keys := make([][]byte, 0, len(value))
+		encoded := map[string][]byte{}
+		for key, element := range value {
+			k := encodeCBOR(key)
+			keys = append(keys, k)
+			encoded[string(k)] = encodeCBOR(element)
+		}
+		sort.Slice(keys, func(i, j int) bool { return string(keys[i]) < string(keys[j]) })
+		out := cborHead(5, uint64(len(value)))
+		for _, k := range keys {
+			out = append(append(out, k...), encoded[string(k)]...)
+		}
+		return out
+	}
+
+	panic("unsupported cbor type")
+}
+
+func cborHead(major byte, argument uint64) []byte {
+	switch {
+	case argument < 24:
+		return []byte{major<<5 | byte(argument)}
+	case argument <= 0xff:
+		return []byte{major<<5 | 24, byte(argument)}
+	case argument <= 0xffff:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(argument))
+	case argument <= 0xffffffff:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(argument))
+	}
+	return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, argument)
+}
+
+func encodeURL(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
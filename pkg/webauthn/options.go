@@ -0,0 +1,79 @@
+package webauthn
+
+// RelyingPartyEntity names the relying party to the authenticator
+type RelyingPartyEntity struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// UserEntity is the account a credential is created for, the ID is the user handle and must not be personal data
+type UserEntity struct {
+	ID          URLEncoded `json:"id"`
+	Name        string     `json:"name"`
+	DisplayName string     `json:"displayName"`
+}
+
+// CredentialParameter is a credential type and algorithm the relying party accepts
+type CredentialParameter struct {
+	Type      string `json:"type"`
+	Algorithm int64  `json:"alg"`
+}
+
+// CredentialDescriptor identifies an existing credential
+type CredentialDescriptor struct {
+	Type       string     `json:"type"`
+	ID         URLEncoded `json:"id"`
+	Transports []string   `json:"transports,omitempty"`
+}
+
+// AuthenticatorSelection states what kind of authenticator the relying party wants
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey,omitempty"`
+	UserVerification string `json:"userVerification,omitempty"`
+}
+
+// CreationOptions are the options passed to navigator.credentials.create() as publicKey
+type CreationOptions struct {
+	Challenge              string                 `json:"challenge"`
+	RelyingParty           RelyingPartyEntity     `json:"rp"`
+	User                   UserEntity             `json:"user"`
+	Parameters             []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions are the options passed to navigator.credentials.get() as publicKey
+type RequestOptions struct {
+	Challenge        string                 `json:"challenge"`
+	RelyingPartyID   string                 `json:"rpId"`
+	Timeout          int64                  `json:"timeout,omitempty"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// Parameters lists the supported algorithms for pubKeyCredParams
+func Parameters() []CredentialParameter {
+	return []CredentialParameter{
+		{Type: "public-key", Algorithm: AlgES256},
+		{Type: "public-key", Algorithm: AlgEdDSA},
+		{Type: "public-key", Algorithm: AlgRS256},
+	}
+}
+
+// RegistrationCredential is the PublicKeyCredential returned by navigator.credentials.create(), as JSON
+type RegistrationCredential struct {
+	ID       string              `json:"id"`
+	RawID    URLEncoded          `json:"rawId"`
+	Type     string              `json:"type"`
+	Response AttestationResponse `json:"response"`
+}
+
+// AssertionCredential is the PublicKeyCredential returned by navigator.credentials.get(), as JSON
+type AssertionCredential struct {
+	ID       string            `json:"id"`
+	RawID    URLEncoded        `json:"rawId"`
+	Type     string            `json:"type"`
+	Response AssertionResponse `json:"response"`
+}
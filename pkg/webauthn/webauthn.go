@@ -0,0 +1,316 @@
+// Package webauthn verifies the registration and assertion ceremonies of Web Authentication, W3C WebAuthn Level 2.
+// Attestation statements are not checked, the relying party asks for "none" attestation and trusts any authenticator
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// challengeSize is the number of random bytes in a challenge, the spec asks for at least 16
+const challengeSize = 32
+
+// Authenticator data flags
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttested     = 0x40
+	flagExtensions   = 0x80
+)
+
+// Ceremony types found in the client data
+const (
+	typeCreate = "webauthn.create"
+	typeGet    = "webauthn.get"
+)
+
+// Verification errors, each rejection reason is distinct so callers can tell them apart
+var (
+	ErrInvalidClientData        = errors.New("client data is invalid")
+	ErrChallengeMismatch        = errors.New("challenge does not match")
+	ErrOriginMismatch           = errors.New("origin is not allowed")
+	ErrInvalidAuthenticatorData = errors.New("authenticator data is invalid")
+	ErrRPIDMismatch             = errors.New("relying party ID does not match")
+	ErrUserNotPresent           = errors.New("user was not present")
+	ErrUserNotVerified          = errors.New("user was not verified")
+	ErrInvalidAttestation       = errors.New("attestation object is invalid")
+	ErrSignCount                = errors.New("signature counter did not increase, the authenticator may be cloned")
+)
+
+// RelyingParty is this service as WebAuthn sees it, ID is the domain credentials are scoped to and Origins are the
+// exact origins ceremonies may run on
+type RelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// Credential is a registered credential as it has to be stored to verify later assertions
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	Algorithm int64
+	SignCount uint32
+	AAGUID    []byte
+}
+
+// AttestationResponse is the response of navigator.credentials.create()
+type AttestationResponse struct {
+	ClientDataJSON    URLEncoded `json:"clientDataJSON"`
+	AttestationObject URLEncoded `json:"attestationObject"`
+	Transports        []string   `json:"transports,omitempty"`
+}
+
+// AssertionResponse is the response of navigator.credentials.get()
+type AssertionResponse struct {
+	ClientDataJSON    URLEncoded `json:"clientDataJSON"`
+	AuthenticatorData URLEncoded `json:"authenticatorData"`
+	Signature         URLEncoded `json:"signature"`
+	UserHandle        URLEncoded `json:"userHandle,omitempty"`
+}
+
+// clientData is the part of CollectedClientData the relying party checks
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+// authenticatorData is the parsed form of the authenticator data, WebAuthn section 6.1
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+	aaguid    []byte
+	credID    []byte
+	publicKey []byte
+}
+
+// NewChallenge returns a random base64url challenge for one ceremony
+func NewChallenge() (string, error) {
+	b := make([]byte, challengeSize)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge returns the challenge a response was made for, so the caller can find the ceremony it belongs to before
+// verifying it
+func Challenge(clientDataJSON []byte) (string, error) {
+	var data clientData
+	err := json.Unmarshal(clientDataJSON, &data)
+	if err != nil || data.Challenge == "" {
+		return "", ErrInvalidClientData
+	}
+
+	return data.Challenge, nil
+}
+
+// VerifyRegistration checks a response to a creation ceremony started with the challenge and returns the new
+// credential, userVerification requires the authenticator to have verified the user with a PIN or biometric
+func (rp *RelyingParty) VerifyRegistration(challenge string, response *AttestationResponse, userVerification bool) (*Credential, error) {
+	err := rp.verifyClientData(response.ClientDataJSON, typeCreate, challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	item, rest, err := decodeCBOR(response.AttestationObject)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrInvalidAttestation
+	}
+	attestation, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, ErrInvalidAttestation
+	}
+	rawAuthData, ok := attestation["authData"].([]byte)
+	if !ok {
+		return nil, ErrInvalidAttestation
+	}
+	if _, ok := attestation["fmt"].(string); !ok {
+		return nil, ErrInvalidAttestation
+	}
+
+	authData, err := parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	err = rp.verifyAuthenticatorData(authData, userVerification)
+	if err != nil {
+		return nil, err
+	}
+	if authData.credID == nil {
+		return nil, fmt.Errorf("%w: no attested credential data", ErrInvalidAuthenticatorData)
+	}
+
+	key, err := ParsePublicKey(authData.publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:        authData.credID,
+		PublicKey: authData.publicKey,
+		Algorithm: key.Algorithm,
+		SignCount: authData.signCount,
+		AAGUID:    authData.aaguid,
+	}, nil
+}
+
+// VerifyAssertion checks a response to a request ceremony started with the challenge against the stored credential
+// and returns the new signature counter to store
+func (rp *RelyingParty) VerifyAssertion(challenge string, credential *Credential, response *AssertionResponse, userVerification bool) (uint32, error) {
+	err := rp.verifyClientData(response.ClientDataJSON, typeGet, challenge)
+	if err != nil {
+		return 0, err
+	}
+
+	authData, err := parseAuthenticatorData(response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+	err = rp.verifyAuthenticatorData(authData, userVerification)
+	if err != nil {
+		return 0, err
+	}
+
+	key, err := ParsePublicKey(credential.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	clientDataHash := sha256.Sum256(response.ClientDataJSON)
+	message := append(append([]byte{}, response.AuthenticatorData...), clientDataHash[:]...)
+	err = key.Verify(message, response.Signature)
+	if err != nil {
+		return 0, err
+	}
+
+	// Authenticators without a counter always report zero, otherwise the counter has to move forward
+	if (authData.signCount != 0 || credential.SignCount != 0) && authData.signCount <= credential.SignCount {
+		return 0, ErrSignCount
+	}
+
+	return authData.signCount, nil
+}
+
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string, challenge string) error {
+	var data clientData
+	err := json.Unmarshal(raw, &data)
+	if err != nil || data.Type != ceremony {
+		return ErrInvalidClientData
+	}
+	if data.CrossOrigin {
+		return fmt.Errorf("%w: cross origin ceremonies are not allowed", ErrOriginMismatch)
+	}
+	if challenge == "" || subtle.ConstantTimeCompare([]byte(data.Challenge), []byte(challenge)) != 1 {
+		return ErrChallengeMismatch
+	}
+
+	for _, origin := range rp.Origins {
+		if data.Origin == strings.TrimSuffix(origin, "/") {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrOriginMismatch, data.Origin)
+}
+
+func (rp *RelyingParty) verifyAuthenticatorData(authData *authenticatorData, userVerification bool) error {
+	rpIDHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return ErrRPIDMismatch
+	}
+	if authData.flags&flagUserPresent == 0 {
+		return ErrUserNotPresent
+	}
+	if userVerification && authData.flags&flagUserVerified == 0 {
+		return ErrUserNotVerified
+	}
+
+	return nil
+}
+
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: too short", ErrInvalidAuthenticatorData)
+	}
+
+	authData := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+
+	if authData.flags&flagAttested != 0 {
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data is too short", ErrInvalidAuthenticatorData)
+		}
+		authData.aaguid = rest[:16]
+		length := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if length == 0 || length > 1023 || len(rest) < length {
+			return nil, fmt.Errorf("%w: credential ID length is invalid", ErrInvalidAuthenticatorData)
+		}
+		authData.credID = rest[:length]
+		rest = rest[length:]
+
+		// The public key is the first CBOR item, extensions may follow it
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAuthenticatorData, err)
+		}
+		authData.publicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+
+	if authData.flags&flagExtensions != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidAuthenticatorData, err)
+		}
+		rest = after
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing data", ErrInvalidAuthenticatorData)
+	}
+
+	return authData, nil
+}
+
+// URLEncoded is binary data that is base64url encoded in JSON, the way PublicKeyCredential.toJSON() encodes it
+type URLEncoded []byte
+
+// MarshalJSON encodes the data as unpadded base64url
+func (u URLEncoded) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(u))
+}
+
+// UnmarshalJSON decodes base64url with or without padding
+func (u *URLEncoded) UnmarshalJSON(data []byte) error {
+	var value string
+	err := json.Unmarshal(data, &value)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return err
+	}
+	*u = decoded
+
+	return nil
+}
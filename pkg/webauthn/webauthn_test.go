@@ -0,0 +1,223 @@
+package webauthn
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+const (
+	testRPID   = "login.example.com"
+	testOrigin = "https://login.example.com"
+)
+
+func testRelyingParty() *RelyingParty {
+	return &RelyingParty{ID: testRPID, Name: "Login Service", Origins: []string{testOrigin}}
+}
+
+func register(t *testing.T, rp *RelyingParty, authenticator *softAuthenticator) *Credential {
+	challenge, err := NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge() returned error: %v", err)
+	}
+
+	credential, err := rp.VerifyRegistration(challenge, authenticator.create(challenge), true)
+	if err != nil {
+		t.Fatalf("VerifyRegistration() returned error: %v", err)
+	}
+
+	return credential
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	rp := testRelyingParty()
+	for _, algorithm := range []int64{AlgES256, AlgEdDSA, AlgRS256} {
+		authenticator := newSoftAuthenticator(t, testRPID, testOrigin, algorithm)
+		credential := register(t, rp, authenticator)
+
+		if credential.Algorithm != algorithm {
+			t.Errorf("VerifyRegistration() error:\n   expected: algorithm %v\n   got:      %v", algorithm, credential.Algorithm)
+		}
+		if string(credential.ID) != string(authenticator.credID) {
+			t.Errorf("VerifyRegistration() error:\n   expected: %v\n   got:      %v", authenticator.credID, credential.ID)
+		}
+
+		for i := 1; i <= 2; i++ {
+			challenge, _ := NewChallenge()
+			signCount, err := rp.VerifyAssertion(challenge, credential, authenticator.get(challenge), true)
+			if err != nil {
+				t.Fatalf("VerifyAssertion() returned error for algorithm %v: %v", algorithm, err)
+			}
+			if signCount != uint32(i) {
+				t.Errorf("VerifyAssertion() error:\n   expected: %v\n   got:      %v", i, signCount)
+			}
+			credential.SignCount = signCount
+		}
+	}
+}
+
+func TestRegistrationRejected(t *testing.T) {
+	rp := testRelyingParty()
+	challenge, _ := NewChallenge()
+
+	cases := []struct {
+		name     string
+		response func() *AttestationResponse
+		expected error
+	}{
+		{
+			name: "other challenge",
+			response: func() *AttestationResponse {
+				other, _ := NewChallenge()
+				return newSoftAuthenticator(t, testRPID, testOrigin, AlgES256).create(other)
+			},
+			expected: ErrChallengeMismatch,
+		},
+		{
+			name: "other origin",
+			response: func() *AttestationResponse {
+				return newSoftAuthenticator(t, testRPID, "https://evil.example.com", AlgES256).create(challenge)
+			},
+			expected: ErrOriginMismatch,
+		},
+		{
+			name: "other relying party",
+			response: func() *AttestationResponse {
+				return newSoftAuthenticator(t, "evil.example.com", testOrigin, AlgES256).create(challenge)
+			},
+			expected: ErrRPIDMismatch,
+		},
+		{
+			name: "user not verified",
+			response: func() *AttestationResponse {
+				authenticator := newSoftAuthenticator(t, testRPID, testOrigin, AlgES256)
+				authenticator.flags = flagUserPresent
+				return authenticator.create(challenge)
+			},
+			expected: ErrUserNotVerified,
+		},
+		{
+			name: "assertion instead of attestation",
+			response: func() *AttestationResponse {
+				response := newSoftAuthenticator(t, testRPID, testOrigin, AlgES256).create(challenge)
+				response.ClientDataJSON, _ = json.Marshal(map[string]string{"type": typeGet, "challenge": challenge, "origin": testOrigin})
+				return response
+			},
+			expected: ErrInvalidClientData,
+		},
+		{
+			name: "truncated attestation",
+			response: func() *AttestationResponse {
+				response := newSoftAuthenticator(t, testRPID, testOrigin, AlgES256).create(challenge)
+				response.AttestationObject = response.AttestationObject[:len(response.AttestationObject)-10]
+				return response
+			},
+			expected: ErrInvalidAttestation,
+		},
+	}
+
+	for _, c := range cases {
+		_, err := rp.VerifyRegistration(challenge, c.response(), true)
+		if !errors.Is(err, c.expected) {
+			t.Errorf("VerifyRegistration() error for %s:\n   expected: %v\n   got:      %v", c.name, c.expected, err)
+		}
+	}
+}
+
+func TestAssertionRejected(t *testing.T) {
+	rp := testRelyingParty()
+	authenticator := newSoftAuthenticator(t, testRPID, testOrigin, AlgES256)
+	credential := register(t, rp, authenticator)
+	challenge, _ := NewChallenge()
+
+	response := authenticator.get(challenge)
+	response.Signature[len(response.Signature)-1] ^= 0xff
+	if _, err := rp.VerifyAssertion(challenge, credential, response, false); err == nil {
+		t.Errorf("VerifyAssertion() error for a tampered signature:\n   expected: an error\n   got:      <nil>")
+	}
+
+	other := newSoftAuthenticator(t, testRPID, testOrigin, AlgES256)
+	if _, err := rp.VerifyAssertion(challenge, credential, other.get(challenge), false); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("VerifyAssertion() error for another key:\n   expected: %v\n   got:      %v", ErrBadSignature, err)
+	}
+
+	credential.SignCount = 10
+	if _, err := rp.VerifyAssertion(challenge, credential, authenticator.get(challenge), false); !errors.Is(err, ErrSignCount) {
+		t.Errorf("VerifyAssertion() error for a counter that went back:\n   expected: %v\n   got:      %v", ErrSignCount, err)
+	}
+	credential.SignCount = 0
+
+	authenticator.flags = flagUserPresent
+	if _, err := rp.VerifyAssertion(challenge, credential, authenticator.get(challenge), true); !errors.Is(err, ErrUserNotVerified) {
+		t.Errorf("VerifyAssertion() error without user verification:\n   expected: %v\n   got:      %v", ErrUserNotVerified, err)
+	}
+	if _, err := rp.VerifyAssertion(challenge, credential, authenticator.get(challenge), false); err != nil {
+		t.Errorf("VerifyAssertion() error with user presence only:\n   expected: <nil>\n   got:      %v", err)
+	}
+}
+
+func TestChallengeAndJSON(t *testing.T) {
+	challenge, _ := NewChallenge()
+	authenticator := newSoftAuthenticator(t, testRPID, testOrigin, AlgEdDSA)
+	response := authenticator.get(challenge)
+
+	got, err := Challenge(response.ClientDataJSON)
+	if err != nil || got != challenge {
+		t.Errorf("Challenge() error:\n   expected: %v\n   got:      %v %v", challenge, got, err)
+	}
+
+	data, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("json.Marshal() returned error: %v", err)
+	}
+	var decoded AssertionResponse
+	err = json.Unmarshal(data, &decoded)
+	if err != nil {
+		t.Fatalf("json.Unmarshal() returned error: %v", err)
+	}
+	if string(decoded.Signature) != string(response.Signature) {
+		t.Errorf("URLEncoded round trip error:\n   expected: %v\n   got:      %v", response.Signature, decoded.Signature)
+	}
+
+	var padded URLEncoded
+	err = json.Unmarshal([]byte(`"`+encodeURL([]byte{1, 2, 3, 4})+`=="`), &padded)
+	if err != nil || string(padded) != string([]byte{1, 2, 3, 4}) {
+		t.Errorf("URLEncoded padding error:\n   expected: %v\n   got:      %v %v", []byte{1, 2, 3, 4}, padded, err)
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	item := map[interface{}]interface{}{
+		int64(1):  int64(2),
+		int64(-7): []byte{0, 1},
+		"text":    "value",
+		"list":    []interface{}{int64(1000000), true, nil, int64(-300)},
+		"nested":  map[interface{}]interface{}{"a": false},
+	}
+	encoded := append(encodeCBOR(item), 0xff)
+
+	decoded, rest, err := decodeCBOR(encoded)
+	if err != nil {
+		t.Fatalf("decodeCBOR() returned error: %v", err)
+	}
+	if len(rest) != 1 || rest[0] != 0xff {
+		t.Errorf("decodeCBOR() error:\n   expected: rest [255]\n   got:      %v", rest)
+	}
+	if string(encodeCBOR(decoded)) != string(encoded[:len(encoded)-1]) {
+		t.Errorf("decodeCBOR() error:\n   expected: %v\n   got:      %v", item, decoded)
+	}
+
+	invalid := map[string][]byte{
+		"empty":             {},
+		"truncated string":  {0x45, 1, 2},
+		"indefinite length": {0x5f},
+		"float":             {0xfa, 0, 0, 0, 0},
+		"huge array":        {0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		"duplicate key":     {0xa2, 0x01, 0x01, 0x01, 0x02},
+	}
+	for name, data := range invalid {
+		if _, _, err := decodeCBOR(data); !errors.Is(err, ErrInvalidCBOR) {
+			t.Errorf("decodeCBOR() error for %s:\n   expected: %v\n   got:      %v", name, ErrInvalidCBOR, err)
+		}
+	}
+}